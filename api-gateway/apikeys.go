@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers forwarded upstream for requests made with an API key. Like the
+// identity headers they are always dropped from client requests.
+const (
+	HeaderAPIKeyID = "X-Api-Key-Id"
+	HeaderPartner  = "X-Partner"
+)
+
+// Quota periods
+const (
+	QuotaMinute = "minute"
+	QuotaHour   = "hour"
+	QuotaDay    = "day"
+	QuotaMonth  = "month"
+)
+
+// apiKeyPrefix starts every key; the key id follows, then the secret
+const apiKeyPrefix = "soa_"
+
+// APIKey is a partner integration key. Scopes list the path prefixes the key
+// may call, optionally preceded by a method ("GET /api/tours/published").
+// Only hashes of the secrets are stored: after a rotation the previous secret
+// keeps working until its ValidUntil.
+type APIKey struct {
+	ID        string         `bson:"_id" json:"id"`
+	Name      string         `bson:"name" json:"name"`
+	Partner   string         `bson:"partner" json:"partner"`
+	Scopes    []string       `bson:"scopes" json:"scopes"`
+	Quota     *APIKeyQuota   `bson:"quota,omitempty" json:"quota,omitempty"`
+	Secrets   []APIKeySecret `bson:"secrets" json:"-"`
+	CreatedAt time.Time      `bson:"createdAt" json:"createdAt"`
+	RotatedAt *time.Time     `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"`
+	ExpiresAt *time.Time     `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+	RevokedAt *time.Time     `bson:"revokedAt,omitempty" json:"revokedAt,omitempty"`
+}
+
+// APIKeyQuota allows Limit requests per calendar Period (UTC)
+type APIKeyQuota struct {
+	Limit  int64  `bson:"limit" json:"limit"`
+	Period string `bson:"period" json:"period"`
+}
+
+// APIKeySecret is the SHA-256 hash of a secret; the secrets are random, so
+// a fast hash is enough
+type APIKeySecret struct {
+	Hash       string     `bson:"hash"`
+	ValidUntil *time.Time `bson:"validUntil,omitempty"`
+}
+
+// Partner is the caller of a request made with an API key
+type Partner struct {
+	KeyID   string
+	Name    string
+	Partner string
+}
+
+type partnerKey struct{}
+
+// PartnerFromContext returns the partner stored by APIKeys.Middleware, if any
+func PartnerFromContext(ctx context.Context) (*Partner, bool) {
+	partner, ok := ctx.Value(partnerKey{}).(*Partner)
+	return partner, ok
+}
+
+var (
+	errAPIKeyInvalid = errors.New("unknown key or wrong secret")
+	errAPIKeyRevoked = errors.New("key has been revoked")
+	errAPIKeyExpired = errors.New("key has expired")
+)
+
+// APIKeys validates partner API keys and enforces their scopes and quotas.
+// The keys are held in memory and reloaded from the store periodically, so
+// validating a key never waits for the database; quota counters do.
+type APIKeys struct {
+	gateway *Gateway
+	store   APIKeyStore
+	now     func() time.Time
+
+	mu   sync.RWMutex
+	keys map[string]*APIKey
+}
+
+func NewAPIKeys(gateway *Gateway, store APIKeyStore) *APIKeys {
+	k := &APIKeys{gateway: gateway, store: store, now: time.Now, keys: map[string]*APIKey{}}
+	k.reload()
+	go func() {
+		for {
+			time.Sleep(k.gateway.Config().APIKeys.Refresh)
+			k.reload()
+		}
+	}()
+	return k
+}
+
+// reload replaces the cached keys; on failure the current ones are kept
+func (k *APIKeys) reload() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	list, err := k.store.List(ctx)
+	if err != nil {
+		log.Printf("[API KEYS] Reload failed, keeping the cached keys: %v", err)
+		return
+	}
+	keys := make(map[string]*APIKey, len(list))
+	for _, key := range list {
+		keys[key.ID] = key
+	}
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+}
+
+// put updates the cache right after a change made through the admin API
+func (k *APIKeys) put(key *APIKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[key.ID] = key.clone()
+}
+
+// Middleware authenticates requests carrying an API key. It must run after
+// authMiddleware and before the rate limiter, which limits partners per key.
+// The key itself is never forwarded upstream.
+func (k *APIKeys) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := k.gateway.Config().APIKeys.Header
+		raw := r.Header.Get(header)
+		if raw == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r.Header.Del(header)
+
+		key, err := k.authenticate(raw)
+		if err != nil {
+			apiKeyRequests.WithLabelValues("", "invalid").Inc()
+			log.Printf("[API KEYS] Rejected key for %s %s: %v", r.Method, r.URL.Path, err)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid API key: "+err.Error())
+			return
+		}
+		if !key.allows(r.Method, r.URL.Path) {
+			apiKeyRequests.WithLabelValues(key.Partner, "forbidden").Inc()
+			writeJSONError(w, http.StatusForbidden, "API key is not allowed to call "+r.Method+" "+r.URL.Path)
+			return
+		}
+		if key.Quota != nil && !k.takeQuota(w, r, key) {
+			apiKeyRequests.WithLabelValues(key.Partner, "quota_exceeded").Inc()
+			writeJSONError(w, http.StatusTooManyRequests, "API key quota exceeded")
+			return
+		}
+		apiKeyRequests.WithLabelValues(key.Partner, "allowed").Inc()
+
+		r.Header.Set(HeaderAPIKeyID, key.ID)
+		r.Header.Set(HeaderPartner, key.Partner)
+		setLogUser(r, "partner:"+key.Partner)
+		partner := &Partner{KeyID: key.ID, Name: key.Name, Partner: key.Partner}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), partnerKey{}, partner)))
+	})
+}
+
+// authenticate checks the secret before telling a revoked or expired key
+// apart, so the state of a key is only revealed to its holder
+func (k *APIKeys) authenticate(raw string) (*APIKey, error) {
+	id, secret, ok := parseAPIKey(raw)
+	if !ok {
+		return nil, errAPIKeyInvalid
+	}
+	k.mu.RLock()
+	key := k.keys[id]
+	k.mu.RUnlock()
+	if key == nil {
+		return nil, errAPIKeyInvalid
+	}
+
+	now := k.now()
+	if !key.matchesSecret(secret, now) {
+		return nil, errAPIKeyInvalid
+	}
+	if key.RevokedAt != nil {
+		return nil, errAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, errAPIKeyExpired
+	}
+	return key, nil
+}
+
+// takeQuota counts the request against the key's quota and sets the quota
+// headers. A failing store lets the request through.
+func (k *APIKeys) takeQuota(w http.ResponseWriter, r *http.Request, key *APIKey) bool {
+	window, reset := key.Quota.window(k.now())
+	count, err := k.store.Increment(r.Context(), key.ID, window, reset)
+	if err != nil {
+		log.Printf("[API KEYS] Quota store error for key %s: %v", key.ID, err)
+		return true
+	}
+
+	h := w.Header()
+	h.Set("X-Quota-Limit", strconv.FormatInt(key.Quota.Limit, 10))
+	h.Set("X-Quota-Remaining", strconv.FormatInt(max(key.Quota.Limit-count, 0), 10))
+	h.Set("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+	if count <= key.Quota.Limit {
+		return true
+	}
+	h.Set("Retry-After", strconv.Itoa(int(reset.Sub(k.now()).Seconds())+1))
+	return false
+}
+
+// allows tells if one of the scopes covers the request; a GET scope also
+// allows HEAD
+func (key *APIKey) allows(method, path string) bool {
+	for _, scope := range key.Scopes {
+		scopeMethod, prefix, found := strings.Cut(scope, " ")
+		if !found {
+			scopeMethod, prefix = "", scope
+		}
+		if scopeMethod != "" && scopeMethod != method && !(method == http.MethodHead && scopeMethod == http.MethodGet) {
+			continue
+		}
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (key *APIKey) matchesSecret(secret string, now time.Time) bool {
+	sum := sha256.Sum256([]byte(secret))
+	hash := hex.EncodeToString(sum[:])
+	for _, s := range key.Secrets {
+		if s.ValidUntil != nil && now.After(*s.ValidUntil) {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(s.Hash)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// addSecret generates a new secret and returns the full key. Older secrets
+// stay valid for grace at most.
+func (key *APIKey) addSecret(now time.Time, grace time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(buf)
+
+	until := now.Add(grace)
+	secrets := []APIKeySecret{}
+	for _, s := range key.Secrets {
+		expired := s.ValidUntil != nil && !s.ValidUntil.After(now)
+		if expired || grace <= 0 {
+			continue
+		}
+		if s.ValidUntil == nil || s.ValidUntil.After(until) {
+			s.ValidUntil = &until
+		}
+		secrets = append(secrets, s)
+	}
+	sum := sha256.Sum256([]byte(secret))
+	key.Secrets = append(secrets, APIKeySecret{Hash: hex.EncodeToString(sum[:])})
+	return apiKeyPrefix + key.ID + "_" + secret, nil
+}
+
+func (key *APIKey) clone() *APIKey {
+	clone := *key
+	clone.Scopes = slices.Clone(key.Scopes)
+	clone.Secrets = slices.Clone(key.Secrets)
+	if key.Quota != nil {
+		quota := *key.Quota
+		clone.Quota = &quota
+	}
+	return &clone
+}
+
+// window returns the quota window containing now and when it ends
+func (q *APIKeyQuota) window(now time.Time) (string, time.Time) {
+	now = now.UTC()
+	switch q.Period {
+	case QuotaMinute:
+		start := now.Truncate(time.Minute)
+		return start.Format("2006-01-02T15:04"), start.Add(time.Minute)
+	case QuotaHour:
+		start := now.Truncate(time.Hour)
+		return start.Format("2006-01-02T15"), start.Add(time.Hour)
+	case QuotaMonth:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01"), start.AddDate(0, 1, 0)
+	default:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start.Format("2006-01-02"), start.AddDate(0, 0, 1)
+	}
+}
+
+// newAPIKeyID returns a random id of 16 hex characters
+func newAPIKeyID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// parseAPIKey splits soa_<id>_<secret>
+func parseAPIKey(raw string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(raw, apiKeyPrefix)
+	if !ok || len(rest) < 18 || rest[16] != '_' {
+		return "", "", false
+	}
+	return rest[:16], rest[17:], true
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultRotationGrace is how long the previous secret keeps working after a
+// rotation when the request does not say otherwise
+const defaultRotationGrace = 24 * time.Hour
+
+// apiKeyRequest is the body of the create and update endpoints
+type apiKeyRequest struct {
+	Name      string       `json:"name"`
+	Partner   string       `json:"partner"`
+	Scopes    []string     `json:"scopes"`
+	Quota     *APIKeyQuota `json:"quota"`
+	ExpiresAt *time.Time   `json:"expiresAt"`
+}
+
+// apiKeyResponse adds the full key, shown only when it is created or rotated,
+// and the usage of the current quota window
+type apiKeyResponse struct {
+	*APIKey
+	Key   string       `json:"key,omitempty"`
+	Usage *apiKeyUsage `json:"usage,omitempty"`
+}
+
+type apiKeyUsage struct {
+	Window   string    `json:"window"`
+	Count    int64     `json:"count"`
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+// Admin wraps the key management endpoints; only users with the configured
+// admin role may call them
+func (k *APIKeys) Admin(handler http.HandlerFunc) http.HandlerFunc {
+	return requireRole(func() string { return k.gateway.Config().APIKeys.AdminRole }, handler)
+}
+
+// List returns every key, newest first, without secrets
+func (k *APIKeys) List(w http.ResponseWriter, r *http.Request) {
+	keys, err := k.store.List(r.Context())
+	if err != nil {
+		k.storeError(w, err)
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// Create issues a key; the response is the only place the key is shown
+func (k *APIKeys) Create(w http.ResponseWriter, r *http.Request) {
+	request, ok := decodeAPIKeyRequest(w, r)
+	if !ok {
+		return
+	}
+	id, err := newAPIKeyID()
+	if err != nil {
+		k.storeError(w, err)
+		return
+	}
+	now := k.now().UTC()
+	key := &APIKey{ID: id, CreatedAt: now}
+	request.apply(key)
+	raw, err := key.addSecret(now, 0)
+	if err != nil {
+		k.storeError(w, err)
+		return
+	}
+	if !k.save(w, r.Context(), key) {
+		return
+	}
+	log.Printf("[API KEYS] Created key %s for %s", key.ID, key.Partner)
+	writeJSON(w, http.StatusCreated, apiKeyResponse{APIKey: key, Key: raw})
+}
+
+// Get returns a key with the usage of its current quota window
+func (k *APIKeys) Get(w http.ResponseWriter, r *http.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+	response := apiKeyResponse{APIKey: key}
+	if key.Quota != nil {
+		window, reset := key.Quota.window(k.now())
+		count, err := k.store.Usage(r.Context(), key.ID, window)
+		if err != nil {
+			k.storeError(w, err)
+			return
+		}
+		response.Usage = &apiKeyUsage{Window: window, Count: count, ResetsAt: reset}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Update replaces the name, partner, scopes, quota and expiry of a key
+func (k *APIKeys) Update(w http.ResponseWriter, r *http.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+	request, ok := decodeAPIKeyRequest(w, r)
+	if !ok {
+		return
+	}
+	request.apply(key)
+	if !k.save(w, r.Context(), key) {
+		return
+	}
+	writeJSON(w, http.StatusOK, key)
+}
+
+// Rotate issues a new secret. The previous one keeps working for
+// gracePeriod (default 24h, "0s" ends it immediately) so the partner can
+// switch without downtime.
+func (k *APIKeys) Rotate(w http.ResponseWriter, r *http.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+	if key.RevokedAt != nil {
+		writeJSONError(w, http.StatusConflict, "API key has been revoked")
+		return
+	}
+	grace := defaultRotationGrace
+	var request struct {
+		GracePeriod string `json:"gracePeriod"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+	if request.GracePeriod != "" {
+		parsed, err := time.ParseDuration(request.GracePeriod)
+		if err != nil || parsed < 0 {
+			writeJSONError(w, http.StatusBadRequest, "gracePeriod must be a duration such as 24h")
+			return
+		}
+		grace = parsed
+	}
+
+	now := k.now().UTC()
+	raw, err := key.addSecret(now, grace)
+	if err != nil {
+		k.storeError(w, err)
+		return
+	}
+	key.RotatedAt = &now
+	if !k.save(w, r.Context(), key) {
+		return
+	}
+	log.Printf("[API KEYS] Rotated key %s of %s, previous secret valid for %s", key.ID, key.Partner, grace)
+	writeJSON(w, http.StatusOK, apiKeyResponse{APIKey: key, Key: raw})
+}
+
+// Revoke disables a key for good; it is kept for the record
+func (k *APIKeys) Revoke(w http.ResponseWriter, r *http.Request) {
+	key, ok := k.load(w, r)
+	if !ok {
+		return
+	}
+	if key.RevokedAt == nil {
+		now := k.now().UTC()
+		key.RevokedAt = &now
+		if !k.save(w, r.Context(), key) {
+			return
+		}
+		log.Printf("[API KEYS] Revoked key %s of %s", key.ID, key.Partner)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *APIKeys) load(w http.ResponseWriter, r *http.Request) (*APIKey, bool) {
+	key, err := k.store.Get(r.Context(), mux.Vars(r)["id"])
+	if errors.Is(err, errAPIKeyNotFound) {
+		writeJSONError(w, http.StatusNotFound, "API key not found")
+		return nil, false
+	}
+	if err != nil {
+		k.storeError(w, err)
+		return nil, false
+	}
+	return key, true
+}
+
+func (k *APIKeys) save(w http.ResponseWriter, ctx context.Context, key *APIKey) bool {
+	if err := k.store.Save(ctx, key); err != nil {
+		k.storeError(w, err)
+		return false
+	}
+	k.put(key)
+	return true
+}
+
+func (k *APIKeys) storeError(w http.ResponseWriter, err error) {
+	log.Printf("[API KEYS] Store error: %v", err)
+	writeJSONError(w, http.StatusInternalServerError, "API key store unavailable")
+}
+
+func decodeAPIKeyRequest(w http.ResponseWriter, r *http.Request) (*apiKeyRequest, bool) {
+	var request apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return nil, false
+	}
+	if err := request.validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return &request, true
+}
+
+func (req *apiKeyRequest) validate() error {
+	if strings.TrimSpace(req.Name) == "" || strings.TrimSpace(req.Partner) == "" {
+		return fmt.Errorf("name and partner are required")
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for i, scope := range req.Scopes {
+		method, prefix, found := strings.Cut(strings.TrimSpace(scope), " ")
+		if !found {
+			method, prefix = "", method
+		}
+		method = strings.ToUpper(method)
+		if method != "" && !slices.Contains([]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}, method) {
+			return fmt.Errorf("scope %q has an unknown method", scope)
+		}
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("scope %q must be a path prefix, optionally preceded by a method", scope)
+		}
+		req.Scopes[i] = strings.TrimSpace(method + " " + prefix)
+	}
+	if req.Quota != nil {
+		if req.Quota.Limit <= 0 {
+			return fmt.Errorf("quota limit must be positive")
+		}
+		if req.Quota.Period == "" {
+			req.Quota.Period = QuotaDay
+		}
+		if !slices.Contains([]string{QuotaMinute, QuotaHour, QuotaDay, QuotaMonth}, req.Quota.Period) {
+			return fmt.Errorf("quota period must be minute, hour, day or month")
+		}
+	}
+	return nil
+}
+
+func (req *apiKeyRequest) apply(key *APIKey) {
+	key.Name = strings.TrimSpace(req.Name)
+	key.Partner = strings.TrimSpace(req.Partner)
+	key.Scopes = req.Scopes
+	key.Quota = req.Quota
+	key.ExpiresAt = req.ExpiresAt
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
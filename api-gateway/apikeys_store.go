@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// API key store kinds
+const (
+	APIKeyStoreMemory = "memory"
+	APIKeyStoreMongo  = "mongo"
+)
+
+// errAPIKeyNotFound is returned by stores for an unknown key id
+var errAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyStore persists API keys and counts their usage per quota window.
+// The memory store forgets everything on restart and is meant for local
+// development; the mongo store is shared by every gateway instance.
+type APIKeyStore interface {
+	List(ctx context.Context) ([]*APIKey, error)
+	Get(ctx context.Context, id string) (*APIKey, error)
+	Save(ctx context.Context, key *APIKey) error
+	// Increment adds a request to the usage of a window and returns the new
+	// count; expires tells when the counter may be dropped
+	Increment(ctx context.Context, id, window string, expires time.Time) (int64, error)
+	Usage(ctx context.Context, id, window string) (int64, error)
+}
+
+// NewAPIKeyStore creates the store selected in the config. Like the rate
+// limit store it is picked once at startup.
+func NewAPIKeyStore(config APIKeysConfig) (APIKeyStore, error) {
+	switch config.Store {
+	case APIKeyStoreMemory:
+		return NewMemoryAPIKeyStore(), nil
+	case APIKeyStoreMongo:
+		return NewMongoAPIKeyStore(config.Database)
+	}
+	return nil, fmt.Errorf("unknown api key store %q", config.Store)
+}
+
+// MemoryAPIKeyStore keeps keys and usage in process
+type MemoryAPIKeyStore struct {
+	mu    sync.Mutex
+	keys  map[string]*APIKey
+	usage map[string]int64
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{keys: map[string]*APIKey{}, usage: map[string]int64{}}
+}
+
+func (s *MemoryAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]*APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key.clone())
+	}
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, errAPIKeyNotFound
+	}
+	return key.clone(), nil
+}
+
+func (s *MemoryAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key.ID] = key.clone()
+	return nil
+}
+
+// Increment does not expire old windows: a window is never used again once
+// it is over, and the store does not outlive the process anyway
+func (s *MemoryAPIKeyStore) Increment(ctx context.Context, id, window string, expires time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage[id+"|"+window]++
+	return s.usage[id+"|"+window], nil
+}
+
+func (s *MemoryAPIKeyStore) Usage(ctx context.Context, id, window string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.usage[id+"|"+window], nil
+}
+
+// MongoAPIKeyStore keeps keys in the api_keys collection and usage counters
+// in api_key_usage, one document per key and window, removed by a TTL index
+// once the window is over
+type MongoAPIKeyStore struct {
+	keys  *mongo.Collection
+	usage *mongo.Collection
+}
+
+func NewMongoAPIKeyStore(database string) (*MongoAPIKeyStore, error) {
+	db, err := mongoDatabase(database)
+	if err != nil {
+		return nil, err
+	}
+	s := &MongoAPIKeyStore{keys: db.Collection("api_keys"), usage: db.Collection("api_key_usage")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = s.usage.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create usage TTL index: %w", err)
+	}
+	log.Printf("[API KEYS] Using MongoDB database %s", database)
+	return s, nil
+}
+
+func (s *MongoAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	cursor, err := s.keys.Find(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+	var keys []*APIKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *MongoAPIKeyStore) Get(ctx context.Context, id string) (*APIKey, error) {
+	var key APIKey
+	err := s.keys.FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&key)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *MongoAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	_, err := s.keys.ReplaceOne(ctx, bson.D{{Key: "_id", Value: key.ID}}, key, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (s *MongoAPIKeyStore) Increment(ctx context.Context, id, window string, expires time.Time) (int64, error) {
+	var usage struct {
+		Count int64 `bson:"count"`
+	}
+	err := s.usage.FindOneAndUpdate(ctx,
+		bson.D{{Key: "_id", Value: id + "|" + window}},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "count", Value: 1}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "keyId", Value: id}, {Key: "window", Value: window}, {Key: "expiresAt", Value: expires}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&usage)
+	return usage.Count, err
+}
+
+func (s *MongoAPIKeyStore) Usage(ctx context.Context, id, window string) (int64, error) {
+	var usage struct {
+		Count int64 `bson:"count"`
+	}
+	err := s.usage.FindOne(ctx, bson.D{{Key: "_id", Value: id + "|" + window}}).Decode(&usage)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	return usage.Count, err
+}
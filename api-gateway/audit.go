@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// auditBufferSize is how many records may wait for the store; when it is
+// full new records are dropped rather than slowing requests down
+const auditBufferSize = 1024
+
+// AuditRecord describes one mutating request: who made it, what it targeted
+// and how it ended. Resource is the path below the route prefix. Upstream is
+// empty when the gateway answered itself (e.g. 403, 429).
+type AuditRecord struct {
+	ID         string    `bson:"_id" json:"id"`
+	Time       time.Time `bson:"time" json:"time"`
+	RequestID  string    `bson:"requestId" json:"requestId"`
+	UserID     string    `bson:"userId,omitempty" json:"userId,omitempty"`
+	Username   string    `bson:"username,omitempty" json:"username,omitempty"`
+	Role       string    `bson:"role,omitempty" json:"role,omitempty"`
+	APIKeyID   string    `bson:"apiKeyId,omitempty" json:"apiKeyId,omitempty"`
+	Partner    string    `bson:"partner,omitempty" json:"partner,omitempty"`
+	ClientIP   string    `bson:"clientIp" json:"clientIp"`
+	Method     string    `bson:"method" json:"method"`
+	Path       string    `bson:"path" json:"path"`
+	Route      string    `bson:"route" json:"route"`
+	Resource   string    `bson:"resource" json:"resource"`
+	Upstream   string    `bson:"upstream,omitempty" json:"upstream,omitempty"`
+	Status     int       `bson:"status" json:"status"`
+	DurationMs int64     `bson:"durationMs" json:"durationMs"`
+	ExpiresAt  time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// AuditLog records every non-GET request. Records are written by a
+// background worker so the store never adds latency to a request.
+type AuditLog struct {
+	gateway *Gateway
+	store   AuditStore
+	records chan AuditRecord
+	done    sync.WaitGroup
+}
+
+func NewAuditLog(gateway *Gateway, store AuditStore) *AuditLog {
+	a := &AuditLog{gateway: gateway, store: store, records: make(chan AuditRecord, auditBufferSize)}
+	a.done.Add(1)
+	go a.write()
+	return a
+}
+
+// Close writes the pending records; it is called once the server has
+// stopped
+func (a *AuditLog) Close() {
+	close(a.records)
+	a.done.Wait()
+}
+
+func (a *AuditLog) write() {
+	defer a.done.Done()
+	for record := range a.records {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err := a.store.Append(ctx, record)
+		cancel()
+		if err != nil {
+			auditRecords.WithLabelValues("failed").Inc()
+			log.Printf("[AUDIT] Failed to store record of request %s: %v", record.RequestID, err)
+			continue
+		}
+		auditRecords.WithLabelValues("written").Inc()
+	}
+}
+
+// Middleware records mutating requests. It must run after authMiddleware and
+// APIKeys.Middleware to know the caller, and before the rate limiter so
+// rejected requests are recorded too.
+func (a *AuditLog) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := a.gateway.Config()
+		path := r.URL.Path // the route handler rewrites r.URL in place
+		if !audited(r.Method) || config.Audit.excludes(path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		record := AuditRecord{
+			ID:        primitive.NewObjectID().Hex(),
+			Time:      start.UTC(),
+			RequestID: RequestIDFromContext(r.Context()),
+			ClientIP:  clientIP(r, config.RateLimit.TrustForwardedFor),
+			Method:    r.Method,
+			Path:      path,
+			Route:     a.gateway.routeLabel(r),
+			Resource:  path,
+			ExpiresAt: start.UTC().Add(config.Audit.Retention),
+		}
+		if rt := a.gateway.table.Load().match(path); rt != nil {
+			record.Resource = "/" + strings.TrimPrefix(strings.TrimPrefix(path, rt.Prefix), "/")
+		}
+		if identity, ok := IdentityFromContext(r.Context()); ok {
+			record.UserID, record.Username, record.Role = identity.UserID, identity.Username, identity.Role
+		}
+		if partner, ok := PartnerFromContext(r.Context()); ok {
+			record.APIKeyID, record.Partner = partner.KeyID, partner.Partner
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			record.Status = recorder.status
+			record.Upstream = loggedUpstream(r)
+			record.DurationMs = time.Since(start).Milliseconds()
+			select {
+			case a.records <- record:
+			default:
+				auditRecords.WithLabelValues("dropped").Inc()
+				log.Printf("[AUDIT] Buffer full, dropped record of request %s", record.RequestID)
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+func audited(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+func (c *AuditConfig) excludes(path string) bool {
+	for _, prefix := range c.Exclude {
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Admin wraps the audit endpoint; only users with the configured admin role
+// may read the trail
+func (a *AuditLog) Admin(handler http.HandlerFunc) http.HandlerFunc {
+	return requireRole(func() string { return a.gateway.Config().Audit.AdminRole }, handler)
+}
+
+// Query returns audit records, newest first. Filters: user (id or
+// username), partner, route, method, resource (prefix), requestId, status,
+// from and to (RFC 3339). Pages hold limit records (default 50, at most
+// 500); pass next as before to get the following page.
+func (a *AuditLog) Query(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := AuditQuery{
+		User:      params.Get("user"),
+		Partner:   params.Get("partner"),
+		Route:     params.Get("route"),
+		Method:    strings.ToUpper(params.Get("method")),
+		Resource:  params.Get("resource"),
+		RequestID: params.Get("requestId"),
+		Before:    params.Get("before"),
+		Limit:     50,
+	}
+	var err error
+	if value := params.Get("status"); value != "" {
+		if query.Status, err = strconv.Atoi(value); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "status must be a number")
+			return
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 || query.Limit > 500 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+	}
+	for name, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := params.Get(name); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				writeJSONError(w, http.StatusBadRequest, name+" must be an RFC 3339 time")
+				return
+			}
+		}
+	}
+
+	records, err := a.store.Query(r.Context(), query)
+	if err != nil {
+		log.Printf("[AUDIT] Query failed: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Audit store unavailable")
+		return
+	}
+	response := map[string]interface{}{"records": records}
+	if len(records) == query.Limit {
+		response["next"] = records[len(records)-1].ID
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Audit store kinds
+const (
+	AuditStoreMemory = "memory"
+	AuditStoreMongo  = "mongo"
+)
+
+// maxMemoryAuditRecords bounds the memory store; the oldest records go first
+const maxMemoryAuditRecords = 10000
+
+// AuditStore is an append-only store of audit records. Records are never
+// changed; they disappear only once their ExpiresAt has passed.
+type AuditStore interface {
+	Append(ctx context.Context, record AuditRecord) error
+	// Query returns the matching records, newest first
+	Query(ctx context.Context, query AuditQuery) ([]AuditRecord, error)
+}
+
+// AuditQuery filters audit records. Empty fields match everything; User is
+// a user id or username, Resource a prefix. Before is the id of the last
+// record of the previous page.
+type AuditQuery struct {
+	User      string
+	Partner   string
+	Route     string
+	Method    string
+	Resource  string
+	RequestID string
+	Status    int
+	From      time.Time
+	To        time.Time
+	Before    string
+	Limit     int
+}
+
+// NewAuditStore creates the store selected in the config
+func NewAuditStore(config AuditConfig) (AuditStore, error) {
+	switch config.Store {
+	case AuditStoreMemory:
+		return NewMemoryAuditStore(), nil
+	case AuditStoreMongo:
+		return NewMongoAuditStore(config.Database)
+	}
+	return nil, fmt.Errorf("unknown audit store %q", config.Store)
+}
+
+// MemoryAuditStore keeps the latest records in process, for local
+// development
+type MemoryAuditStore struct {
+	mu      sync.Mutex
+	records []AuditRecord // oldest first
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Append(ctx context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop the oldest records over the limit and the expired ones
+	drop := max(len(s.records)+1-maxMemoryAuditRecords, 0)
+	for drop < len(s.records) && s.records[drop].ExpiresAt.Before(record.Time) {
+		drop++
+	}
+	s.records = append(s.records[drop:], record)
+	return nil
+}
+
+func (s *MemoryAuditStore) Query(ctx context.Context, query AuditQuery) ([]AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := []AuditRecord{}
+	for i := len(s.records) - 1; i >= 0 && len(records) < query.Limit; i-- {
+		if record := s.records[i]; query.matches(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (q AuditQuery) matches(record AuditRecord) bool {
+	switch {
+	case q.User != "" && record.UserID != q.User && record.Username != q.User,
+		q.Partner != "" && record.Partner != q.Partner,
+		q.Route != "" && record.Route != q.Route,
+		q.Method != "" && record.Method != q.Method,
+		q.Resource != "" && !strings.HasPrefix(record.Resource, q.Resource),
+		q.RequestID != "" && record.RequestID != q.RequestID,
+		q.Status != 0 && record.Status != q.Status,
+		!q.From.IsZero() && record.Time.Before(q.From),
+		!q.To.IsZero() && !record.Time.Before(q.To),
+		q.Before != "" && record.ID >= q.Before:
+		return false
+	}
+	return true
+}
+
+// MongoAuditStore keeps records in the audit_log collection. A TTL index
+// on expiresAt applies the retention, so a changed retention only affects
+// new records.
+type MongoAuditStore struct {
+	records *mongo.Collection
+}
+
+func NewMongoAuditStore(database string) (*MongoAuditStore, error) {
+	db, err := mongoDatabase(database)
+	if err != nil {
+		return nil, err
+	}
+	s := &MongoAuditStore{records: db.Collection("audit_log")}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = s.records.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "route", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create audit indexes: %w", err)
+	}
+	log.Printf("[AUDIT] Using MongoDB database %s", database)
+	return s, nil
+}
+
+func (s *MongoAuditStore) Append(ctx context.Context, record AuditRecord) error {
+	_, err := s.records.InsertOne(ctx, record)
+	return err
+}
+
+func (s *MongoAuditStore) Query(ctx context.Context, query AuditQuery) ([]AuditRecord, error) {
+	filter := bson.D{}
+	if query.User != "" {
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "userId", Value: query.User}},
+			bson.D{{Key: "username", Value: query.User}},
+		}})
+	}
+	for key, value := range map[string]string{
+		"partner":   query.Partner,
+		"route":     query.Route,
+		"method":    query.Method,
+		"requestId": query.RequestID,
+	} {
+		if value != "" {
+			filter = append(filter, bson.E{Key: key, Value: value})
+		}
+	}
+	if query.Resource != "" {
+		filter = append(filter, bson.E{Key: "resource", Value: bson.D{{Key: "$regex", Value: "^" + regexp.QuoteMeta(query.Resource)}}})
+	}
+	if query.Status != 0 {
+		filter = append(filter, bson.E{Key: "status", Value: query.Status})
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		between := bson.D{}
+		if !query.From.IsZero() {
+			between = append(between, bson.E{Key: "$gte", Value: query.From})
+		}
+		if !query.To.IsZero() {
+			between = append(between, bson.E{Key: "$lt", Value: query.To})
+		}
+		filter = append(filter, bson.E{Key: "time", Value: between})
+	}
+	if query.Before != "" {
+		filter = append(filter, bson.E{Key: "_id", Value: bson.D{{Key: "$lt", Value: query.Before}}})
+	}
+
+	cursor, err := s.records.Find(ctx, filter, options.Find().
+		SetSort(bson.D{{Key: "_id", Value: -1}}).
+		SetLimit(int64(query.Limit)))
+	if err != nil {
+		return nil, err
+	}
+	records := []AuditRecord{}
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Headers used to forward the authenticated identity to upstream services.
+// Upstreams that opt in (TRUST_GATEWAY=true) verify X-Gateway-Signature
+// instead of parsing the JWT themselves. The signature also covers the
+// method and path of the upstream request, so captured headers cannot be
+// replayed against another endpoint.
+const (
+	HeaderUserID           = "X-User-Id"
+	HeaderUsername         = "X-User-Username"
+	HeaderUserRole         = "X-User-Role"
+	HeaderGatewayTimestamp = "X-Gateway-Timestamp"
+	HeaderGatewaySignature = "X-Gateway-Signature"
+)
+
+// Claims is the JWT payload issued by stakeholders-service
+type Claims struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Identity is the authenticated caller, as seen by the gateway
+type Identity struct {
+	UserID   string
+	Username string
+	Role     string
+}
+
+type identityKey struct{}
+
+// IdentityFromContext returns the identity stored by authMiddleware, if any
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+var (
+	jwtSecret = []byte(getEnv("JWT_SECRET", "super_secret_key"))
+	// identitySecret has no default: anyone knowing a default could forge
+	// identities for services that are reachable directly (see main)
+	identitySecret = []byte(os.Getenv("GATEWAY_IDENTITY_SECRET"))
+)
+
+// authMiddleware validates the bearer token once per request. Requests with
+// a bad token are rejected before they reach any upstream; requests without a
+// token pass through anonymously. Identity headers coming from the client are
+// always dropped so they cannot be spoofed; the identity is signed per
+// upstream request by signForUpstream.
+func authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stripIdentityHeaders(r.Header)
+
+		// Browsers cannot set headers on WebSocket and EventSource requests,
+		// so streams may pass the token as ?access_token= instead
+		if r.Header.Get("Authorization") == "" && streamProtocol(r) != "" {
+			query := r.URL.Query()
+			if token := query.Get("access_token"); token != "" {
+				r.Header.Set("Authorization", "Bearer "+token)
+				query.Del("access_token")
+				r.URL.RawQuery = query.Encode()
+			}
+		}
+
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" || r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		identity, err := parseToken(authHeader)
+		if err != nil {
+			log.Printf("[AUTH] Rejected token for %s %s: %v", r.Method, r.URL.Path, err)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+
+		setLogUser(r, identity.Username)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), identityKey{}, identity)))
+	})
+}
+
+// requireAuth wraps handlers whose every route needs an authenticated caller
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := IdentityFromContext(r.Context()); !ok && r.Method != http.MethodOptions {
+			writeJSONError(w, http.StatusUnauthorized, "Authorization header is missing")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole allows only users with the role returned by role, which is
+// read per request so a config reload applies immediately
+func requireRole(role func() string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "Authorization header is missing")
+			return
+		}
+		if identity.Role != role() {
+			writeJSONError(w, http.StatusForbidden, "Insufficient permissions")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func parseToken(authHeader string) (*Identity, error) {
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		return nil, fmt.Errorf("invalid authorization header format")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return jwtSecret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// Stakeholders uses the username as the user id; older tokens carry no id at all
+	userID := claims.ID
+	if userID == "" {
+		userID = claims.Username
+	}
+	return &Identity{UserID: userID, Username: claims.Username, Role: claims.Role}, nil
+}
+
+func stripIdentityHeaders(h http.Header) {
+	h.Del(HeaderUserID)
+	h.Del(HeaderUsername)
+	h.Del(HeaderUserRole)
+	h.Del(HeaderGatewayTimestamp)
+	h.Del(HeaderGatewaySignature)
+	h.Del(HeaderAPIKeyID)
+	h.Del(HeaderPartner)
+}
+
+// signForUpstream signs the identity of the caller in ctx for one upstream
+// request; anonymous requests carry no identity headers
+func signForUpstream(ctx context.Context, h http.Header, method, path string) {
+	if identity, ok := IdentityFromContext(ctx); ok {
+		signIdentity(h, identity, method, path, time.Now())
+	}
+}
+
+// signIdentity sets the identity headers and an HMAC-SHA256 signature over
+// "method|path|userId|username|role|timestamp" using GATEWAY_IDENTITY_SECRET.
+// path is the path the upstream receives, after any rewrite.
+func signIdentity(h http.Header, identity *Identity, method, path string, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	h.Set(HeaderUserID, identity.UserID)
+	h.Set(HeaderUsername, identity.Username)
+	h.Set(HeaderUserRole, identity.Role)
+	h.Set(HeaderGatewayTimestamp, timestamp)
+	h.Set(HeaderGatewaySignature, identitySignature(identity, method, path, timestamp))
+}
+
+func identitySignature(identity *Identity, method, path, timestamp string) string {
+	mac := hmac.New(sha256.New, identitySecret)
+	mac.Write([]byte(method + "|" + path + "|" + identity.UserID + "|" + identity.Username + "|" + identity.Role + "|" + timestamp))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// writeJSONError writes a consistent {"error": "..."} body
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
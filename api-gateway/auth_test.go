@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignIdentityCoversMethodAndPath(t *testing.T) {
+	previous := identitySecret
+	identitySecret = []byte("test-secret")
+	t.Cleanup(func() { identitySecret = previous })
+	identity := &Identity{UserID: "1", Username: "ana", Role: "tourist"}
+	now := time.Unix(1700000000, 0)
+
+	signed := http.Header{}
+	signIdentity(signed, identity, http.MethodGet, "/api/tours/1", now)
+	if got := signed.Get(HeaderUsername); got != "ana" {
+		t.Fatalf("%s = %q, want ana", HeaderUsername, got)
+	}
+	signature := signed.Get(HeaderGatewaySignature)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		same   bool
+	}{
+		{"same request", http.MethodGet, "/api/tours/1", true},
+		{"other method", http.MethodDelete, "/api/tours/1", false},
+		{"other path", http.MethodGet, "/api/tours/2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			signIdentity(h, identity, tt.method, tt.path, now)
+			if got := h.Get(HeaderGatewaySignature) == signature; got != tt.same {
+				t.Fatalf("signature equal = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
+
+func TestSignForUpstreamAnonymous(t *testing.T) {
+	h := http.Header{}
+	signForUpstream(context.Background(), h, http.MethodGet, "/api/tours")
+	if len(h) != 0 {
+		t.Fatalf("anonymous request got identity headers %v", h)
+	}
+
+	ctx := context.WithValue(context.Background(), identityKey{}, &Identity{UserID: "1", Username: "ana", Role: "tourist"})
+	signForUpstream(ctx, h, http.MethodGet, "/api/tours")
+	if h.Get(HeaderGatewaySignature) == "" || h.Get(HeaderGatewayTimestamp) == "" {
+		t.Fatalf("signed request is missing signature headers: %v", h)
+	}
+}
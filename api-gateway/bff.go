@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// Upstreams the BFF and GraphQL endpoints aggregate, by their name in the
+// config file
+const (
+	bffToursUpstream        = "tours"
+	bffStakeholdersUpstream = "stakeholders"
+	bffEncountersUpstream   = "encounters"
+	bffFollowerUpstream     = "follower"
+	bffBlogUpstream         = "blog"
+)
+
+// errNotFound marks a 404 from an upstream, which is a valid "no data" answer
+var errNotFound = errors.New("not found")
+
+// TourDetail is the composite DTO of the tour detail page. Parts that could
+// not be loaded are null and have a message in Errors.
+type TourDetail struct {
+	Tour            json.RawMessage            `json:"tour"`
+	Reviewers       map[string]json.RawMessage `json:"reviewers"`
+	ActiveExecution json.RawMessage            `json:"activeExecution"`
+	AuthorFollowed  *bool                      `json:"authorFollowed"`
+	Partial         bool                       `json:"partial"`
+	Errors          map[string]string          `json:"errors,omitempty"`
+}
+
+// BFF serves backend-for-frontend endpoints that combine several services
+// into one response for the Angular app
+type BFF struct {
+	gateway *Gateway
+}
+
+func NewBFF(gateway *Gateway) *BFF {
+	return &BFF{gateway: gateway}
+}
+
+// TourDetail loads the tour and, concurrently, the caller's active execution;
+// once the tour is known it loads the reviewers' profiles and whether the
+// caller follows the author. Only a missing tour fails the request.
+func (b *BFF) TourDetail(w http.ResponseWriter, r *http.Request) {
+	tourID := mux.Vars(r)["id"]
+	ctx, cancel := context.WithTimeout(r.Context(), b.gateway.Config().BFF.Timeout)
+	defer cancel()
+
+	identity, authenticated := IdentityFromContext(r.Context())
+	detail := &TourDetail{Reviewers: map[string]json.RawMessage{}, Errors: map[string]string{}}
+	var mu sync.Mutex
+	fail := func(part string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		detail.Errors[part] = err.Error()
+	}
+
+	var wg sync.WaitGroup
+	if authenticated {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			execution, err := b.gateway.getJSON(ctx, r, bffEncountersUpstream, "/api/tour-executions/active")
+			if errors.Is(err, errNotFound) {
+				return
+			}
+			if err != nil {
+				fail("activeExecution", err)
+				return
+			}
+			// Only an execution of this tour is relevant for the page
+			var active struct{ TourId string }
+			if json.Unmarshal(execution, &active) == nil && active.TourId == tourID {
+				detail.ActiveExecution = execution
+			}
+		}()
+	}
+
+	tour, err := b.gateway.getJSON(ctx, r, bffToursUpstream, "/api/tours/"+url.PathEscape(tourID))
+	if err != nil {
+		wg.Wait()
+		if errors.Is(err, errNotFound) {
+			writeJSONError(w, http.StatusNotFound, "Tour not found")
+		} else {
+			writeJSONError(w, http.StatusBadGateway, "Could not load tour: "+err.Error())
+		}
+		return
+	}
+	detail.Tour = tour
+
+	var summary struct {
+		AuthorId string `json:"authorId"`
+		Reviews  []struct {
+			TouristId string `json:"touristId"`
+		} `json:"reviews"`
+	}
+	json.Unmarshal(tour, &summary)
+
+	seen := map[string]bool{}
+	for _, review := range summary.Reviews {
+		username := review.TouristId
+		if username == "" || seen[username] {
+			continue
+		}
+		seen[username] = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			profile, err := b.gateway.getJSON(ctx, r, bffStakeholdersUpstream, "/api/stakeholders/users/"+url.PathEscape(username))
+			if err != nil {
+				fail("reviewers."+username, err)
+				return
+			}
+			mu.Lock()
+			detail.Reviewers[username] = profile
+			mu.Unlock()
+		}()
+	}
+
+	if authenticated && summary.AuthorId != "" && summary.AuthorId != identity.UserID {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body, err := b.gateway.getJSON(ctx, r, bffFollowerUpstream, "/api/users/"+url.PathEscape(summary.AuthorId)+"/is-following")
+			if err != nil {
+				fail("authorFollowed", err)
+				return
+			}
+			var response struct {
+				IsFollowing bool `json:"isFollowing"`
+			}
+			if err := json.Unmarshal(body, &response); err != nil {
+				fail("authorFollowed", err)
+				return
+			}
+			detail.AuthorFollowed = &response.IsFollowing
+		}()
+	}
+	wg.Wait()
+
+	detail.Partial = len(detail.Errors) > 0
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(detail)
+}
+
+// getJSON calls an upstream through its proxy transport (circuit breaker,
+// retries, timeouts), forwarding the caller's identity and request id
+func (g *Gateway) getJSON(ctx context.Context, r *http.Request, upstreamName, path string) (json.RawMessage, error) {
+	upstream := g.Upstream(upstreamName)
+	if upstream == nil {
+		return nil, fmt.Errorf("upstream %q is not configured", upstreamName)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.Target.String()+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for _, header := range []string{"Authorization", RequestIDHeader} {
+		if value := r.Header.Get(header); value != "" {
+			req.Header.Set(header, value)
+		}
+	}
+	signForUpstream(r.Context(), req.Header, req.Method, req.URL.Path)
+
+	resp, err := upstream.Client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrCircuitOpen) {
+			return nil, fmt.Errorf("%s is temporarily disabled", upstreamName)
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%s timed out", upstreamName)
+		}
+		return nil, fmt.Errorf("%s is unavailable", upstreamName)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNotFound
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("%s requires authentication", upstreamName)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %d", upstreamName, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", upstreamName, err)
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("%s returned invalid JSON", upstreamName)
+	}
+	return body, nil
+}
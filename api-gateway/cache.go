@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache key modes
+const (
+	CacheKeyPublic = "public" // one entry shared by every client
+	CacheKeyUser   = "user"   // one entry per authenticated user
+)
+
+// cachedResponse is a stored 200 response
+type cachedResponse struct {
+	key      string
+	rule     string
+	header   http.Header
+	body     []byte
+	etag     string
+	storedAt time.Time
+	expires  time.Time
+}
+
+// ResponseCache is an in-memory LRU cache for GET responses of the routes
+// listed in the cache config. Successful writes matching a rule's
+// invalidateOn patterns purge that rule's entries.
+type ResponseCache struct {
+	gateway *Gateway
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front = most recently used
+	now     func() time.Time
+}
+
+func NewResponseCache(gateway *Gateway) *ResponseCache {
+	return &ResponseCache{
+		gateway: gateway,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Middleware must run after authMiddleware, per-user keys need the identity
+func (c *ResponseCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := c.gateway.Config().Cache
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions {
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if recorder.status >= 200 && recorder.status < 300 {
+				for _, rule := range config.invalidatedBy(r.URL.Path) {
+					c.purge(rule)
+				}
+			}
+			return
+		}
+
+		rule := config.match(r.URL.Path)
+		if r.Method != http.MethodGet || rule == nil || rule.Bypass {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := cacheKey(r, rule, c.gateway.Variant(r))
+		if !strings.Contains(r.Header.Get("Cache-Control"), "no-cache") {
+			if entry := c.get(key); entry != nil {
+				cacheRequests.WithLabelValues(rule.Name, "hit").Inc()
+				c.serve(w, r, entry)
+				return
+			}
+		}
+		cacheRequests.WithLabelValues(rule.Name, "miss").Inc()
+
+		w.Header().Set("X-Cache", "MISS")
+		recorder := &cacheRecorder{ResponseWriter: w, status: http.StatusOK, limit: config.MaxBodyBytes}
+		next.ServeHTTP(recorder, r)
+
+		ttl, ok := storableFor(recorder, rule)
+		if !ok {
+			return
+		}
+		body := recorder.body.Bytes()
+		etag := recorder.Header().Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(body)
+			etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+		}
+		now := c.now()
+		c.put(&cachedResponse{
+			key:      key,
+			rule:     rule.Name,
+			header:   cacheableHeaders(recorder.Header()),
+			body:     append([]byte(nil), body...),
+			etag:     etag,
+			storedAt: now,
+			expires:  now.Add(ttl),
+		}, config.MaxEntries)
+	})
+}
+
+// serve writes a cached entry, answering 304 when the client already has it
+func (c *ResponseCache) serve(w http.ResponseWriter, r *http.Request, entry *cachedResponse) {
+	h := w.Header()
+	for name, values := range entry.header {
+		h[name] = values
+	}
+	h.Set("ETag", entry.etag)
+	h.Set("Age", strconv.Itoa(int(c.now().Sub(entry.storedAt).Seconds())))
+	h.Set("X-Cache", "HIT")
+
+	if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+		h.Del("Content-Length")
+		h.Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Length", strconv.Itoa(len(entry.body)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(entry.body)
+}
+
+func (c *ResponseCache) get(key string) *cachedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*cachedResponse)
+	if c.now().After(entry.expires) {
+		c.lru.Remove(element)
+		delete(c.entries, key)
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return entry
+}
+
+func (c *ResponseCache) put(entry *cachedResponse, maxEntries int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		c.lru.Remove(element)
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	for c.lru.Len() > maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// purge drops every entry stored under a rule
+func (c *ResponseCache) purge(rule string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, element := range c.entries {
+		if element.Value.(*cachedResponse).rule == rule {
+			c.lru.Remove(element)
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	if removed > 0 {
+		cacheInvalidations.WithLabelValues(rule).Inc()
+	}
+}
+
+// cacheKey builds the entry key; stable and canary responses of a route with
+// a canary are kept apart
+func cacheKey(r *http.Request, rule *CacheRule, variant string) string {
+	subject := "public"
+	if rule.Key == CacheKeyUser {
+		subject = "anonymous"
+		if identity, ok := IdentityFromContext(r.Context()); ok {
+			subject = "user:" + identity.UserID
+		}
+	}
+	// Query().Encode sorts the parameters, so ?a=1&b=2 and ?b=2&a=1 share an entry
+	return rule.Name + "|" + subject + "|" + variant + "|" + r.URL.Path + "?" + r.URL.Query().Encode()
+}
+
+// storableFor decides whether a response may be cached and for how long.
+// The upstream Cache-Control is honored: no-store, no-cache and private
+// (for shared entries) prevent caching, s-maxage or max-age shorten the TTL.
+func storableFor(recorder *cacheRecorder, rule *CacheRule) (time.Duration, bool) {
+	h := recorder.Header()
+	if recorder.status != http.StatusOK || recorder.overflow || h.Get("Set-Cookie") != "" || h.Get("Vary") == "*" {
+		return 0, false
+	}
+
+	ttl := rule.TTL
+	maxAge, sharedMaxAge := -1, -1
+	for _, directive := range strings.Split(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(strings.ToLower(directive)), "=")
+		switch name {
+		case "no-store", "no-cache":
+			return 0, false
+		case "private":
+			if rule.Key == CacheKeyPublic {
+				return 0, false
+			}
+		case "max-age":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				maxAge = seconds
+			}
+		case "s-maxage":
+			if seconds, err := strconv.Atoi(value); err == nil {
+				sharedMaxAge = seconds
+			}
+		}
+	}
+	if sharedMaxAge < 0 {
+		sharedMaxAge = maxAge
+	}
+	if sharedMaxAge >= 0 {
+		if upstream := time.Duration(sharedMaxAge) * time.Second; upstream < ttl {
+			ttl = upstream
+		}
+	}
+	return ttl, ttl > 0
+}
+
+// cacheableHeaders keeps the upstream headers worth replaying; CORS (with
+// Vary), rate limit and request id headers are set per request by the
+// middleware chain
+func cacheableHeaders(h http.Header) http.Header {
+	stored := http.Header{}
+	for name, values := range h {
+		if strings.HasPrefix(name, "Access-Control-") || strings.HasPrefix(name, "X-Ratelimit-") ||
+			name == "Vary" || name == "X-Request-Id" || name == "X-Cache" || name == "Retry-After" || name == "Date" {
+			continue
+		}
+		stored[name] = append([]string(nil), values...)
+	}
+	return stored
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheRecorder passes the response through to the client and keeps a copy
+// of the body, up to limit bytes
+type cacheRecorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (r *cacheRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *cacheRecorder) Write(b []byte) (int, error) {
+	if !r.overflow {
+		if int64(r.body.Len()+len(b)) > r.limit {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach Flush on the real writer
+func (r *cacheRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (c *CacheConfig) match(path string) *CacheRule {
+	for i := range c.Rules {
+		if matchPattern(c.Rules[i].Pattern, path) {
+			return &c.Rules[i]
+		}
+	}
+	return nil
+}
+
+func (c *CacheConfig) invalidatedBy(path string) []string {
+	var rules []string
+	for _, rule := range c.Rules {
+		for _, pattern := range rule.InvalidateOn {
+			if matchPattern(pattern, path) {
+				rules = append(rules, rule.Name)
+				break
+			}
+		}
+	}
+	return rules
+}
+
+// matchPattern matches a path against a pattern where {name} stands for one
+// path segment and a trailing * for any remainder, e.g. /api/tours/{id}/*
+func matchPattern(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "*" && i == len(patternSegments)-1 {
+			return len(pathSegments) > i
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(pathSegments) == len(patternSegments)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/tours/published", "/api/tours/published", true},
+		{"/api/tours/published", "/api/tours/published/", true},
+		{"/api/tours/published", "/api/tours", false},
+		{"/api/tours/{id}", "/api/tours/42", true},
+		{"/api/tours/{id}", "/api/tours/42/reviews", false},
+		{"/api/tours/{id}", "/api/tours", false},
+		{"/api/tours/{id}/*", "/api/tours/42/reviews", true},
+		{"/api/tours/{id}/*", "/api/tours/42/keypoints/7", true},
+		{"/api/tours/{id}/*", "/api/tours/42", false},
+		{"/api/tours/{id}/*", "/api/blogs/42/comments", false},
+		{"/api/tours/{id}/archive", "/api/tours/42/archive", true},
+		{"/api/tours/{id}/archive", "/api/tours/42/publish", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	public := &CacheRule{Name: "published-tours", Key: CacheKeyPublic}
+	perUser := &CacheRule{Name: "recommended", Key: CacheKeyUser}
+	signedIn := func(r *http.Request, userID string) *http.Request {
+		return r.WithContext(context.WithValue(r.Context(), identityKey{}, &Identity{UserID: userID}))
+	}
+	key := func(r *http.Request, rule *CacheRule, variant string) string { return cacheKey(r, rule, variant) }
+
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"query order does not matter",
+			key(httptest.NewRequest("GET", "/api/tours/published?a=1&b=2", nil), public, ""),
+			key(httptest.NewRequest("GET", "/api/tours/published?b=2&a=1", nil), public, ""), true},
+		{"query values do",
+			key(httptest.NewRequest("GET", "/api/tours/published?page=1", nil), public, ""),
+			key(httptest.NewRequest("GET", "/api/tours/published?page=2", nil), public, ""), false},
+		{"public entries are shared between users",
+			key(signedIn(httptest.NewRequest("GET", "/api/tours/published", nil), "1"), public, ""),
+			key(signedIn(httptest.NewRequest("GET", "/api/tours/published", nil), "2"), public, ""), true},
+		{"per-user entries are not",
+			key(signedIn(httptest.NewRequest("GET", "/api/recommended", nil), "1"), perUser, ""),
+			key(signedIn(httptest.NewRequest("GET", "/api/recommended", nil), "2"), perUser, ""), false},
+		{"anonymous users share a per-user entry",
+			key(httptest.NewRequest("GET", "/api/recommended", nil), perUser, ""),
+			key(httptest.NewRequest("GET", "/api/recommended", nil), perUser, ""), true},
+		{"canary variants are kept apart",
+			key(httptest.NewRequest("GET", "/api/tours/published", nil), public, "stable"),
+			key(httptest.NewRequest("GET", "/api/tours/published", nil), public, "canary"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.a == tt.b; got != tt.same {
+			t.Errorf("%s: keys %q and %q equal = %v, want %v", tt.name, tt.a, tt.b, got, tt.same)
+		}
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		ifNoneMatch string
+		want        bool
+	}{
+		{"", false},
+		{`"abc"`, true},
+		{`W/"abc"`, true},
+		{`"xyz", "abc"`, true},
+		{`"xyz"`, false},
+		{"*", true},
+	}
+	for _, tt := range tests {
+		if got := etagMatches(tt.ifNoneMatch, `"abc"`); got != tt.want {
+			t.Errorf("etagMatches(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+		}
+	}
+}
+
+func TestStorableFor(t *testing.T) {
+	rule := &CacheRule{Name: "tour", TTL: time.Minute, Key: CacheKeyPublic}
+	tests := []struct {
+		name    string
+		status  int
+		header  http.Header
+		wantTTL time.Duration
+		wantOK  bool
+	}{
+		{"plain 200", 200, http.Header{}, time.Minute, true},
+		{"error status", 404, http.Header{}, 0, false},
+		{"no-store", 200, http.Header{"Cache-Control": {"no-store"}}, 0, false},
+		{"private on a public rule", 200, http.Header{"Cache-Control": {"private, max-age=30"}}, 0, false},
+		{"shorter max-age", 200, http.Header{"Cache-Control": {"max-age=10"}}, 10 * time.Second, true},
+		{"s-maxage wins", 200, http.Header{"Cache-Control": {"max-age=10, s-maxage=20"}}, 20 * time.Second, true},
+		{"longer max-age keeps the rule TTL", 200, http.Header{"Cache-Control": {"max-age=3600"}}, time.Minute, true},
+		{"cookie", 200, http.Header{"Set-Cookie": {"session=1"}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &cacheRecorder{ResponseWriter: httptest.NewRecorder(), status: tt.status}
+			for name, values := range tt.header {
+				recorder.Header()[name] = values
+			}
+			ttl, ok := storableFor(recorder, rule)
+			if ttl != tt.wantTTL || ok != tt.wantOK {
+				t.Errorf("storableFor() = %s, %v, want %s, %v", ttl, ok, tt.wantTTL, tt.wantOK)
+			}
+		})
+	}
+}
+
+// newTestCache puts the cache in front of an upstream that answers reads
+// with 200 and writes with *writeStatus
+func newTestCache() (cache http.Handler, writeStatus *int) {
+	gateway := NewGateway(&GatewayConfig{Cache: CacheConfig{MaxEntries: 100, MaxBodyBytes: 1 << 20, Rules: []CacheRule{
+		{Name: "published-tours", Pattern: "/api/tours/published", TTL: time.Minute, Key: CacheKeyPublic, InvalidateOn: []string{"/api/tours/{id}/*"}},
+		{Name: "tour", Pattern: "/api/tours/{id}", TTL: time.Minute, Key: CacheKeyPublic, InvalidateOn: []string{"/api/tours/{id}/*"}},
+		{Name: "blogs", Pattern: "/api/blogs", TTL: time.Minute, Key: CacheKeyPublic, InvalidateOn: []string{"/api/blogs/{id}/*"}},
+	}}})
+	writeStatus = new(int)
+	upstream := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(*writeStatus)
+			return
+		}
+		w.Write([]byte(`{"id":"42"}`))
+	})
+	return NewResponseCache(gateway).Middleware(upstream), writeStatus
+}
+
+func TestResponseCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		status      int
+		read        string
+		invalidated bool
+	}{
+		{"review of a tour", http.MethodPost, "/api/tours/42/reviews", http.StatusCreated, "/api/tours/published", true},
+		{"key point of a tour", http.MethodPut, "/api/tours/42/keypoints/7", http.StatusOK, "/api/tours/42", true},
+		{"another tour", http.MethodPost, "/api/tours/7/publish", http.StatusOK, "/api/tours/42", true},
+		{"failed write", http.MethodPost, "/api/tours/42/reviews", http.StatusBadRequest, "/api/tours/published", false},
+		{"write elsewhere", http.MethodPost, "/api/blogs/1/comments", http.StatusCreated, "/api/tours/published", false},
+		{"path outside invalidateOn", http.MethodPut, "/api/tours/42", http.StatusOK, "/api/tours/42", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, writeStatus := newTestCache()
+			read := func() string {
+				recorder := httptest.NewRecorder()
+				cache.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.read, nil))
+				return recorder.Header().Get("X-Cache")
+			}
+
+			if got := read(); got != "MISS" {
+				t.Fatalf("first read: X-Cache = %q, want MISS", got)
+			}
+			if got := read(); got != "HIT" {
+				t.Fatalf("second read: X-Cache = %q, want HIT", got)
+			}
+
+			*writeStatus = tt.status
+			cache.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			want := "HIT"
+			if tt.invalidated {
+				want = "MISS"
+			}
+			if got := read(); got != want {
+				t.Fatalf("read after %s %s (%d): X-Cache = %q, want %s", tt.method, tt.path, tt.status, got, want)
+			}
+		})
+	}
+}
+
+func TestResponseCacheNotModified(t *testing.T) {
+	cache, _ := newTestCache()
+	first := httptest.NewRecorder()
+	cache.ServeHTTP(first, httptest.NewRequest(http.MethodGet, "/api/tours/42", nil))
+	second := httptest.NewRecorder()
+	cache.ServeHTTP(second, httptest.NewRequest(http.MethodGet, "/api/tours/42", nil))
+	etag := second.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("cached response has no ETag")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/tours/42", nil)
+	req.Header.Set("If-None-Match", etag)
+	recorder := httptest.NewRecorder()
+	cache.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Fatalf("conditional read = %d with %d body bytes, want 304 without a body", recorder.Code, recorder.Body.Len())
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Variants of a route with a canary
+const (
+	VariantStable = "stable"
+	VariantCanary = "canary"
+)
+
+// VariantHeader tells the client which variant answered
+const VariantHeader = "X-Gateway-Variant"
+
+// canaryHandler dispatches a request to the stable or the canary upstream of
+// a route and counts the requests of each variant
+func canaryHandler(rt *route, stable, canary http.Handler, trustForwardedFor bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		variant := rt.Canary.variant(r, trustForwardedFor)
+		next := stable
+		if variant == VariantCanary {
+			next = canary
+		}
+		w.Header().Set(VariantHeader, variant)
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			variantRequests.WithLabelValues(rt.Name, variant, strconv.Itoa(recorder.status)).Inc()
+			if streamProtocol(r) == "" {
+				variantDuration.WithLabelValues(rt.Name, variant).Observe(time.Since(start).Seconds())
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// variant picks the variant of a request: an explicit header or cookie
+// first, then the canary roles, then the sticky weighted share
+func (c *CanaryConfig) variant(r *http.Request, trustForwardedFor bool) string {
+	if c.Header != "" {
+		if variant, ok := requestedVariant(r.Header.Get(c.Header)); ok {
+			return variant
+		}
+	}
+	if c.Cookie != "" {
+		if cookie, err := r.Cookie(c.Cookie); err == nil {
+			if variant, ok := requestedVariant(cookie.Value); ok {
+				return variant
+			}
+		}
+	}
+	if identity, ok := IdentityFromContext(r.Context()); ok && slices.Contains(c.Roles, identity.Role) {
+		return VariantCanary
+	}
+	if c.Weight <= 0 {
+		return VariantStable
+	}
+
+	// Hashed with the canary upstream, so routes sharing a canary (such as
+	// the encounters routes) put a user on the same variant
+	hash := fnv.New32a()
+	hash.Write([]byte(c.Upstream + "|" + rateLimitSubject(r, RateLimitKeyUser, trustForwardedFor)))
+	if float64(hash.Sum32()%10000) < c.Weight*100 {
+		return VariantCanary
+	}
+	return VariantStable
+}
+
+func requestedVariant(value string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "1", VariantCanary:
+		return VariantCanary, true
+	case "false", "0", VariantStable:
+		return VariantStable, true
+	}
+	return "", false
+}
+
+// Variant returns the variant a request is routed to, or "" when its route
+// has no canary. The response cache keys entries by it.
+func (g *Gateway) Variant(r *http.Request) string {
+	table := g.table.Load()
+	if table.matchGRPC(r.Method, r.URL.Path) != nil {
+		return ""
+	}
+	rt := table.match(r.URL.Path)
+	if rt == nil || rt.Canary == nil {
+		return ""
+	}
+	return rt.Canary.variant(r, table.config.RateLimit.TrustForwardedFor)
+}
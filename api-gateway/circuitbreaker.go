@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"    // requests flow, failures are counted
+	CircuitOpen     = "open"      // requests fail fast until OpenTimeout passes
+	CircuitHalfOpen = "half-open" // a few trial requests decide whether to close again
+)
+
+// ErrCircuitOpen is returned instead of calling an upstream whose circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker protects one upstream. It opens after FailureThreshold
+// consecutive failures, and after OpenTimeout lets HalfOpenRequests trial
+// requests through: one success closes it, one failure opens it again.
+type CircuitBreaker struct {
+	name string
+
+	mu       sync.Mutex
+	settings CircuitBreakerConfig
+	state    string
+	failures int
+	openedAt time.Time
+	inFlight int // trial requests currently running in half-open state
+	now      func() time.Time
+}
+
+func NewCircuitBreaker(name string, settings CircuitBreakerConfig) *CircuitBreaker {
+	circuitState.WithLabelValues(name).Set(circuitStateValues[CircuitClosed])
+	return &CircuitBreaker{name: name, settings: settings, state: CircuitClosed, now: time.Now}
+}
+
+// Configure applies new settings without resetting the current state
+func (b *CircuitBreaker) Configure(settings CircuitBreakerConfig) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settings = settings
+}
+
+// State returns the current state, moving from open to half-open when due
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// RetryAfter is how long until an open circuit lets trial requests through
+func (b *CircuitBreaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != CircuitOpen {
+		return 0
+	}
+	return b.settings.OpenTimeout - b.now().Sub(b.openedAt)
+}
+
+// Allow reports whether a request may be sent. Every allowed request must be
+// followed by exactly one call to Record or Release.
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case CircuitOpen:
+		return ErrCircuitOpen
+	case CircuitHalfOpen:
+		if b.inFlight >= b.settings.HalfOpenRequests {
+			return ErrCircuitOpen
+		}
+		b.inFlight++
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed request
+func (b *CircuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitHalfOpen {
+		b.inFlight--
+		if success {
+			b.transition(CircuitClosed)
+		} else {
+			b.transition(CircuitOpen)
+		}
+		return
+	}
+
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == CircuitClosed && b.failures >= b.settings.FailureThreshold {
+		b.transition(CircuitOpen)
+	}
+}
+
+// Release gives back the slot of an allowed request whose outcome says
+// nothing about the upstream, without counting it as a success or a failure
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen && b.inFlight > 0 {
+		b.inFlight--
+	}
+}
+
+func (b *CircuitBreaker) advance() {
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		b.transition(CircuitHalfOpen)
+	}
+}
+
+func (b *CircuitBreaker) transition(state string) {
+	if b.state == state {
+		return
+	}
+	log.Printf("[CIRCUIT] %s: %s -> %s", b.name, b.state, state)
+	b.state = state
+	circuitState.WithLabelValues(b.name).Set(circuitStateValues[state])
+	b.failures = 0
+	b.inFlight = 0
+	if state == CircuitOpen {
+		b.openedAt = b.now()
+	}
+}
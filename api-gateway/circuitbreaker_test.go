@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// Steps of a circuit breaker scenario
+const (
+	stepSuccess  = "success"  // an allowed request that succeeds
+	stepFailure  = "failure"  // an allowed request that fails
+	stepTrial    = "trial"    // an allowed request still in flight
+	stepRejected = "rejected" // a request refused with ErrCircuitOpen
+	stepWait     = "wait"     // time passes
+)
+
+type breakerStep struct {
+	action string
+	wait   time.Duration
+	state  string // state after the step
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	settings := CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: 10 * time.Second, HalfOpenRequests: 1}
+	open := []breakerStep{
+		{action: stepFailure, state: CircuitClosed},
+		{action: stepFailure, state: CircuitClosed},
+		{action: stepFailure, state: CircuitOpen},
+	}
+	halfOpen := append(open[:len(open):len(open)], breakerStep{action: stepWait, wait: 10 * time.Second, state: CircuitHalfOpen})
+
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		{"opens after consecutive failures", append(open, breakerStep{action: stepRejected, state: CircuitOpen})},
+		{"success resets the failure count", []breakerStep{
+			{action: stepFailure, state: CircuitClosed},
+			{action: stepFailure, state: CircuitClosed},
+			{action: stepSuccess, state: CircuitClosed},
+			{action: stepFailure, state: CircuitClosed},
+			{action: stepFailure, state: CircuitClosed},
+		}},
+		{"stays open until the timeout", append(open,
+			breakerStep{action: stepWait, wait: 9 * time.Second, state: CircuitOpen},
+			breakerStep{action: stepRejected, state: CircuitOpen},
+			breakerStep{action: stepWait, wait: time.Second, state: CircuitHalfOpen},
+		)},
+		{"trial success closes", append(halfOpen,
+			breakerStep{action: stepSuccess, state: CircuitClosed},
+			breakerStep{action: stepFailure, state: CircuitClosed},
+		)},
+		{"trial failure opens again", append(halfOpen,
+			breakerStep{action: stepFailure, state: CircuitOpen},
+			breakerStep{action: stepRejected, state: CircuitOpen},
+			breakerStep{action: stepWait, wait: 10 * time.Second, state: CircuitHalfOpen},
+		)},
+		{"half-open limits trial requests", append(halfOpen,
+			breakerStep{action: stepTrial, state: CircuitHalfOpen},
+			breakerStep{action: stepRejected, state: CircuitHalfOpen},
+		)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			breaker := NewCircuitBreaker("test", settings)
+			breaker.now = func() time.Time { return now }
+
+			for i, step := range tt.steps {
+				switch step.action {
+				case stepSuccess, stepFailure, stepTrial:
+					if err := breaker.Allow(); err != nil {
+						t.Fatalf("step %d (%s): Allow() = %v", i, step.action, err)
+					}
+					if step.action != stepTrial {
+						breaker.Record(step.action == stepSuccess)
+					}
+				case stepRejected:
+					if err := breaker.Allow(); !errors.Is(err, ErrCircuitOpen) {
+						t.Fatalf("step %d: Allow() = %v, want ErrCircuitOpen", i, err)
+					}
+				case stepWait:
+					now = now.Add(step.wait)
+				}
+				if state := breaker.State(); state != step.state {
+					t.Fatalf("step %d (%s): state = %s, want %s", i, step.action, state, step.state)
+				}
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	breaker := NewCircuitBreaker("test", CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 30 * time.Second, HalfOpenRequests: 1})
+	breaker.now = func() time.Time { return now }
+
+	if got := breaker.RetryAfter(); got != 0 {
+		t.Fatalf("closed: RetryAfter() = %s, want 0", got)
+	}
+	breaker.Allow()
+	breaker.Record(false)
+	now = now.Add(12 * time.Second)
+	if got := breaker.RetryAfter(); got != 18*time.Second {
+		t.Fatalf("open: RetryAfter() = %s, want 18s", got)
+	}
+}
@@ -0,0 +1,986 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route auth modes
+const (
+	AuthOptional = "optional" // token validated if present, anonymous requests allowed
+	AuthRequired = "required" // request rejected with 401 without a valid token
+)
+
+// GatewayConfig is the declarative gateway configuration. It is read from a
+// YAML file (JSON is valid YAML, so .json files work too).
+type GatewayConfig struct {
+	Server    ServerConfig              `yaml:"server"`
+	CORS      CORSConfig                `yaml:"cors"`
+	Upstreams map[string]UpstreamConfig `yaml:"upstreams"`
+	Routes    []RouteConfig             `yaml:"routes"`
+	RateLimit RateLimitConfig           `yaml:"rateLimit"`
+	Health    HealthConfig              `yaml:"health"`
+	Proxy     ProxyConfig               `yaml:"proxy"`
+	Cache     CacheConfig               `yaml:"cache"`
+	BFF       BFFConfig                 `yaml:"bff"`
+	GRPC      GRPCConfig                `yaml:"grpc"`
+	OpenAPI   OpenAPIConfig             `yaml:"openapi"`
+	APIKeys   APIKeysConfig             `yaml:"apiKeys"`
+	Audit     AuditConfig               `yaml:"audit"`
+	GraphQL   GraphQLConfig             `yaml:"graphql"`
+}
+
+// ServerConfig controls the listener of the gateway. It is read at startup
+// only; a changed file keeps serving with the previous settings.
+// WriteTimeout bounds writing a response, so it must be longer than every
+// route timeout; streams clear it and use their idle timeout instead.
+// On SIGTERM the gateway reports not ready, waits DrainDelay for load
+// balancers to notice, closes streams and waits up to ShutdownTimeout for
+// in-flight requests.
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout"`
+	DrainDelay        time.Duration `yaml:"drainDelay"`
+	ShutdownTimeout   time.Duration `yaml:"shutdownTimeout"`
+	H2C               bool          `yaml:"h2c"`
+	TLS               *TLSConfig    `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS on the server address with a PEM certificate and
+// key (HTTP/2 is negotiated through ALPN). RedirectAddr, when set, is a
+// plain HTTP listener that redirects to HTTPS; only /health/* is answered
+// there so probes keep working without the certificate.
+type TLSConfig struct {
+	CertFile     string `yaml:"certFile"`
+	KeyFile      string `yaml:"keyFile"`
+	MinVersion   string `yaml:"minVersion"`
+	RedirectAddr string `yaml:"redirectAddr"`
+}
+
+// CORSConfig is the gateway-wide CORS policy. AllowOrigins holds exact
+// origins, "*" or path.Match patterns (https://*.example.com).
+type CORSConfig struct {
+	AllowOrigins     []string `yaml:"allowOrigins"`
+	AllowMethods     []string `yaml:"allowMethods"`
+	AllowHeaders     []string `yaml:"allowHeaders"`
+	ExposeHeaders    []string `yaml:"exposeHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	MaxAge           int      `yaml:"maxAge"`
+}
+
+// Load balancing strategies of an upstream pool
+const (
+	BalancerRoundRobin       = "round-robin"
+	BalancerLeastConnections = "least-connections"
+)
+
+// UpstreamConfig is a backend service the gateway proxies to: a single URL,
+// a static list of Instances or instances found through Discovery. Optional
+// upstreams do not affect gateway readiness when they are down.
+// Timeouts, Retry, CircuitBreaker and HealthCheck override the proxy defaults.
+type UpstreamConfig struct {
+	URL            string                `yaml:"url"`
+	Instances      []string              `yaml:"instances"`
+	Discovery      *DiscoveryConfig      `yaml:"discovery"`
+	Balancer       string                `yaml:"balancer"`
+	HealthPath     string                `yaml:"healthPath"`
+	Optional       bool                  `yaml:"optional"`
+	Timeouts       *TimeoutConfig        `yaml:"timeouts"`
+	Retry          *RetryConfig          `yaml:"retry"`
+	CircuitBreaker *CircuitBreakerConfig `yaml:"circuitBreaker"`
+	HealthCheck    *HealthCheckConfig    `yaml:"healthCheck"`
+}
+
+// DiscoveryConfig finds the instances of an upstream in DNS, either from an
+// SRV record or from every address of Host (host:port), such as a scaled
+// docker compose service. The lookup is repeated every Refresh.
+type DiscoveryConfig struct {
+	SRV     string        `yaml:"srv"`
+	Host    string        `yaml:"host"`
+	Scheme  string        `yaml:"scheme"`
+	Refresh time.Duration `yaml:"refresh"`
+}
+
+// ProxyConfig holds the defaults used by every upstream proxy.
+// MaxBodyBytes is the request body limit of routes without their own.
+type ProxyConfig struct {
+	MaxBodyBytes   int64                `yaml:"maxBodyBytes"`
+	Timeouts       TimeoutConfig        `yaml:"timeouts"`
+	Retry          RetryConfig          `yaml:"retry"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker"`
+	HealthCheck    HealthCheckConfig    `yaml:"healthCheck"`
+}
+
+// TimeoutConfig bounds connecting to an upstream and waiting for its
+// response headers
+type TimeoutConfig struct {
+	Dial     time.Duration `yaml:"dial"`
+	Response time.Duration `yaml:"response"`
+	Idle     time.Duration `yaml:"idle"`
+}
+
+// RetryConfig controls retries of failed GET and HEAD requests. The n-th
+// retry waits Backoff * 2^(n-1).
+type RetryConfig struct {
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+}
+
+// CircuitBreakerConfig controls when an upstream is taken out of rotation
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `yaml:"failureThreshold"`
+	OpenTimeout      time.Duration `yaml:"openTimeout"`
+	HalfOpenRequests int           `yaml:"halfOpenRequests"`
+}
+
+// HealthCheckConfig controls which pool instances receive traffic. An
+// instance is probed on HealthPath every Interval: UnhealthyThreshold failed
+// probes take it out, HealthyThreshold passing probes bring it back.
+// PassiveFailures consecutive 5xx responses or connection errors eject it
+// for EjectionTime.
+type HealthCheckConfig struct {
+	Interval           time.Duration `yaml:"interval"`
+	Timeout            time.Duration `yaml:"timeout"`
+	UnhealthyThreshold int           `yaml:"unhealthyThreshold"`
+	HealthyThreshold   int           `yaml:"healthyThreshold"`
+	PassiveFailures    int           `yaml:"passiveFailures"`
+	EjectionTime       time.Duration `yaml:"ejectionTime"`
+}
+
+// HealthConfig controls the upstream health probes
+type HealthConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// RouteConfig maps a public path prefix to an upstream. If Rewrite is set the
+// prefix is replaced by it before proxying ("" strips the prefix entirely,
+// which is why it is a pointer). Only routes with Stream set accept
+// WebSocket upgrades.
+//
+// Methods restricts the accepted methods (HEAD is implied by GET), MaxBodyBytes
+// overrides the proxy body limit (-1 disables it) and Timeout bounds the
+// whole request, retries included. Streams are not subject to Timeout.
+type RouteConfig struct {
+	Name         string        `yaml:"name"`
+	Prefix       string        `yaml:"prefix"`
+	Upstream     string        `yaml:"upstream"`
+	Rewrite      *string       `yaml:"rewrite"`
+	Auth         string        `yaml:"auth"`
+	Methods      []string      `yaml:"methods"`
+	MaxBodyBytes int64         `yaml:"maxBodyBytes"`
+	Timeout      time.Duration `yaml:"timeout"`
+	Stream       *StreamConfig `yaml:"stream"`
+	Canary       *CanaryConfig `yaml:"canary"`
+}
+
+// CanaryConfig sends part of a route's traffic to an alternate upstream, such
+// as a new version of the service. A request goes to the canary when Header
+// or Cookie says so (true/canary, or false/stable to opt out), when the
+// user's role is one of Roles, or when the user falls into the Weight percent
+// of traffic. The share is assigned by hashing the user id (client IP when
+// anonymous), so a user stays on the same variant and raising Weight only
+// moves users from stable to canary.
+type CanaryConfig struct {
+	Upstream string   `yaml:"upstream"`
+	Weight   float64  `yaml:"weight"`
+	Header   string   `yaml:"header"`
+	Cookie   string   `yaml:"cookie"`
+	Roles    []string `yaml:"roles"`
+}
+
+// StreamConfig controls WebSocket and Server-Sent Events connections of a
+// route. A connection without traffic in either direction for IdleTimeout
+// is closed; MaxConnections and MaxPerClient cap the open connections.
+type StreamConfig struct {
+	IdleTimeout    time.Duration `yaml:"idleTimeout"`
+	MaxConnections int           `yaml:"maxConnections"`
+	MaxPerClient   int           `yaml:"maxPerClient"`
+}
+
+// RateLimitConfig holds the token bucket rules applied per path prefix
+type RateLimitConfig struct {
+	Store             string          `yaml:"store"`
+	TrustForwardedFor bool            `yaml:"trustForwardedFor"`
+	Rules             []RateLimitRule `yaml:"rules"`
+}
+
+// RateLimitRule allows Burst requests at once, refilled at Rate requests per
+// second, per user or per client IP
+type RateLimitRule struct {
+	Name   string  `yaml:"name"`
+	Prefix string  `yaml:"prefix"`
+	Rate   float64 `yaml:"rate"`
+	Burst  int     `yaml:"burst"`
+	Key    string  `yaml:"key"`
+}
+
+// CacheConfig holds the response cache rules. Rules are checked in order and
+// the first one whose pattern matches the path applies.
+type CacheConfig struct {
+	MaxEntries   int         `yaml:"maxEntries"`
+	MaxBodyBytes int64       `yaml:"maxBodyBytes"`
+	Rules        []CacheRule `yaml:"rules"`
+}
+
+// CacheRule caches GET responses of matching paths for at most TTL. Bypass
+// excludes paths that a later, broader pattern would match. A successful
+// non-GET request matching one of InvalidateOn purges the rule's entries.
+type CacheRule struct {
+	Name         string        `yaml:"name"`
+	Pattern      string        `yaml:"pattern"`
+	TTL          time.Duration `yaml:"ttl"`
+	Key          string        `yaml:"key"`
+	Bypass       bool          `yaml:"bypass"`
+	InvalidateOn []string      `yaml:"invalidateOn"`
+}
+
+// BFFConfig controls the aggregation endpoints; Timeout bounds the whole
+// fan-out of one request
+type BFFConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// GraphQLConfig controls the GraphQL endpoint (/graphql). Timeout bounds one
+// query with all its service calls. Tours are loaded over gRPC from
+// ToursBackend (a grpc backend) when it is set, otherwise over REST.
+// MaxDepth and MaxParallelism are applied at startup only.
+type GraphQLConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxDepth       int           `yaml:"maxDepth"`
+	MaxParallelism int           `yaml:"maxParallelism"`
+	ToursBackend   string        `yaml:"toursBackend"`
+}
+
+// GRPCConfig exposes unary gRPC methods as JSON endpoints. Message schemas
+// come from the backends' server reflection, so only the HTTP mapping is
+// configured here.
+type GRPCConfig struct {
+	Backends map[string]GRPCBackendConfig `yaml:"backends"`
+	Routes   []GRPCRouteConfig            `yaml:"routes"`
+}
+
+// GRPCBackendConfig is a gRPC server (host:port, plaintext). Timeout bounds
+// each call and defaults to the proxy response timeout.
+type GRPCBackendConfig struct {
+	Address string        `yaml:"address"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// GRPCRouteConfig maps an HTTP method and path template to an RPC, written as
+// package.Service/Method. {field} segments of the path, query parameters and,
+// except for GET and DELETE, the JSON body fill the request message.
+type GRPCRouteConfig struct {
+	Name    string `yaml:"name"`
+	Method  string `yaml:"method"`
+	Path    string `yaml:"path"`
+	Backend string `yaml:"backend"`
+	RPC     string `yaml:"rpc"`
+	Auth    string `yaml:"auth"`
+}
+
+// OpenAPIConfig controls the unified API documentation: the specs of the
+// Sources are rewritten to gateway paths and merged into one OpenAPI 3
+// document. The result is cached for CacheTTL; Timeout bounds fetching the
+// specs.
+type OpenAPIConfig struct {
+	Title    string                `yaml:"title"`
+	Version  string                `yaml:"version"`
+	CacheTTL time.Duration         `yaml:"cacheTTL"`
+	Timeout  time.Duration         `yaml:"timeout"`
+	Sources  []OpenAPISourceConfig `yaml:"sources"`
+}
+
+// OpenAPISourceConfig is the spec (Swagger 2.0 or OpenAPI 3, JSON) of an
+// upstream, fetched from Path on the upstream or read from File for services
+// that do not serve it
+type OpenAPISourceConfig struct {
+	Upstream string `yaml:"upstream"`
+	Path     string `yaml:"path"`
+	File     string `yaml:"file"`
+}
+
+// APIKeysConfig controls partner API keys, sent in Header. Keys live in
+// Store (memory, or mongo at MONGO_URI in Database) and are reloaded every
+// Refresh, so changes made through another gateway instance apply within that
+// time. Users with AdminRole manage them at /api/gateway/api-keys. Store and
+// Database are read at startup only.
+type APIKeysConfig struct {
+	Store     string        `yaml:"store"`
+	Database  string        `yaml:"database"`
+	Header    string        `yaml:"header"`
+	Refresh   time.Duration `yaml:"refresh"`
+	AdminRole string        `yaml:"adminRole"`
+}
+
+// AuditConfig controls the audit trail of mutating (non-GET) requests.
+// Records are kept in Store (memory, or mongo at MONGO_URI in Database) for
+// Retention; paths under an Exclude prefix are not recorded. Users with
+// AdminRole query them at /api/gateway/audit. Store and Database are read
+// at startup only.
+type AuditConfig struct {
+	Store     string        `yaml:"store"`
+	Database  string        `yaml:"database"`
+	Retention time.Duration `yaml:"retention"`
+	Exclude   []string      `yaml:"exclude"`
+	AdminRole string        `yaml:"adminRole"`
+}
+
+// LoadConfig reads and validates the configuration file
+func LoadConfig(path string) (*GatewayConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(data)
+}
+
+func parseConfig(data []byte) (*GatewayConfig, error) {
+	config := &GatewayConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, fmt.Errorf("parse config: %w", err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Validate checks the configuration and fills in defaults
+func (c *GatewayConfig) Validate() error {
+	if len(c.Upstreams) == 0 {
+		return fmt.Errorf("config: at least one upstream is required")
+	}
+	if c.Health.Timeout <= 0 {
+		c.Health.Timeout = 2 * time.Second
+	}
+	c.Proxy.defaults(c.Health.Timeout)
+	for name, upstream := range c.Upstreams {
+		if err := upstream.validateInstances(); err != nil {
+			return fmt.Errorf("config: upstream %q: %w", name, err)
+		}
+		if upstream.HealthPath == "" {
+			upstream.HealthPath = "/health"
+		}
+		if !strings.HasPrefix(upstream.HealthPath, "/") {
+			return fmt.Errorf("config: upstream %q healthPath must start with /", name)
+		}
+		if err := upstream.applyProxyDefaults(c.Proxy); err != nil {
+			return fmt.Errorf("config: upstream %q: %w", name, err)
+		}
+		c.Upstreams[name] = upstream
+	}
+	if err := c.CORS.validate(); err != nil {
+		return err
+	}
+	if c.BFF.Timeout <= 0 {
+		c.BFF.Timeout = 3 * time.Second
+	}
+
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("config: at least one route is required")
+	}
+	names := map[string]bool{}
+	prefixes := map[string]bool{}
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if route.Name == "" {
+			return fmt.Errorf("config: route #%d has no name", i+1)
+		}
+		if names[route.Name] {
+			return fmt.Errorf("config: duplicate route name %q", route.Name)
+		}
+		names[route.Name] = true
+
+		route.Prefix = strings.TrimSuffix(route.Prefix, "/")
+		if !strings.HasPrefix(route.Prefix, "/") {
+			return fmt.Errorf("config: route %q prefix must start with /", route.Name)
+		}
+		if prefixes[route.Prefix] {
+			return fmt.Errorf("config: duplicate route prefix %q", route.Prefix)
+		}
+		prefixes[route.Prefix] = true
+
+		if _, ok := c.Upstreams[route.Upstream]; !ok {
+			return fmt.Errorf("config: route %q references unknown upstream %q", route.Name, route.Upstream)
+		}
+		if route.Rewrite != nil && *route.Rewrite != "" && !strings.HasPrefix(*route.Rewrite, "/") {
+			return fmt.Errorf("config: route %q rewrite must start with /", route.Name)
+		}
+
+		switch route.Auth {
+		case "":
+			route.Auth = AuthOptional
+		case AuthOptional, AuthRequired:
+		default:
+			return fmt.Errorf("config: route %q has unknown auth mode %q", route.Name, route.Auth)
+		}
+
+		if err := route.validatePolicy(c.Proxy.MaxBodyBytes); err != nil {
+			return err
+		}
+		if route.Stream != nil {
+			route.Stream.defaults()
+		}
+		if canary := route.Canary; canary != nil {
+			if _, ok := c.Upstreams[canary.Upstream]; !ok {
+				return fmt.Errorf("config: route %q canary references unknown upstream %q", route.Name, canary.Upstream)
+			}
+			if canary.Upstream == route.Upstream {
+				return fmt.Errorf("config: route %q canary upstream must differ from the route upstream", route.Name)
+			}
+			if canary.Weight < 0 || canary.Weight > 100 {
+				return fmt.Errorf("config: route %q canary weight must be between 0 and 100", route.Name)
+			}
+		}
+	}
+
+	if err := c.GRPC.validate(names, c.Proxy.Timeouts.Response); err != nil {
+		return err
+	}
+	if err := c.RateLimit.validate(); err != nil {
+		return err
+	}
+	if err := c.OpenAPI.validate(c.Upstreams); err != nil {
+		return err
+	}
+	if err := c.APIKeys.validate(); err != nil {
+		return err
+	}
+	if err := c.GraphQL.validate(c.GRPC.Backends); err != nil {
+		return err
+	}
+	if err := c.Audit.validate(); err != nil {
+		return err
+	}
+	if err := c.Server.validate(c.Routes); err != nil {
+		return err
+	}
+	return c.Cache.validate()
+}
+
+func (p *ProxyConfig) defaults(healthTimeout time.Duration) {
+	if p.MaxBodyBytes == 0 {
+		p.MaxBodyBytes = 1 << 20
+	}
+	p.Timeouts.fill(TimeoutConfig{Dial: 2 * time.Second, Response: 10 * time.Second, Idle: 90 * time.Second})
+	if p.Retry.Backoff <= 0 {
+		p.Retry.Backoff = 100 * time.Millisecond
+	}
+	p.CircuitBreaker.fill(CircuitBreakerConfig{FailureThreshold: 5, OpenTimeout: 30 * time.Second, HalfOpenRequests: 1})
+	p.HealthCheck.fill(HealthCheckConfig{
+		Interval:           10 * time.Second,
+		Timeout:            healthTimeout,
+		UnhealthyThreshold: 2,
+		HealthyThreshold:   2,
+		PassiveFailures:    5,
+		EjectionTime:       30 * time.Second,
+	})
+}
+
+// validateInstances checks that exactly one instance source is configured.
+// A single URL becomes a pool of one; instances may differ only in scheme
+// and host.
+func (u *UpstreamConfig) validateInstances() error {
+	sources := 0
+	for _, set := range []bool{u.URL != "", len(u.Instances) > 0, u.Discovery != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of url, instances or discovery is required")
+	}
+	if u.URL != "" {
+		u.Instances = []string{u.URL}
+	}
+
+	basePath := ""
+	for i, instance := range u.Instances {
+		parsed, err := url.Parse(instance)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid url %q", instance)
+		}
+		u.Instances[i] = strings.TrimSuffix(instance, "/")
+		instancePath := strings.TrimSuffix(parsed.Path, "/")
+		if i == 0 {
+			basePath = instancePath
+		} else if instancePath != basePath {
+			return fmt.Errorf("instances must share the same path")
+		}
+	}
+
+	if d := u.Discovery; d != nil {
+		if (d.SRV == "") == (d.Host == "") {
+			return fmt.Errorf("discovery needs exactly one of srv or host")
+		}
+		if d.Host != "" {
+			if _, _, err := net.SplitHostPort(d.Host); err != nil {
+				return fmt.Errorf("discovery host must be host:port")
+			}
+		}
+		switch d.Scheme {
+		case "":
+			d.Scheme = "http"
+		case "http", "https":
+		default:
+			return fmt.Errorf("discovery has unknown scheme %q", d.Scheme)
+		}
+		if d.Refresh <= 0 {
+			d.Refresh = 30 * time.Second
+		}
+	}
+
+	switch u.Balancer {
+	case "":
+		u.Balancer = BalancerRoundRobin
+	case BalancerRoundRobin, BalancerLeastConnections:
+	default:
+		return fmt.Errorf("unknown balancer %q", u.Balancer)
+	}
+	return nil
+}
+
+// applyProxyDefaults fills every setting the upstream does not override
+func (u *UpstreamConfig) applyProxyDefaults(defaults ProxyConfig) error {
+	if u.Timeouts == nil {
+		u.Timeouts = &TimeoutConfig{}
+	}
+	u.Timeouts.fill(defaults.Timeouts)
+
+	if u.Retry == nil {
+		retry := defaults.Retry
+		u.Retry = &retry
+	}
+	if u.Retry.Backoff <= 0 {
+		u.Retry.Backoff = defaults.Retry.Backoff
+	}
+	if u.Retry.Attempts < 0 || u.Retry.Attempts > 5 {
+		return fmt.Errorf("retry attempts must be between 0 and 5")
+	}
+
+	if u.CircuitBreaker == nil {
+		u.CircuitBreaker = &CircuitBreakerConfig{}
+	}
+	u.CircuitBreaker.fill(defaults.CircuitBreaker)
+
+	if u.HealthCheck == nil {
+		u.HealthCheck = &HealthCheckConfig{}
+	}
+	u.HealthCheck.fill(defaults.HealthCheck)
+	return nil
+}
+
+func (t *TimeoutConfig) fill(defaults TimeoutConfig) {
+	if t.Dial <= 0 {
+		t.Dial = defaults.Dial
+	}
+	if t.Response <= 0 {
+		t.Response = defaults.Response
+	}
+	if t.Idle <= 0 {
+		t.Idle = defaults.Idle
+	}
+}
+
+func (b *CircuitBreakerConfig) fill(defaults CircuitBreakerConfig) {
+	if b.FailureThreshold <= 0 {
+		b.FailureThreshold = defaults.FailureThreshold
+	}
+	if b.OpenTimeout <= 0 {
+		b.OpenTimeout = defaults.OpenTimeout
+	}
+	if b.HalfOpenRequests <= 0 {
+		b.HalfOpenRequests = defaults.HalfOpenRequests
+	}
+}
+
+func (h *HealthCheckConfig) fill(defaults HealthCheckConfig) {
+	if h.Interval <= 0 {
+		h.Interval = defaults.Interval
+	}
+	if h.Timeout <= 0 {
+		h.Timeout = defaults.Timeout
+	}
+	if h.UnhealthyThreshold <= 0 {
+		h.UnhealthyThreshold = defaults.UnhealthyThreshold
+	}
+	if h.HealthyThreshold <= 0 {
+		h.HealthyThreshold = defaults.HealthyThreshold
+	}
+	if h.PassiveFailures <= 0 {
+		h.PassiveFailures = defaults.PassiveFailures
+	}
+	if h.EjectionTime <= 0 {
+		h.EjectionTime = defaults.EjectionTime
+	}
+}
+
+// validatePolicy normalizes the methods, body limit and timeout of a route
+func (r *RouteConfig) validatePolicy(defaultMaxBodyBytes int64) error {
+	for i, method := range r.Methods {
+		method = strings.ToUpper(method)
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("config: route %q has unsupported method %q", r.Name, method)
+		}
+		r.Methods[i] = method
+	}
+	if r.MaxBodyBytes == 0 {
+		r.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if r.MaxBodyBytes < -1 {
+		return fmt.Errorf("config: route %q maxBodyBytes must be positive or -1", r.Name)
+	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("config: route %q timeout must not be negative", r.Name)
+	}
+	return nil
+}
+
+func (s *StreamConfig) defaults() {
+	if s.IdleTimeout <= 0 {
+		s.IdleTimeout = 60 * time.Second
+	}
+	if s.MaxConnections <= 0 {
+		s.MaxConnections = 1000
+	}
+	if s.MaxPerClient <= 0 {
+		s.MaxPerClient = 10
+	}
+}
+
+func (c *CORSConfig) validate() error {
+	for _, origin := range c.AllowOrigins {
+		if _, err := path.Match(origin, ""); err != nil {
+			return fmt.Errorf("config: invalid cors origin pattern %q", origin)
+		}
+		// A wildcard would let any matching site make credentialed requests
+		// on behalf of the signed-in user
+		if c.AllowCredentials && strings.ContainsAny(origin, `*?[\`) {
+			return fmt.Errorf("config: cors origin %q must be exact when allowCredentials is set", origin)
+		}
+	}
+	if len(c.AllowMethods) == 0 {
+		c.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+	}
+	if len(c.AllowHeaders) == 0 {
+		c.AllowHeaders = []string{"Content-Type", "Authorization"}
+	}
+	return nil
+}
+
+// validate checks the gRPC backends and routes; route names share the
+// namespace of the proxy routes because both label the request metrics
+func (c *GRPCConfig) validate(names map[string]bool, defaultTimeout time.Duration) error {
+	for name, backend := range c.Backends {
+		if _, _, err := net.SplitHostPort(backend.Address); err != nil {
+			return fmt.Errorf("config: grpc backend %q has invalid address %q", name, backend.Address)
+		}
+		if backend.Timeout <= 0 {
+			backend.Timeout = defaultTimeout
+		}
+		c.Backends[name] = backend
+	}
+
+	for i := range c.Routes {
+		route := &c.Routes[i]
+		if route.Name == "" {
+			return fmt.Errorf("config: grpc route #%d has no name", i+1)
+		}
+		if names[route.Name] {
+			return fmt.Errorf("config: duplicate route name %q", route.Name)
+		}
+		names[route.Name] = true
+
+		route.Method = strings.ToUpper(route.Method)
+		switch route.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("config: grpc route %q has unsupported method %q", route.Name, route.Method)
+		}
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("config: grpc route %q path must start with /", route.Name)
+		}
+		if _, ok := c.Backends[route.Backend]; !ok {
+			return fmt.Errorf("config: grpc route %q references unknown backend %q", route.Name, route.Backend)
+		}
+		if service, method, ok := strings.Cut(route.RPC, "/"); !ok || service == "" || method == "" {
+			return fmt.Errorf("config: grpc route %q rpc must be package.Service/Method", route.Name)
+		}
+
+		switch route.Auth {
+		case "":
+			route.Auth = AuthOptional
+		case AuthOptional, AuthRequired:
+		default:
+			return fmt.Errorf("config: grpc route %q has unknown auth mode %q", route.Name, route.Auth)
+		}
+	}
+	return nil
+}
+
+func (c *OpenAPIConfig) validate(upstreams map[string]UpstreamConfig) error {
+	if c.Title == "" {
+		c.Title = "API"
+	}
+	if c.Version == "" {
+		c.Version = "1.0"
+	}
+	if c.CacheTTL <= 0 {
+		c.CacheTTL = time.Minute
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	for i, source := range c.Sources {
+		if _, ok := upstreams[source.Upstream]; !ok {
+			return fmt.Errorf("config: openapi source #%d references unknown upstream %q", i+1, source.Upstream)
+		}
+		if (source.Path == "") == (source.File == "") {
+			return fmt.Errorf("config: openapi source %q needs exactly one of path and file", source.Upstream)
+		}
+		if source.Path != "" && !strings.HasPrefix(source.Path, "/") {
+			return fmt.Errorf("config: openapi source %q path must start with /", source.Upstream)
+		}
+	}
+	return nil
+}
+
+func (c *APIKeysConfig) validate() error {
+	switch c.Store {
+	case "":
+		c.Store = APIKeyStoreMemory
+	case APIKeyStoreMemory, APIKeyStoreMongo:
+	default:
+		return fmt.Errorf("config: unknown apiKeys store %q", c.Store)
+	}
+	if c.Database == "" {
+		c.Database = "api_gateway"
+	}
+	if c.Header == "" {
+		c.Header = "X-API-Key"
+	}
+	if c.Refresh <= 0 {
+		c.Refresh = 30 * time.Second
+	}
+	if c.AdminRole == "" {
+		c.AdminRole = "administrator"
+	}
+	return nil
+}
+
+func (c *GraphQLConfig) validate(backends map[string]GRPCBackendConfig) error {
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = 8
+	}
+	if c.MaxParallelism <= 0 {
+		c.MaxParallelism = 50
+	}
+	if _, ok := backends[c.ToursBackend]; c.ToursBackend != "" && !ok {
+		return fmt.Errorf("config: graphql toursBackend references unknown grpc backend %q", c.ToursBackend)
+	}
+	return nil
+}
+
+func (c *AuditConfig) validate() error {
+	switch c.Store {
+	case "":
+		c.Store = AuditStoreMemory
+	case AuditStoreMemory, AuditStoreMongo:
+	default:
+		return fmt.Errorf("config: unknown audit store %q", c.Store)
+	}
+	if c.Database == "" {
+		c.Database = "api_gateway"
+	}
+	if c.Retention <= 0 {
+		c.Retention = 90 * 24 * time.Hour
+	}
+	for i, prefix := range c.Exclude {
+		if !strings.HasPrefix(prefix, "/") {
+			return fmt.Errorf("config: audit exclude %q must start with /", prefix)
+		}
+		c.Exclude[i] = strings.TrimSuffix(prefix, "/")
+	}
+	if c.AdminRole == "" {
+		c.AdminRole = "administrator"
+	}
+	return nil
+}
+
+func (c *ServerConfig) validate(routes []RouteConfig) error {
+	if c.Addr == "" {
+		c.Addr = ":8080"
+	}
+	if c.ReadHeaderTimeout <= 0 {
+		c.ReadHeaderTimeout = 10 * time.Second
+	}
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 || c.IdleTimeout < 0 || c.DrainDelay < 0 {
+		return fmt.Errorf("config: server timeouts must not be negative")
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 120 * time.Second
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = 30 * time.Second
+	}
+	if c.WriteTimeout > 0 {
+		for _, route := range routes {
+			if route.Timeout >= c.WriteTimeout {
+				return fmt.Errorf("config: route %q timeout must be shorter than server writeTimeout %s", route.Name, c.WriteTimeout)
+			}
+		}
+	}
+
+	if c.TLS == nil {
+		return nil
+	}
+	if c.TLS.CertFile == "" || c.TLS.KeyFile == "" {
+		return fmt.Errorf("config: server tls needs certFile and keyFile")
+	}
+	switch c.TLS.MinVersion {
+	case "":
+		c.TLS.MinVersion = "1.2"
+	case "1.2", "1.3":
+	default:
+		return fmt.Errorf("config: server tls minVersion must be 1.2 or 1.3")
+	}
+	if c.TLS.RedirectAddr != "" && c.TLS.RedirectAddr == c.Addr {
+		return fmt.Errorf("config: server tls redirectAddr must differ from addr")
+	}
+	return nil
+}
+
+func (c *RateLimitConfig) validate() error {
+	switch c.Store {
+	case "":
+		c.Store = RateLimitStoreMemory
+	case RateLimitStoreMemory:
+	default:
+		return fmt.Errorf("config: unknown rate limit store %q", c.Store)
+	}
+
+	names := map[string]bool{}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("config: rate limit rule #%d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("config: duplicate rate limit rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		rule.Prefix = strings.TrimSuffix(rule.Prefix, "/")
+		if !strings.HasPrefix(rule.Prefix, "/") {
+			return fmt.Errorf("config: rate limit rule %q prefix must start with /", rule.Name)
+		}
+		if rule.Rate <= 0 || rule.Burst < 1 {
+			return fmt.Errorf("config: rate limit rule %q needs rate > 0 and burst >= 1", rule.Name)
+		}
+
+		switch rule.Key {
+		case "":
+			rule.Key = RateLimitKeyUser
+		case RateLimitKeyUser, RateLimitKeyIP:
+		default:
+			return fmt.Errorf("config: rate limit rule %q has unknown key %q", rule.Name, rule.Key)
+		}
+	}
+	return nil
+}
+
+func (c *CacheConfig) validate() error {
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = 1000
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 1 << 20
+	}
+
+	names := map[string]bool{}
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("config: cache rule #%d has no name", i+1)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("config: duplicate cache rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if !strings.HasPrefix(rule.Pattern, "/") {
+			return fmt.Errorf("config: cache rule %q pattern must start with /", rule.Name)
+		}
+		for _, pattern := range rule.InvalidateOn {
+			if !strings.HasPrefix(pattern, "/") {
+				return fmt.Errorf("config: cache rule %q invalidateOn pattern must start with /", rule.Name)
+			}
+		}
+		if !rule.Bypass && rule.TTL <= 0 {
+			return fmt.Errorf("config: cache rule %q needs ttl > 0", rule.Name)
+		}
+
+		switch rule.Key {
+		case "":
+			rule.Key = CacheKeyPublic
+		case CacheKeyPublic, CacheKeyUser:
+		default:
+			return fmt.Errorf("config: cache rule %q has unknown key %q", rule.Name, rule.Key)
+		}
+	}
+	return nil
+}
+
+// watchConfig polls the configuration file and calls apply whenever its
+// content changes. Invalid files are logged and ignored so a typo never takes
+// the running gateway down.
+func watchConfig(path string, interval time.Duration, apply func(*GatewayConfig)) {
+	lastHash := fileHash(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		hash := fileHash(path)
+		if hash == lastHash {
+			continue
+		}
+		lastHash = hash
+
+		config, err := LoadConfig(path)
+		if err != nil {
+			log.Printf("[CONFIG] Reload of %s failed, keeping previous config: %v", path, err)
+			continue
+		}
+		apply(config)
+		log.Printf("[CONFIG] Reloaded %s (%d routes)", path, len(config.Routes))
+	}
+}
+
+func fileHash(path string) [32]byte {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [32]byte{}
+	}
+	return sha256.Sum256(data)
+}
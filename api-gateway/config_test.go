@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestCORSConfigValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{"exact origin with credentials", []string{"http://localhost:4200"}, true, false},
+		{"any origin without credentials", []string{"*"}, false, false},
+		{"pattern without credentials", []string{"https://*.example.com"}, false, false},
+		{"any origin with credentials", []string{"*"}, true, true},
+		{"pattern with credentials", []string{"http://localhost:4200", "https://*.example.com"}, true, true},
+		{"port pattern with credentials", []string{"http://localhost:*"}, true, true},
+		{"character class with credentials", []string{"https://app[0-9].example.com"}, true, true},
+		{"malformed pattern", []string{"https://[.example.com"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cors := CORSConfig{AllowOrigins: tt.origins, AllowCredentials: tt.credentials}
+			if err := cors.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCORSConfigValidateShippedConfig(t *testing.T) {
+	if _, err := LoadConfig("gateway.yaml"); err != nil {
+		t.Fatalf("gateway.yaml: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var graphQLSchema string
+
+// graphQLMaxBody bounds a query document with its variables
+const graphQLMaxBody = 1 << 20
+
+// GraphQL serves the schema in schema.graphql over the tours, blog,
+// stakeholders and follower services. Per-request data loaders batch and
+// deduplicate the service calls, so a list of tours does not load the same
+// author once per tour.
+type GraphQL struct {
+	gateway *Gateway
+	schema  *graphql.Schema
+}
+
+func NewGraphQL(gateway *Gateway) *GraphQL {
+	config := gateway.Config().GraphQL
+	schema := graphql.MustParseSchema(graphQLSchema, &queryResolver{},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(config.MaxDepth),
+		graphql.MaxParallelism(config.MaxParallelism),
+	)
+	return &GraphQL{gateway: gateway, schema: schema}
+}
+
+type graphQLParams struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// ServeHTTP executes a query sent as JSON in a POST body, or in the query,
+// operationName and variables parameters of a GET request. Like any GraphQL
+// server it answers 200 with partial data when some fields failed.
+func (q *GraphQL) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var params graphQLParams
+	if r.Method == http.MethodGet {
+		values := r.URL.Query()
+		params.Query = values.Get("query")
+		params.OperationName = values.Get("operationName")
+		if variables := values.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &params.Variables); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "variables must be a JSON object")
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, graphQLMaxBody)).Decode(&params); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if params.Query == "" {
+		writeJSONError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), q.gateway.Config().GraphQL.Timeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, graphQLLoadersKey{}, newGraphQLLoaders(q.gateway, r))
+
+	response := q.schema.Exec(ctx, params.Query, params.OperationName, params.Variables)
+	for _, err := range response.Errors {
+		if err.ResolverError != nil {
+			slog.Warn("graphql resolver failed",
+				"request_id", RequestIDFromContext(r.Context()),
+				"path", fmt.Sprint(err.Path),
+				"error", err.Message,
+			)
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
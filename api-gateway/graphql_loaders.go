@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graph-gophers/dataloader/v7"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// graphQLBatchWait is how long a loader collects keys before calling the
+	// service; fields resolved in parallel land in the same batch
+	graphQLBatchWait = 2 * time.Millisecond
+	// maxProfilesPerCall matches the limit of the stakeholders batch endpoint
+	maxProfilesPerCall = 100
+	// getTourRPC loads a tour from the tours gRPC service
+	getTourRPC = "tours.ToursService/GetTourById"
+)
+
+type graphQLLoadersKey struct{}
+
+// graphQLLoaders batch, deduplicate and cache the service calls of one
+// request. Calls carry the caller's token and signed identity, like the BFF.
+type graphQLLoaders struct {
+	gateway  *Gateway
+	request  *http.Request
+	identity *Identity
+
+	users       *dataloader.Loader[string, *userProfile]
+	tours       *dataloader.Loader[string, *tourData] // over gRPC when configured
+	tourDetails *dataloader.Loader[string, *tourData] // REST, with reviews and dates
+	blogs       *dataloader.Loader[string, *blogData]
+	followers   *dataloader.Loader[string, []string]
+	following   *dataloader.Loader[string, []string]
+
+	toursOverGRPC bool
+
+	followedOnce sync.Once
+	followed     map[string]bool
+	followedErr  error
+}
+
+func loadersFrom(ctx context.Context) *graphQLLoaders {
+	return ctx.Value(graphQLLoadersKey{}).(*graphQLLoaders)
+}
+
+func newGraphQLLoaders(gateway *Gateway, r *http.Request) *graphQLLoaders {
+	l := &graphQLLoaders{gateway: gateway, request: r}
+	l.identity, _ = IdentityFromContext(r.Context())
+	l.toursOverGRPC = gateway.Config().GraphQL.ToursBackend != ""
+
+	l.users = newGraphQLLoader("users", l.loadProfiles)
+	l.tours = newGraphQLLoader("tours", eachKey(func(ctx context.Context, id string) (*tourData, error) {
+		if l.toursOverGRPC {
+			return l.loadTourGRPC(ctx, id)
+		}
+		return getResource[tourData](ctx, l, bffToursUpstream, "/api/tours/"+url.PathEscape(id))
+	}))
+	l.tourDetails = newGraphQLLoader("tour_details", eachKey(func(ctx context.Context, id string) (*tourData, error) {
+		return getResource[tourData](ctx, l, bffToursUpstream, "/api/tours/"+url.PathEscape(id))
+	}))
+	l.blogs = newGraphQLLoader("blogs", eachKey(func(ctx context.Context, id string) (*blogData, error) {
+		return getResource[blogData](ctx, l, bffBlogUpstream, "/api/blogs/"+url.PathEscape(id))
+	}))
+	l.followers = newGraphQLLoader("followers", eachKey(func(ctx context.Context, username string) ([]string, error) {
+		var response struct {
+			Followers []followerUser `json:"followers"`
+		}
+		err := l.getInto(ctx, bffFollowerUpstream, "/api/users/"+url.PathEscape(username)+"/followers", &response)
+		return followerNames(response.Followers), err
+	}))
+	l.following = newGraphQLLoader("following", eachKey(func(ctx context.Context, username string) ([]string, error) {
+		var response struct {
+			Following []followerUser `json:"following"`
+		}
+		err := l.getInto(ctx, bffFollowerUpstream, "/api/users/"+url.PathEscape(username)+"/following", &response)
+		return followerNames(response.Following), err
+	}))
+	return l
+}
+
+func newGraphQLLoader[V any](name string, batch dataloader.BatchFunc[string, V]) *dataloader.Loader[string, V] {
+	return dataloader.NewBatchedLoader(func(ctx context.Context, keys []string) []*dataloader.Result[V] {
+		graphQLBatchSize.WithLabelValues(name).Observe(float64(len(keys)))
+		return batch(ctx, keys)
+	}, dataloader.WithWait[string, V](graphQLBatchWait))
+}
+
+// eachKey makes a batch function for services without a batch endpoint: the
+// keys of a batch are loaded concurrently, and the loader still removes
+// duplicates
+func eachKey[V any](load func(ctx context.Context, key string) (V, error)) dataloader.BatchFunc[string, V] {
+	return func(ctx context.Context, keys []string) []*dataloader.Result[V] {
+		results := make([]*dataloader.Result[V], len(keys))
+		var wg sync.WaitGroup
+		for i, key := range keys {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				value, err := load(ctx, key)
+				results[i] = &dataloader.Result[V]{Data: value, Error: err}
+			}()
+		}
+		wg.Wait()
+		return results
+	}
+}
+
+// loadProfiles loads public profiles through the stakeholders batch
+// endpoint, maxProfilesPerCall at a time. Unknown users load as nil.
+func (l *graphQLLoaders) loadProfiles(ctx context.Context, usernames []string) []*dataloader.Result[*userProfile] {
+	profiles := map[string]*userProfile{}
+	var err error
+	for start := 0; start < len(usernames) && err == nil; start += maxProfilesPerCall {
+		chunk := usernames[start:min(start+maxProfilesPerCall, len(usernames))]
+		var list []*userProfile
+		err = l.getInto(ctx, bffStakeholdersUpstream, "/api/stakeholders/users?usernames="+url.QueryEscape(strings.Join(chunk, ",")), &list)
+		for _, profile := range list {
+			profiles[profile.Username] = profile
+		}
+	}
+	results := make([]*dataloader.Result[*userProfile], len(usernames))
+	for i, username := range usernames {
+		results[i] = &dataloader.Result[*userProfile]{Data: profiles[username], Error: err}
+	}
+	return results
+}
+
+// loadTourGRPC calls GetTourById on the configured tours backend, through
+// the same client and circuit breaker as the transcoded gRPC routes. The
+// reply has no reviews or dates.
+func (l *graphQLLoaders) loadTourGRPC(ctx context.Context, id string) (*tourData, error) {
+	config := l.gateway.Config()
+	name := config.GraphQL.ToursBackend
+	backend := config.GRPC.Backends[name]
+	ctx, cancel := context.WithTimeout(ctx, backend.Timeout)
+	defer cancel()
+
+	client, err := l.gateway.grpcClient(backend.Address)
+	if err != nil {
+		return nil, err
+	}
+	method, err := client.method(ctx, getTourRPC)
+	if err != nil {
+		upstreamErrors.WithLabelValues(name, "schema").Inc()
+		return nil, fmt.Errorf("%s is unavailable", name)
+	}
+	request := dynamicpb.NewMessage(method.Input())
+	if err := setField(request, "tourId", []string{id}); err != nil {
+		return nil, err
+	}
+
+	breaker := l.gateway.breaker("grpc:"+name, config.Proxy.CircuitBreaker)
+	if err := breaker.Allow(); err != nil {
+		return nil, fmt.Errorf("%s is temporarily disabled", name)
+	}
+	response := dynamicpb.NewMessage(method.Output())
+	err = client.conn.Invoke(outgoingContext(ctx, l.request, "/"+getTourRPC), "/"+getTourRPC, request, response)
+	code := status.Code(err)
+	breaker.Record(code != codes.Unavailable && code != codes.DeadlineExceeded)
+	switch code {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return nil, nil
+	default:
+		upstreamErrors.WithLabelValues(name, strings.ToLower(code.String())).Inc()
+		return nil, fmt.Errorf("%s: %s", name, status.Convert(err).Message())
+	}
+
+	body, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var tour tourData
+	if err := json.Unmarshal(body, &tour); err != nil {
+		return nil, err
+	}
+	return &tour, nil
+}
+
+// getResource loads one resource; a 404 loads as nil
+func getResource[T any](ctx context.Context, l *graphQLLoaders, upstreamName, path string) (*T, error) {
+	var value T
+	err := l.getInto(ctx, upstreamName, path, &value)
+	if errors.Is(err, errNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func (l *graphQLLoaders) getInto(ctx context.Context, upstreamName, path string, target interface{}) error {
+	body, err := l.gateway.getJSON(ctx, l.request, upstreamName, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("%s returned an unexpected response", upstreamName)
+	}
+	return nil
+}
+
+// followedByCaller returns the users the caller follows, loaded once per
+// request
+func (l *graphQLLoaders) followedByCaller(ctx context.Context) (map[string]bool, error) {
+	l.followedOnce.Do(func() {
+		var response struct {
+			UserIds []string `json:"userIds"`
+		}
+		l.followedErr = l.getInto(ctx, bffFollowerUpstream, "/api/followed-users", &response)
+		l.followed = map[string]bool{}
+		for _, id := range response.UserIds {
+			l.followed[id] = true
+		}
+	})
+	return l.followed, l.followedErr
+}
+
+// followerUser is a user as the follower service lists it; its id is the
+// username
+type followerUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+func followerNames(users []followerUser) []string {
+	names := make([]string, len(users))
+	for i, user := range users {
+		names[i] = user.ID
+		if names[i] == "" {
+			names[i] = user.Username
+		}
+	}
+	return names
+}
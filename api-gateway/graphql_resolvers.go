@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+// Service payloads as the GraphQL resolvers read them. The tours gRPC reply
+// uses the same JSON names as the REST API; blogs have no JSON tags in the
+// blog service and keep their Go field names.
+type tourData struct {
+	ID            string          `json:"id"`
+	AuthorID      string          `json:"authorId"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Difficulty    int32           `json:"difficulty"`
+	Tags          []string        `json:"tags"`
+	Status        string          `json:"status"`
+	Price         float64         `json:"price"`
+	Distance      float64         `json:"distance"`
+	KeyPoints     []keyPointData  `json:"keyPoints"`
+	TransportInfo []transportData `json:"transportInfo"`
+	Reviews       []reviewData    `json:"reviews"`
+	PublishedAt   *time.Time      `json:"publishedAt"`
+	ArchivedAt    *time.Time      `json:"archivedAt"`
+}
+
+type keyPointData struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	ImageURL    string  `json:"imageUrl"`
+	Order       int32   `json:"order"`
+}
+
+type transportData struct {
+	Type          string `json:"type"`
+	TimeInMinutes int32  `json:"timeInMinutes"`
+}
+
+type reviewData struct {
+	ID          string    `json:"id"`
+	Rating      int32     `json:"rating"`
+	Comment     string    `json:"comment"`
+	TouristID   string    `json:"touristId"`
+	VisitDate   time.Time `json:"visitDate"`
+	CommentDate time.Time `json:"commentDate"`
+	ImageURLs   []string  `json:"imageUrls"`
+}
+
+type blogData struct {
+	ID        string
+	Title     string
+	Content   string
+	AuthorID  string
+	CreatedAt time.Time
+	Images    []string
+	Comments  []commentData
+	Likes     []struct{ UserID string }
+}
+
+type commentData struct {
+	ID            string
+	AuthorID      string
+	Text          string
+	CreatedAt     time.Time
+	LastUpdatedAt time.Time
+}
+
+type userProfile struct {
+	Username       string `json:"username"`
+	FirstName      string `json:"firstName"`
+	LastName       string `json:"lastName"`
+	ProfilePicture string `json:"profilePicture"`
+	Biography      string `json:"biography"`
+	Motto          string `json:"motto"`
+}
+
+// queryResolver resolves the fields of Query. Resolvers get the loaders and
+// the caller from the context, as one schema serves every request.
+type queryResolver struct{}
+
+func (q *queryResolver) Tour(ctx context.Context, args struct{ ID graphql.ID }) (*tourResolver, error) {
+	l := loadersFrom(ctx)
+	tour, err := l.tours.Load(ctx, string(args.ID))()
+	if err != nil || tour == nil {
+		return nil, err
+	}
+	return &tourResolver{data: tour, full: !l.toursOverGRPC}, nil
+}
+
+// tourListArgs are the paging and sorting arguments of tour lists
+type tourListArgs struct {
+	Sort     *string
+	Page     int32
+	PageSize int32
+}
+
+// tourSortParams maps TourSort values to the sort parameter of the tours service
+var tourSortParams = map[string]string{
+	"PUBLISHED_AT": "publishedAt",
+	"PRICE":        "price",
+	"RATING":       "rating",
+	"DISTANCE":     "distance",
+}
+
+// path adds the arguments to a tours list path; the tours service validates
+// the page range
+func (a tourListArgs) path(base string) string {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(int(a.Page)))
+	params.Set("pageSize", strconv.Itoa(int(a.PageSize)))
+	if a.Sort != nil {
+		params.Set("sort", tourSortParams[*a.Sort])
+	}
+	return base + "?" + params.Encode()
+}
+
+func (q *queryResolver) Tours(ctx context.Context, args struct {
+	Status string
+	tourListArgs
+}) ([]*tourResolver, error) {
+	switch args.Status {
+	case "PUBLISHED":
+		return loadTours(ctx, args.path("/api/tours/published"))
+	case "ARCHIVED":
+		return loadTours(ctx, args.path("/api/tours/archived"))
+	default:
+		return nil, errors.New("drafts are only visible to their author, use myTours")
+	}
+}
+
+func (q *queryResolver) MyTours(ctx context.Context, args tourListArgs) ([]*tourResolver, error) {
+	if loadersFrom(ctx).identity == nil {
+		return nil, errors.New("myTours requires authentication")
+	}
+	return loadTours(ctx, args.path("/api/tours/my-tours"))
+}
+
+// loadTours loads a list from the tours REST API and primes the loaders, so
+// tour(id) in the same query does not call the service again
+func loadTours(ctx context.Context, path string) ([]*tourResolver, error) {
+	l := loadersFrom(ctx)
+	var tours []*tourData
+	if err := l.getInto(ctx, bffToursUpstream, path, &tours); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*tourResolver, len(tours))
+	for i, tour := range tours {
+		l.tourDetails.Prime(ctx, tour.ID, tour)
+		if !l.toursOverGRPC {
+			l.tours.Prime(ctx, tour.ID, tour)
+		}
+		resolvers[i] = &tourResolver{data: tour, full: true}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) Blog(ctx context.Context, args struct{ ID graphql.ID }) (*blogResolver, error) {
+	blog, err := loadersFrom(ctx).blogs.Load(ctx, string(args.ID))()
+	if err != nil || blog == nil {
+		return nil, err
+	}
+	return &blogResolver{data: blog}, nil
+}
+
+func (q *queryResolver) Blogs(ctx context.Context) ([]*blogResolver, error) {
+	return loadBlogs(ctx, "/api/blogs")
+}
+
+func (q *queryResolver) Feed(ctx context.Context) ([]*blogResolver, error) {
+	return loadBlogs(ctx, "/api/blogs/following")
+}
+
+func loadBlogs(ctx context.Context, path string) ([]*blogResolver, error) {
+	l := loadersFrom(ctx)
+	var blogs []*blogData
+	if err := l.getInto(ctx, bffBlogUpstream, path, &blogs); err != nil {
+		return nil, err
+	}
+	resolvers := make([]*blogResolver, len(blogs))
+	for i, blog := range blogs {
+		l.blogs.Prime(ctx, blog.ID, blog)
+		resolvers[i] = &blogResolver{data: blog}
+	}
+	return resolvers, nil
+}
+
+func (q *queryResolver) User(ctx context.Context, args struct{ Username string }) (*userResolver, error) {
+	profile, err := loadersFrom(ctx).users.Load(ctx, args.Username)()
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	return &userResolver{username: args.Username}, nil
+}
+
+func (q *queryResolver) Me(ctx context.Context) *userResolver {
+	identity := loadersFrom(ctx).identity
+	if identity == nil {
+		return nil
+	}
+	return &userResolver{username: identity.Username}
+}
+
+// tourResolver resolves a tour. A tour loaded over gRPC is not full: it has
+// no reviews or dates, which are then loaded from the REST API on demand.
+type tourResolver struct {
+	data *tourData
+	full bool
+}
+
+func (t *tourResolver) ID() graphql.ID        { return graphql.ID(t.data.ID) }
+func (t *tourResolver) Name() string          { return t.data.Name }
+func (t *tourResolver) Description() string   { return t.data.Description }
+func (t *tourResolver) Difficulty() int32     { return t.data.Difficulty }
+func (t *tourResolver) Tags() []string        { return nonNil(t.data.Tags) }
+func (t *tourResolver) Status() string        { return strings.ToUpper(t.data.Status) }
+func (t *tourResolver) Price() float64        { return t.data.Price }
+func (t *tourResolver) Distance() float64     { return t.data.Distance }
+func (t *tourResolver) Author() *userResolver { return &userResolver{username: t.data.AuthorID} }
+
+func (t *tourResolver) KeyPoints() []*keyPointResolver {
+	resolvers := make([]*keyPointResolver, len(t.data.KeyPoints))
+	for i := range t.data.KeyPoints {
+		resolvers[i] = &keyPointResolver{data: &t.data.KeyPoints[i]}
+	}
+	// Order is authoritative; stable so tours without it keep array order
+	sort.SliceStable(resolvers, func(i, j int) bool {
+		return resolvers[i].data.Order < resolvers[j].data.Order
+	})
+	return resolvers
+}
+
+func (t *tourResolver) Transport() []*transportResolver {
+	resolvers := make([]*transportResolver, len(t.data.TransportInfo))
+	for i := range t.data.TransportInfo {
+		resolvers[i] = &transportResolver{data: &t.data.TransportInfo[i]}
+	}
+	return resolvers
+}
+
+func (t *tourResolver) details(ctx context.Context) (*tourData, error) {
+	if t.full {
+		return t.data, nil
+	}
+	tour, err := loadersFrom(ctx).tourDetails.Load(ctx, t.data.ID)()
+	if err != nil {
+		return nil, err
+	}
+	if tour == nil {
+		return nil, fmt.Errorf("tour %s not found", t.data.ID)
+	}
+	return tour, nil
+}
+
+func (t *tourResolver) PublishedAt(ctx context.Context) (*graphql.Time, error) {
+	tour, err := t.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optionalTime(tour.PublishedAt), nil
+}
+
+func (t *tourResolver) ArchivedAt(ctx context.Context) (*graphql.Time, error) {
+	tour, err := t.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return optionalTime(tour.ArchivedAt), nil
+}
+
+func (t *tourResolver) Reviews(ctx context.Context) ([]*reviewResolver, error) {
+	tour, err := t.details(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*reviewResolver, len(tour.Reviews))
+	for i := range tour.Reviews {
+		resolvers[i] = &reviewResolver{data: &tour.Reviews[i]}
+	}
+	return resolvers, nil
+}
+
+func (t *tourResolver) AverageRating(ctx context.Context) (*float64, error) {
+	tour, err := t.details(ctx)
+	if err != nil || len(tour.Reviews) == 0 {
+		return nil, err
+	}
+	var sum int32
+	for _, review := range tour.Reviews {
+		sum += review.Rating
+	}
+	average := float64(sum) / float64(len(tour.Reviews))
+	return &average, nil
+}
+
+type keyPointResolver struct{ data *keyPointData }
+
+func (k *keyPointResolver) ID() graphql.ID      { return graphql.ID(k.data.ID) }
+func (k *keyPointResolver) Name() string        { return k.data.Name }
+func (k *keyPointResolver) Description() string { return k.data.Description }
+func (k *keyPointResolver) Latitude() float64   { return k.data.Latitude }
+func (k *keyPointResolver) Longitude() float64  { return k.data.Longitude }
+func (k *keyPointResolver) ImageURL() string    { return k.data.ImageURL }
+func (k *keyPointResolver) Order() int32        { return k.data.Order }
+
+type transportResolver struct{ data *transportData }
+
+func (t *transportResolver) Type() string         { return t.data.Type }
+func (t *transportResolver) TimeInMinutes() int32 { return t.data.TimeInMinutes }
+
+type reviewResolver struct{ data *reviewData }
+
+func (r *reviewResolver) ID() graphql.ID            { return graphql.ID(r.data.ID) }
+func (r *reviewResolver) Rating() int32             { return r.data.Rating }
+func (r *reviewResolver) Comment() string           { return r.data.Comment }
+func (r *reviewResolver) VisitDate() graphql.Time   { return graphql.Time{Time: r.data.VisitDate} }
+func (r *reviewResolver) CommentDate() graphql.Time { return graphql.Time{Time: r.data.CommentDate} }
+func (r *reviewResolver) ImageURLs() []string       { return nonNil(r.data.ImageURLs) }
+func (r *reviewResolver) Tourist() *userResolver    { return &userResolver{username: r.data.TouristID} }
+
+type blogResolver struct{ data *blogData }
+
+func (b *blogResolver) ID() graphql.ID          { return graphql.ID(b.data.ID) }
+func (b *blogResolver) Title() string           { return b.data.Title }
+func (b *blogResolver) Content() string         { return b.data.Content }
+func (b *blogResolver) CreatedAt() graphql.Time { return graphql.Time{Time: b.data.CreatedAt} }
+func (b *blogResolver) Images() []string        { return nonNil(b.data.Images) }
+func (b *blogResolver) Author() *userResolver   { return &userResolver{username: b.data.AuthorID} }
+func (b *blogResolver) LikeCount() int32        { return int32(len(b.data.Likes)) }
+
+func (b *blogResolver) Comments() []*commentResolver {
+	resolvers := make([]*commentResolver, len(b.data.Comments))
+	for i := range b.data.Comments {
+		resolvers[i] = &commentResolver{data: &b.data.Comments[i]}
+	}
+	return resolvers
+}
+
+// LikedByMe compares likes with the caller's username, which the blog
+// service stores as the user id
+func (b *blogResolver) LikedByMe(ctx context.Context) bool {
+	identity := loadersFrom(ctx).identity
+	if identity == nil {
+		return false
+	}
+	for _, like := range b.data.Likes {
+		if like.UserID == identity.Username {
+			return true
+		}
+	}
+	return false
+}
+
+type commentResolver struct{ data *commentData }
+
+func (c *commentResolver) ID() graphql.ID          { return graphql.ID(c.data.ID) }
+func (c *commentResolver) Text() string            { return c.data.Text }
+func (c *commentResolver) CreatedAt() graphql.Time { return graphql.Time{Time: c.data.CreatedAt} }
+func (c *commentResolver) LastUpdatedAt() graphql.Time {
+	return graphql.Time{Time: c.data.LastUpdatedAt}
+}
+func (c *commentResolver) Author() *userResolver { return &userResolver{username: c.data.AuthorID} }
+
+// userResolver resolves a user by username; the profile is loaded only when
+// one of its fields is selected
+type userResolver struct {
+	username string
+}
+
+func (u *userResolver) Username() string { return u.username }
+
+func (u *userResolver) profileField(ctx context.Context, field func(*userProfile) string) (*string, error) {
+	profile, err := loadersFrom(ctx).users.Load(ctx, u.username)()
+	if err != nil || profile == nil {
+		return nil, err
+	}
+	value := field(profile)
+	return &value, nil
+}
+
+func (u *userResolver) FirstName(ctx context.Context) (*string, error) {
+	return u.profileField(ctx, func(p *userProfile) string { return p.FirstName })
+}
+
+func (u *userResolver) LastName(ctx context.Context) (*string, error) {
+	return u.profileField(ctx, func(p *userProfile) string { return p.LastName })
+}
+
+func (u *userResolver) ProfilePicture(ctx context.Context) (*string, error) {
+	return u.profileField(ctx, func(p *userProfile) string { return p.ProfilePicture })
+}
+
+func (u *userResolver) Biography(ctx context.Context) (*string, error) {
+	return u.profileField(ctx, func(p *userProfile) string { return p.Biography })
+}
+
+func (u *userResolver) Motto(ctx context.Context) (*string, error) {
+	return u.profileField(ctx, func(p *userProfile) string { return p.Motto })
+}
+
+func (u *userResolver) Followers(ctx context.Context) ([]*followResolver, error) {
+	followers, err := loadersFrom(ctx).followers.Load(ctx, u.username)()
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*followResolver, len(followers))
+	for i, follower := range followers {
+		resolvers[i] = &followResolver{follower: follower, following: u.username}
+	}
+	return resolvers, nil
+}
+
+func (u *userResolver) Following(ctx context.Context) ([]*followResolver, error) {
+	following, err := loadersFrom(ctx).following.Load(ctx, u.username)()
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*followResolver, len(following))
+	for i, followed := range following {
+		resolvers[i] = &followResolver{follower: u.username, following: followed}
+	}
+	return resolvers, nil
+}
+
+func (u *userResolver) FollowedByMe(ctx context.Context) (*bool, error) {
+	l := loadersFrom(ctx)
+	if l.identity == nil {
+		return nil, nil
+	}
+	followed, err := l.followedByCaller(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value := followed[u.username]
+	return &value, nil
+}
+
+type followResolver struct {
+	follower  string
+	following string
+}
+
+func (f *followResolver) Follower() *userResolver  { return &userResolver{username: f.follower} }
+func (f *followResolver) Following() *userResolver { return &userResolver{username: f.following} }
+
+func optionalTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+// nonNil turns a missing list into an empty one for non-null list fields
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health statuses
+const (
+	HealthUp       = "up"
+	HealthDegraded = "degraded" // only optional upstreams are down
+	HealthDown     = "down"
+)
+
+// ServiceHealth is the result of probing one upstream. It is up when at
+// least one instance is; LatencyMs is that of the slowest instance.
+type ServiceHealth struct {
+	Status    string           `json:"status"`
+	Optional  bool             `json:"optional,omitempty"`
+	LatencyMs int64            `json:"latencyMs"`
+	Circuit   string           `json:"circuit,omitempty"`
+	Error     string           `json:"error,omitempty"`
+	Instances []InstanceHealth `json:"instances"`
+}
+
+// InstanceHealth is the result of probing one instance of an upstream pool.
+// Healthy and Ejected tell whether the pool currently sends it traffic.
+type InstanceHealth struct {
+	URL       string `json:"url"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs"`
+	Healthy   bool   `json:"healthy"`
+	Ejected   bool   `json:"ejected,omitempty"`
+	InFlight  int64  `json:"inFlight"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthReport is the aggregated gateway health
+type HealthReport struct {
+	Status    string                   `json:"status"`
+	CheckedAt time.Time                `json:"checkedAt"`
+	Services  map[string]ServiceHealth `json:"services"`
+}
+
+// HealthChecker probes every configured upstream concurrently
+type HealthChecker struct {
+	gateway *Gateway
+	client  *http.Client
+}
+
+func NewHealthChecker(gateway *Gateway) *HealthChecker {
+	return &HealthChecker{gateway: gateway, client: &http.Client{}}
+}
+
+// Check fans out to all upstream health endpoints, each bounded by the
+// configured timeout
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	config := h.gateway.Config()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	services := map[string]ServiceHealth{}
+	for name, upstream := range config.Upstreams {
+		wg.Add(1)
+		go func(name string, upstream UpstreamConfig) {
+			defer wg.Done()
+			result := ServiceHealth{Status: HealthDown, Optional: upstream.Optional, Error: errNoInstances.Error()}
+			if u := h.gateway.Upstream(name); u != nil {
+				result = h.probe(ctx, u.Pool.Snapshot(), upstream, config.Health.Timeout)
+				result.Circuit = u.Breaker.State()
+			}
+			mu.Lock()
+			services[name] = result
+			mu.Unlock()
+		}(name, upstream)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: HealthUp, CheckedAt: time.Now().UTC(), Services: services}
+	for _, service := range services {
+		if service.Status == HealthUp {
+			continue
+		}
+		if !service.Optional {
+			report.Status = HealthDown
+			break
+		}
+		report.Status = HealthDegraded
+	}
+	return report
+}
+
+// probe checks every instance of an upstream concurrently
+func (h *HealthChecker) probe(ctx context.Context, instances []InstanceStatus, upstream UpstreamConfig, timeout time.Duration) ServiceHealth {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make([]InstanceHealth, len(instances))
+	var wg sync.WaitGroup
+	for i, inst := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := InstanceHealth{URL: inst.URL, Status: HealthUp, Healthy: inst.Healthy, Ejected: inst.Ejected, InFlight: inst.InFlight}
+			latency, err := probeHealth(ctx, h.client, inst.URL+upstream.HealthPath)
+			result.LatencyMs = latency.Milliseconds()
+			if err != nil {
+				result.Status = HealthDown
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	service := ServiceHealth{Status: HealthDown, Optional: upstream.Optional, Instances: results}
+	for _, result := range results {
+		if result.Status == HealthUp {
+			service.Status = HealthUp
+		} else if service.Error == "" {
+			service.Error = result.Error
+		}
+		if result.LatencyMs > service.LatencyMs {
+			service.LatencyMs = result.LatencyMs
+		}
+	}
+	if service.Status == HealthUp {
+		service.Error = ""
+	} else if len(results) == 0 {
+		service.Error = errNoInstances.Error()
+	}
+	return service
+}
+
+// probeHealth calls a health endpoint; anything but a 2xx answer is an error
+func probeHealth(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return latency, fmt.Errorf("health endpoint returned %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// Health returns the full per-service report. 503 means a required upstream is down.
+func (h *HealthChecker) Health(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+	writeHealth(w, report.Status, report)
+}
+
+// Ready is the readiness probe: the gateway can serve traffic only when every
+// required upstream is reachable and it is not shutting down
+func (h *HealthChecker) Ready(w http.ResponseWriter, r *http.Request) {
+	if h.gateway.Draining() {
+		writeHealth(w, HealthDown, map[string]interface{}{"status": HealthDown, "draining": true})
+		return
+	}
+	report := h.Check(r.Context())
+	var down []string
+	for name, service := range report.Services {
+		if service.Status != HealthUp {
+			down = append(down, name)
+		}
+	}
+	sort.Strings(down)
+	writeHealth(w, report.Status, map[string]interface{}{"status": report.Status, "down": down})
+}
+
+// Live is the liveness probe: it only tells that the gateway process is serving
+func (h *HealthChecker) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthUp, map[string]string{"status": HealthUp})
+}
+
+func writeHealth(w http.ResponseWriter, status string, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if status == HealthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// RequestIDHeader correlates a request across the gateway and the services
+const RequestIDHeader = "X-Request-ID"
+
+// accessLog collects fields filled in further down the chain (user by
+// authMiddleware, upstream by the route handler) for the access log line
+type accessLog struct {
+	requestID string
+	user      string
+	upstream  string
+}
+
+type accessLogKey struct{}
+
+func setupLogging() {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("service", "api-gateway"))
+}
+
+// RequestIDFromContext returns the id assigned by loggingMiddleware
+func RequestIDFromContext(ctx context.Context) string {
+	if entry, ok := ctx.Value(accessLogKey{}).(*accessLog); ok {
+		return entry.requestID
+	}
+	return ""
+}
+
+func setLogUser(r *http.Request, user string) {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLog); ok {
+		entry.user = user
+	}
+}
+
+func setLogUpstream(r *http.Request, upstream string) {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLog); ok {
+		entry.upstream = upstream
+	}
+}
+
+// loggedUpstream returns the upstream that answered the request, if any
+func loggedUpstream(r *http.Request) string {
+	if entry, ok := r.Context().Value(accessLogKey{}).(*accessLog); ok {
+		return entry.upstream
+	}
+	return ""
+}
+
+// loggingMiddleware keeps a valid incoming X-Request-ID or assigns a new one,
+// forwards it upstream, echoes it to the client and writes one JSON access
+// log line per request
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		path := r.URL.Path // the route handler rewrites r.URL in place
+		entry := &accessLog{requestID: r.Header.Get(RequestIDHeader)}
+		if !validRequestID(entry.requestID) {
+			entry.requestID = newRequestID()
+		}
+		r.Header.Set(RequestIDHeader, entry.requestID)
+		w.Header().Set(RequestIDHeader, entry.requestID)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// Deferred: a stream cut off mid-body aborts the handler with a panic
+		defer func() {
+			slog.Info("request",
+				"request_id", entry.requestID,
+				"method", r.Method,
+				"path", path,
+				"status", recorder.status,
+				"bytes", recorder.bytes,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"user", entry.user,
+				"upstream", entry.upstream,
+				"remote_addr", r.RemoteAddr,
+			)
+		}()
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, entry)))
+	})
+}
+
+// statusRecorder captures the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach Flush on the real writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Hijack records a WebSocket upgrade, whose 101 response is written to the
+// hijacked connection and never passes through WriteHeader
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
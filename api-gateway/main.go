@@ -2,36 +2,116 @@ package main
 
 import (
 	"log"
-	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
+	setupLogging()
+
+	// Services trusting the gateway accept any identity signed with this secret
+	if len(identitySecret) == 0 {
+		log.Fatal("GATEWAY_IDENTITY_SECRET must be set")
+	}
+
+	// Load the declarative route table
+	configPath := getEnv("GATEWAY_CONFIG", "gateway.yaml")
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		log.Fatalf("Failed to load gateway config %s: %v", configPath, err)
+	}
+	gateway := NewGateway(config)
+
+	rateLimitStore, err := NewRateLimitStore(config.RateLimit.Store)
+	if err != nil {
+		log.Fatalf("Failed to create rate limit store: %v", err)
+	}
+	rateLimiter := NewRateLimiter(gateway, rateLimitStore)
+
+	apiKeyStore, err := NewAPIKeyStore(config.APIKeys)
+	if err != nil {
+		log.Fatalf("Failed to create API key store: %v", err)
+	}
+	apiKeys := NewAPIKeys(gateway, apiKeyStore)
+
+	auditStore, err := NewAuditStore(config.Audit)
+	if err != nil {
+		log.Fatalf("Failed to create audit store: %v", err)
+	}
+	audit := NewAuditLog(gateway, auditStore)
+
+	// Reload the route table whenever the file changes
+	reloadInterval, err := time.ParseDuration(getEnv("GATEWAY_CONFIG_RELOAD_INTERVAL", "5s"))
+	if err != nil {
+		log.Fatalf("Invalid GATEWAY_CONFIG_RELOAD_INTERVAL: %v", err)
+	}
+	go watchConfig(configPath, reloadInterval, gateway.Apply)
+
 	router := mux.NewRouter()
 
-	// Setup CORS middleware
-	router.Use(corsMiddleware)
-	
-	// Setup logging middleware
+	// Request id + JSON access log (first, so preflights are logged too)
 	router.Use(loggingMiddleware)
 
-	// Health check endpoint
-	router.HandleFunc("/health", healthCheck).Methods("GET")
+	// Prometheus request metrics per route
+	router.Use(gateway.metricsMiddleware)
 
-	// Setup routes for different services
-	setupStakeholdersRoutes(router)
-	setupPaymentsRoutes(router)
-	setupBlogRoutes(router)
-	setupToursRoutes(router)
-	setupEncountersRoutes(router)
-	setupFollowerRoutes(router)
+	// Setup CORS middleware
+	router.Use(gateway.corsMiddleware)
 
-	log.Println("API Gateway starting on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", router))
-}
+	// Validate JWTs once at the edge and forward a signed identity upstream
+	router.Use(authMiddleware)
+
+	// Partner API keys: scopes and quotas (before the rate limiter, which limits per key)
+	router.Use(apiKeys.Middleware)
+
+	// Audit trail of mutating requests (needs the caller, before the rate limiter rejects anything)
+	router.Use(audit.Middleware)
+
+	// Token bucket limits per user or client IP (needs the identity from authMiddleware)
+	router.Use(rateLimiter.Middleware)
+
+	// Response cache for public read endpoints (per-user keys need the identity)
+	router.Use(NewResponseCache(gateway).Middleware)
+
+	// Health endpoints: full report, readiness (required upstreams up) and liveness
+	healthChecker := NewHealthChecker(gateway)
+	router.HandleFunc("/health", healthChecker.Health).Methods("GET")
+	router.HandleFunc("/health/ready", healthChecker.Ready).Methods("GET")
+	router.HandleFunc("/health/live", healthChecker.Live).Methods("GET")
+
+	// Prometheus metrics of the gateway itself
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// Backend-for-frontend aggregation endpoints
+	bff := NewBFF(gateway)
+	router.HandleFunc("/api/bff/tours/{id}", bff.TourDetail).Methods("GET")
+
+	// GraphQL read API over tours, blogs, users and follows
+	router.Handle("/graphql", NewGraphQL(gateway)).Methods("GET", "POST")
+
+	// Unified OpenAPI document of all services and its Swagger UI
+	docs := NewOpenAPIDocs(gateway)
+	router.HandleFunc("/openapi.json", docs.Spec).Methods("GET")
+	router.HandleFunc("/docs", docs.UI).Methods("GET")
+	router.PathPrefix("/docs/").HandlerFunc(docs.UI).Methods("GET")
+
+	// API key management for administrators
+	router.HandleFunc("/api/gateway/api-keys", apiKeys.Admin(apiKeys.List)).Methods("GET")
+	router.HandleFunc("/api/gateway/api-keys", apiKeys.Admin(apiKeys.Create)).Methods("POST")
+	router.HandleFunc("/api/gateway/api-keys/{id}", apiKeys.Admin(apiKeys.Get)).Methods("GET")
+	router.HandleFunc("/api/gateway/api-keys/{id}", apiKeys.Admin(apiKeys.Update)).Methods("PUT")
+	router.HandleFunc("/api/gateway/api-keys/{id}", apiKeys.Admin(apiKeys.Revoke)).Methods("DELETE")
+	router.HandleFunc("/api/gateway/api-keys/{id}/rotate", apiKeys.Admin(apiKeys.Rotate)).Methods("POST")
+
+	// Audit trail for administrators
+	router.HandleFunc("/api/gateway/audit", audit.Admin(audit.Query)).Methods("GET")
+
+	// Everything else is routed according to the config file
+	router.PathPrefix("/").Handler(gateway)
 
-func healthCheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("API Gateway is healthy"))
+	log.Printf("API Gateway starting on %s with %d routes from %s...", config.Server.Addr, len(config.Routes), configPath)
+	serve(router, gateway, config.Server)
+	audit.Close()
 }
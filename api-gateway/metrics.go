@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_http_requests_total",
+		Help: "Requests handled by the gateway, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_http_request_duration_seconds",
+		Help:    "Gateway request latency including the upstream call, by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	upstreamErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_errors_total",
+		Help: "Failed upstream calls, by upstream and reason (unavailable, timeout, circuit_open).",
+	}, []string{"upstream", "reason"})
+
+	upstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_retries_total",
+		Help: "Retried upstream requests, by upstream.",
+	}, []string{"upstream"})
+
+	cacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_requests_total",
+		Help: "Cacheable GET requests, by cache rule and result (hit, miss).",
+	}, []string{"rule", "result"})
+
+	cacheInvalidations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_cache_invalidations_total",
+		Help: "Cache purges triggered by writes, by cache rule.",
+	}, []string{"rule"})
+
+	circuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_circuit_breaker_state",
+		Help: "Circuit breaker state per upstream: 0 closed, 1 half-open, 2 open.",
+	}, []string{"upstream"})
+
+	upstreamInstanceUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_upstream_instance_up",
+		Help: "Whether a pool instance receives traffic (1) or is unhealthy or ejected (0).",
+	}, []string{"upstream", "instance"})
+
+	upstreamEjections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_upstream_ejections_total",
+		Help: "Instances taken out of a pool, by upstream and reason (active, passive).",
+	}, []string{"upstream", "reason"})
+
+	streamConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gateway_stream_connections",
+		Help: "Open WebSocket and SSE connections, by route and protocol.",
+	}, []string{"route", "protocol"})
+
+	streamRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_stream_rejections_total",
+		Help: "Refused stream connections, by route and reason (max_connections, max_per_client, origin, not_stream_route).",
+	}, []string{"route", "reason"})
+
+	streamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_stream_duration_seconds",
+		Help:    "Lifetime of WebSocket and SSE connections, by route and protocol.",
+		Buckets: []float64{1, 5, 15, 60, 300, 900, 3600, 14400},
+	}, []string{"route", "protocol"})
+
+	streamBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_stream_bytes_total",
+		Help: "Bytes relayed over stream connections, by route and direction (upstream, downstream).",
+	}, []string{"route", "direction"})
+
+	variantRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_variant_requests_total",
+		Help: "Requests of routes with a canary, by route, variant (stable, canary) and status code.",
+	}, []string{"route", "variant", "status"})
+
+	variantDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_variant_request_duration_seconds",
+		Help:    "Latency of routes with a canary, by route and variant.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "variant"})
+
+	apiKeyRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_api_key_requests_total",
+		Help: "Requests made with an API key, by partner and result (allowed, invalid, forbidden, quota_exceeded).",
+	}, []string{"partner", "result"})
+
+	auditRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gateway_audit_records_total",
+		Help: "Audit records by result (written, failed, dropped when the buffer is full).",
+	}, []string{"result"})
+
+	graphQLBatchSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gateway_graphql_batch_size",
+		Help:    "Keys per GraphQL data loader batch, by loader (users, tours, tour_details, blogs, followers, following).",
+		Buckets: []float64{1, 2, 5, 10, 25, 50, 100},
+	}, []string{"loader"})
+)
+
+var circuitStateValues = map[string]float64{CircuitClosed: 0, CircuitHalfOpen: 1, CircuitOpen: 2}
+
+// metricsMiddleware counts and times every request. Proxied requests are
+// labeled with the name of the config route, everything else with the mux
+// route template. Streams are timed by gateway_stream_duration_seconds
+// instead, their lifetime would drown the request latencies.
+func (g *Gateway) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		route := g.routeLabel(r)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			requestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(recorder.status)).Inc()
+			if streamProtocol(r) == "" {
+				requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+			}
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+func (g *Gateway) routeLabel(r *http.Request) string {
+	table := g.table.Load()
+	if rt := table.matchGRPC(r.Method, r.URL.Path); rt != nil {
+		return rt.Name
+	}
+	if rt := table.match(r.URL.Path); rt != nil {
+		return rt.Name
+	}
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil && template != "/" {
+			return template
+		}
+	}
+	return "unmatched"
+}
@@ -1,46 +1,79 @@
 package main
 
 import (
-	"log"
 	"net/http"
+	"path"
+	"strconv"
 	"strings"
 )
 
-// corsMiddleware handles CORS headers
-func corsMiddleware(next http.Handler) http.Handler {
+// corsMiddleware is the only place CORS headers come from: upstream CORS
+// headers are dropped by the proxy. Allowed origins get their Origin echoed
+// back, preflights are answered here so upstreams never see them, and
+// requests from other origins get no CORS headers (the browser blocks them).
+func (g *Gateway) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only set CORS headers for non-API routes (like /health)
-		// API routes will get CORS headers from ModifyResponse in reverse proxy
-		if !strings.HasPrefix(r.URL.Path, "/api/") {
-			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Max-Age", "86400")
-			
-			// Handle preflight OPTIONS requests for non-API routes
-			if r.Method == "OPTIONS" {
-				log.Printf("🔄 [CORS] OPTIONS preflight request for %s from %s", r.URL.Path, r.Header.Get("Origin"))
-				w.WriteHeader(http.StatusOK)
-				return
+		cors := g.Config().CORS
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && cors.allows(origin)
+		if allowed {
+			// "*" is only configurable without credentials (see CORSConfig.validate)
+			if cors.allowsAnyOrigin() {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if cors.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
 			}
-		} else {
-			// For API routes, just log OPTIONS requests but don't handle them here
-			if r.Method == "OPTIONS" {
-				log.Printf("🔄 [CORS] OPTIONS preflight request for API %s from %s", r.URL.Path, r.Header.Get("Origin"))
+			if len(cors.ExposeHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
 			}
 		}
 
-		next.ServeHTTP(w, r)
+		if r.Method != http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight
+		if origin != "" && !allowed {
+			writeJSONError(w, http.StatusForbidden, "Origin "+origin+" is not allowed")
+			return
+		}
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		h.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowMethods, ", "))
+		h.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowHeaders, ", "))
+		if cors.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
 	})
 }
 
-// loggingMiddleware logs incoming requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("🔀 [GATEWAY] %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-		log.Printf("📨 [GATEWAY] Headers: User-Agent=%s, Origin=%s", r.Header.Get("User-Agent"), r.Header.Get("Origin"))
-		next.ServeHTTP(w, r)
-		log.Printf("✅ [GATEWAY] Response sent for %s %s", r.Method, r.URL.Path)
-	})
+// allows matches the origin against the allowed list; entries may be exact
+// origins, "*" or patterns such as https://*.example.com or http://localhost:*
+func (c *CORSConfig) allows(origin string) bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if matched, _ := path.Match(allowed, origin); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) allowsAnyOrigin() bool {
+	for _, allowed := range c.AllowOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
 }
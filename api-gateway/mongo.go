@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var mongoClient struct {
+	once   sync.Once
+	client *mongo.Client
+	err    error
+}
+
+// mongoDatabase returns a database on the MongoDB server at MONGO_URI. The
+// stores that use MongoDB share one client.
+func mongoDatabase(name string) (*mongo.Database, error) {
+	mongoClient.once.Do(func() {
+		uri := os.Getenv("MONGO_URI")
+		if uri == "" {
+			mongoClient.err = fmt.Errorf("MONGO_URI is not set")
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+		if err == nil {
+			err = client.Ping(ctx, nil)
+		}
+		mongoClient.client, mongoClient.err = client, err
+	})
+	if mongoClient.err != nil {
+		return nil, mongoClient.err
+	}
+	return mongoClient.client.Database(name), nil
+}
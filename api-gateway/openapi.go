@@ -0,0 +1,723 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	swaggerFiles "github.com/swaggo/files"
+)
+
+// openAPIMethods are the operation keys of an OpenAPI path item
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// bearerScheme is the single security scheme of the merged document; the
+// services' own Authorization header schemes are folded into it
+const bearerScheme = "bearerAuth"
+
+// openAPIRetryInterval is how long a document missing a source is served
+// (at most CacheTTL), so a service that is down does not make every request
+// wait for the spec timeout
+const openAPIRetryInterval = 5 * time.Second
+
+// maxSpecBytes bounds a fetched spec
+const maxSpecBytes = 10 << 20
+
+// OpenAPIDocs serves one OpenAPI 3 document for the whole API (GET
+// /openapi.json) and a Swagger UI for it (/docs/).
+//
+// The specs of the configured sources are converted to OpenAPI 3, their paths
+// mapped to the gateway routes that reach them and their components prefixed
+// with the upstream name, so schemas of different services cannot collide.
+// Operations no route reaches are left out. When a source cannot be loaded
+// its last good spec is used; a source that never loaded is listed in
+// x-gateway-sources and the document is rebuilt after openAPIRetryInterval.
+type OpenAPIDocs struct {
+	gateway *Gateway
+	ui      http.Handler
+
+	mu       sync.Mutex
+	config   *GatewayConfig // configuration the cached document was built for
+	doc      []byte
+	expires  time.Time
+	lastGood map[OpenAPISourceConfig]map[string]any
+}
+
+// openAPISourceStatus tells how a source went into the document
+type openAPISourceStatus struct {
+	Upstream string `json:"upstream"`
+	Status   string `json:"status"` // ok, stale or unavailable
+	Error    string `json:"error,omitempty"`
+}
+
+func NewOpenAPIDocs(gateway *Gateway) *OpenAPIDocs {
+	return &OpenAPIDocs{
+		gateway:  gateway,
+		ui:       http.StripPrefix("/docs", http.FileServer(swaggerFiles.HTTP)),
+		lastGood: map[OpenAPISourceConfig]map[string]any{},
+	}
+}
+
+// Spec serves the merged document
+func (d *OpenAPIDocs) Spec(w http.ResponseWriter, r *http.Request) {
+	doc := d.document(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(doc)
+}
+
+// UI serves the Swagger UI bundled with swaggo/files, pointed at /openapi.json
+func (d *OpenAPIDocs) UI(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/docs":
+		http.Redirect(w, r, "/docs/", http.StatusMovedPermanently)
+	case "/docs/swagger-initializer.js":
+		w.Header().Set("Content-Type", "application/javascript")
+		io.WriteString(w, swaggerInitializer)
+	default:
+		d.ui.ServeHTTP(w, r)
+	}
+}
+
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "/openapi.json",
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    persistAuthorization: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// document returns the cached document, rebuilding it when it expired or
+// the configuration was reloaded
+func (d *OpenAPIDocs) document(ctx context.Context) []byte {
+	table := d.gateway.table.Load()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.doc != nil && d.config == table.config && time.Now().Before(d.expires) {
+		return d.doc
+	}
+
+	doc, complete := d.build(ctx, table)
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		// Specs are decoded from JSON, so they always encode again
+		log.Printf("[OPENAPI] Failed to encode document: %v", err)
+		return []byte("{}")
+	}
+	d.config = table.config
+	d.doc = data
+	ttl := table.config.OpenAPI.CacheTTL
+	if !complete {
+		ttl = min(ttl, openAPIRetryInterval)
+	}
+	d.expires = time.Now().Add(ttl)
+	return data
+}
+
+// build loads every source concurrently and merges them in config order.
+// complete is false when a source has never been loaded.
+func (d *OpenAPIDocs) build(ctx context.Context, table *routeTable) (map[string]any, bool) {
+	config := table.config.OpenAPI
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
+
+	specs := make([]map[string]any, len(config.Sources))
+	errs := make([]error, len(config.Sources))
+	var wg sync.WaitGroup
+	for i, source := range config.Sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			specs[i], errs[i] = d.load(ctx, table, source)
+		}()
+	}
+	wg.Wait()
+
+	merged := &openAPIMerge{
+		table:   table,
+		paths:   map[string]any{},
+		schemes: map[string]any{},
+		comps:   map[string]map[string]any{},
+		tags:    map[string]bool{},
+		tagList: []any{},
+	}
+	complete := true
+	for i, source := range config.Sources {
+		status := openAPISourceStatus{Upstream: source.Upstream, Status: "ok"}
+		spec := specs[i]
+		if errs[i] != nil {
+			status.Error = errs[i].Error()
+			status.Status = "stale"
+			if spec = d.lastGood[source]; spec == nil {
+				status.Status = "unavailable"
+				complete = false
+			}
+			log.Printf("[OPENAPI] Spec of %s not loaded (%s): %v", source.Upstream, status.Status, errs[i])
+		} else {
+			d.lastGood[source] = spec
+		}
+		if spec != nil {
+			merged.add(source.Upstream, spec)
+		}
+		merged.sources = append(merged.sources, status)
+	}
+
+	merged.schemes[bearerScheme] = map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+	components := map[string]any{"securitySchemes": merged.schemes}
+	for kind, entries := range merged.comps {
+		components[kind] = entries
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       config.Title,
+			"version":     config.Version,
+			"description": "All services behind the API gateway. Paths are the public gateway paths.",
+		},
+		"servers":           []any{map[string]any{"url": "/"}},
+		"tags":              merged.tagList,
+		"paths":             merged.paths,
+		"components":        components,
+		"x-gateway-sources": merged.sources,
+	}, complete
+}
+
+// load fetches a source through its upstream (pool, breaker and retries
+// included) or reads it from disk
+func (d *OpenAPIDocs) load(ctx context.Context, table *routeTable, source OpenAPISourceConfig) (map[string]any, error) {
+	var body io.Reader
+	if source.File != "" {
+		data, err := os.ReadFile(source.File)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	} else {
+		upstream := table.upstreams[source.Upstream]
+		target := *upstream.Target
+		target.Path = strings.TrimSuffix(target.Path, "/") + source.Path
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := upstream.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s returned %d", source.Path, resp.StatusCode)
+		}
+		body = io.LimitReader(resp.Body, maxSpecBytes)
+	}
+
+	var spec map[string]any
+	if err := json.NewDecoder(body).Decode(&spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	if version, _ := spec["swagger"].(string); version == "2.0" {
+		return convertSwagger2(spec), nil
+	}
+	if version, _ := spec["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported spec version")
+	}
+	return spec, nil
+}
+
+// openAPIMerge accumulates the merged document
+type openAPIMerge struct {
+	table   *routeTable
+	paths   map[string]any
+	schemes map[string]any
+	comps   map[string]map[string]any
+	tags    map[string]bool
+	tagList []any
+	sources []openAPISourceStatus
+}
+
+// add merges the OpenAPI 3 spec of an upstream
+func (m *openAPIMerge) add(upstream string, spec map[string]any) {
+	// The spec is changed in place and may be the last good one of the source
+	spec = namespaceComponents(cloneJSON(spec), upstream)
+
+	// Every scheme sending a token in the Authorization header becomes the
+	// shared bearer scheme, the rest keep a namespaced copy
+	renames := map[string]string{}
+	components, _ := spec["components"].(map[string]any)
+	schemes, _ := components["securitySchemes"].(map[string]any)
+	for name, scheme := range schemes {
+		if isBearerScheme(scheme) {
+			renames[name] = bearerScheme
+			continue
+		}
+		renames[name] = upstream + "." + name
+		m.schemes[upstream+"."+name] = scheme
+	}
+	for kind, entries := range components {
+		if entries, ok := entries.(map[string]any); ok && kind != "securitySchemes" {
+			if m.comps[kind] == nil {
+				m.comps[kind] = map[string]any{}
+			}
+			for name, entry := range entries {
+				m.comps[kind][name] = entry
+			}
+		}
+	}
+
+	info, _ := spec["info"].(map[string]any)
+	title, _ := info["title"].(string)
+	m.addTag(map[string]any{"name": upstream, "description": title})
+	if tags, ok := spec["tags"].([]any); ok {
+		for _, tag := range tags {
+			if tag, ok := tag.(map[string]any); ok {
+				m.addTag(tag)
+			}
+		}
+	}
+
+	basePath := ""
+	if servers, ok := spec["servers"].([]any); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]any); ok {
+			serverURL, _ := server["url"].(string)
+			if parsed, err := url.Parse(serverURL); err == nil {
+				basePath = strings.TrimSuffix(parsed.Path, "/")
+			}
+		}
+	}
+	defaultSecurity := spec["security"]
+
+	paths, _ := spec["paths"].(map[string]any)
+	servicePaths := make([]string, 0, len(paths))
+	for servicePath := range paths {
+		servicePaths = append(servicePaths, servicePath)
+	}
+	sort.Strings(servicePaths)
+	for _, servicePath := range servicePaths {
+		item, ok := paths[servicePath].(map[string]any)
+		if !ok {
+			continue
+		}
+		rt, gatewayPath := m.table.publicPath(upstream, basePath+servicePath)
+		if rt == nil {
+			continue
+		}
+
+		merged, _ := m.paths[gatewayPath].(map[string]any)
+		if merged == nil {
+			merged = map[string]any{}
+		}
+		for key, value := range item {
+			if !isOpenAPIMethod(key) {
+				if _, exists := merged[key]; !exists {
+					merged[key] = value
+				}
+				continue
+			}
+			op, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			if !rt.allowsMethod(strings.ToUpper(key)) {
+				continue
+			}
+			if m.table.matchGRPC(strings.ToUpper(key), gatewayPath) != nil {
+				continue // shadowed by a transcoded gRPC route
+			}
+			if _, exists := merged[key]; exists {
+				log.Printf("[OPENAPI] %s %s of %s duplicates an operation of another service, skipped", strings.ToUpper(key), gatewayPath, upstream)
+				continue
+			}
+			if tags, _ := op["tags"].([]any); len(tags) == 0 {
+				op["tags"] = []any{upstream}
+			}
+			if _, ok := op["security"]; !ok && defaultSecurity != nil {
+				op["security"] = defaultSecurity
+			}
+			if security, ok := op["security"].([]any); ok {
+				op["security"] = renameSecurity(security, renames)
+			} else if rt.Auth == AuthRequired {
+				op["security"] = []any{map[string]any{bearerScheme: []any{}}}
+			}
+			merged[key] = op
+		}
+		m.paths[gatewayPath] = merged
+	}
+}
+
+func (m *openAPIMerge) addTag(tag map[string]any) {
+	name, _ := tag["name"].(string)
+	if name == "" || m.tags[name] {
+		return
+	}
+	m.tags[name] = true
+	m.tagList = append(m.tagList, tag)
+}
+
+// publicPath maps a path of an upstream back to the gateway path reaching it,
+// by inverting the prefix/rewrite of the upstream's routes. The most specific
+// route wins; the path is dropped if another route shadows it.
+func (t *routeTable) publicPath(upstream, servicePath string) (*route, string) {
+	var best *route
+	var bestTarget string
+	for _, rt := range t.routes {
+		if rt.Upstream != upstream {
+			continue
+		}
+		target := rt.Prefix
+		if rt.Rewrite != nil {
+			target = strings.TrimSuffix(*rt.Rewrite, "/")
+		}
+		if target != "" && servicePath != target && !strings.HasPrefix(servicePath, target+"/") {
+			continue
+		}
+		if best == nil || len(target) > len(bestTarget) {
+			best, bestTarget = rt, target
+		}
+	}
+	if best == nil {
+		return nil, ""
+	}
+	publicPath := best.Prefix + strings.TrimPrefix(servicePath, bestTarget)
+	if t.match(publicPath) != best {
+		return nil, ""
+	}
+	return best, publicPath
+}
+
+func isOpenAPIMethod(key string) bool {
+	for _, method := range openAPIMethods {
+		if key == method {
+			return true
+		}
+	}
+	return false
+}
+
+// isBearerScheme tells if a security scheme carries the token in the
+// Authorization header (swag's apiKey convention or HTTP bearer)
+func isBearerScheme(scheme any) bool {
+	s, _ := scheme.(map[string]any)
+	switch s["type"] {
+	case "apiKey":
+		name, _ := s["name"].(string)
+		return s["in"] == "header" && strings.EqualFold(name, "Authorization")
+	case "http":
+		name, _ := s["scheme"].(string)
+		return strings.EqualFold(name, "bearer")
+	}
+	return false
+}
+
+func renameSecurity(security []any, renames map[string]string) []any {
+	renamed := make([]any, 0, len(security))
+	for _, requirement := range security {
+		requirement, ok := requirement.(map[string]any)
+		if !ok {
+			continue
+		}
+		out := map[string]any{}
+		for name, scopes := range requirement {
+			if newName, ok := renames[name]; ok {
+				name = newName
+			}
+			out[name] = scopes
+		}
+		renamed = append(renamed, out)
+	}
+	return renamed
+}
+
+// namespaceComponents prefixes the component names (except security schemes,
+// see openAPIMerge.add) with the upstream name and rewrites every reference
+func namespaceComponents(spec map[string]any, upstream string) map[string]any {
+	if components, ok := spec["components"].(map[string]any); ok {
+		for kind, entries := range components {
+			entries, ok := entries.(map[string]any)
+			if !ok || kind == "securitySchemes" {
+				continue
+			}
+			renamed := make(map[string]any, len(entries))
+			for name, entry := range entries {
+				renamed[upstream+"."+name] = entry
+			}
+			components[kind] = renamed
+		}
+	}
+	return rewriteRefs(spec, func(ref string) string {
+		rest, ok := strings.CutPrefix(ref, "#/components/")
+		kind, name, found := strings.Cut(rest, "/")
+		if !ok || !found || kind == "securitySchemes" {
+			return ref
+		}
+		return "#/components/" + kind + "/" + upstream + "." + name
+	}).(map[string]any)
+}
+
+// rewriteRefs applies rewrite to every $ref of a JSON tree
+func rewriteRefs(node any, rewrite func(string) string) any {
+	switch node := node.(type) {
+	case map[string]any:
+		for key, value := range node {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				node[key] = rewrite(ref)
+			} else {
+				node[key] = rewriteRefs(value, rewrite)
+			}
+		}
+	case []any:
+		for i, value := range node {
+			node[i] = rewriteRefs(value, rewrite)
+		}
+	}
+	return node
+}
+
+// convertSwagger2 turns a Swagger 2.0 spec into OpenAPI 3. It covers what
+// swag generates: body and form parameters become request bodies, response
+// schemas get a content entry per produced media type and definitions
+// become component schemas.
+func convertSwagger2(spec map[string]any) map[string]any {
+	out := map[string]any{"openapi": "3.0.3", "info": spec["info"]}
+	for _, key := range []string{"tags", "security", "externalDocs"} {
+		if value, ok := spec[key]; ok {
+			out[key] = value
+		}
+	}
+	basePath, _ := spec["basePath"].(string)
+	out["servers"] = []any{map[string]any{"url": "/" + strings.Trim(basePath, "/")}}
+
+	components := map[string]any{}
+	if definitions, ok := spec["definitions"].(map[string]any); ok {
+		components["schemas"] = definitions
+	}
+	if definitions, ok := spec["securityDefinitions"].(map[string]any); ok {
+		schemes := map[string]any{}
+		for name, definition := range definitions {
+			schemes[name] = convertSecurityDefinition(definition)
+		}
+		components["securitySchemes"] = schemes
+	}
+	out["components"] = components
+
+	consumes := stringList(spec["consumes"], "application/json")
+	produces := stringList(spec["produces"], "application/json")
+	paths := map[string]any{}
+	if items, ok := spec["paths"].(map[string]any); ok {
+		for path, item := range items {
+			item, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			converted := map[string]any{}
+			for key, value := range item {
+				switch {
+				case key == "parameters":
+					converted[key] = convertParameters(value)
+				case isOpenAPIMethod(key):
+					if op, ok := value.(map[string]any); ok {
+						converted[key] = convertOperation(op, consumes, produces)
+					}
+				default:
+					converted[key] = value
+				}
+			}
+			paths[path] = converted
+		}
+	}
+	out["paths"] = paths
+
+	return rewriteRefs(out, func(ref string) string {
+		if name, ok := strings.CutPrefix(ref, "#/definitions/"); ok {
+			return "#/components/schemas/" + name
+		}
+		return ref
+	}).(map[string]any)
+}
+
+func convertOperation(op map[string]any, consumes, produces []string) map[string]any {
+	consumes = stringList(op["consumes"], consumes...)
+	produces = stringList(op["produces"], produces...)
+
+	out := map[string]any{}
+	for key, value := range op {
+		switch key {
+		case "parameters", "responses", "consumes", "produces", "schemes":
+		default:
+			out[key] = value
+		}
+	}
+
+	params, _ := op["parameters"].([]any)
+	if converted := convertParameters(params); len(converted) > 0 {
+		out["parameters"] = converted
+	}
+	form := map[string]any{}
+	var formRequired []any
+	multipart := false
+	for _, param := range params {
+		param, ok := param.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch param["in"] {
+		case "body":
+			content := map[string]any{}
+			for _, mediaType := range consumes {
+				content[mediaType] = map[string]any{"schema": param["schema"]}
+			}
+			body := map[string]any{"content": content}
+			copyKeys(body, param, "description", "required")
+			out["requestBody"] = body
+		case "formData":
+			name, _ := param["name"].(string)
+			form[name] = parameterSchema(param)
+			if required, _ := param["required"].(bool); required {
+				formRequired = append(formRequired, name)
+			}
+			multipart = multipart || param["type"] == "file"
+		}
+	}
+	if len(form) > 0 {
+		mediaType := "application/x-www-form-urlencoded"
+		if multipart || slices.Contains(consumes, "multipart/form-data") {
+			mediaType = "multipart/form-data"
+		}
+		schema := map[string]any{"type": "object", "properties": form}
+		if len(formRequired) > 0 {
+			schema["required"] = formRequired
+		}
+		out["requestBody"] = map[string]any{"content": map[string]any{mediaType: map[string]any{"schema": schema}}}
+	}
+
+	responses := map[string]any{}
+	if items, ok := op["responses"].(map[string]any); ok {
+		for code, response := range items {
+			response, ok := response.(map[string]any)
+			if !ok {
+				continue
+			}
+			converted := map[string]any{"description": ""}
+			copyKeys(converted, response, "description", "$ref")
+			if schema, ok := response["schema"]; ok {
+				content := map[string]any{}
+				for _, mediaType := range produces {
+					content[mediaType] = map[string]any{"schema": schema}
+				}
+				converted["content"] = content
+			}
+			if headers, ok := response["headers"].(map[string]any); ok {
+				convertedHeaders := map[string]any{}
+				for name, header := range headers {
+					if header, ok := header.(map[string]any); ok {
+						h := map[string]any{"schema": parameterSchema(header)}
+						copyKeys(h, header, "description")
+						convertedHeaders[name] = h
+					}
+				}
+				converted["headers"] = convertedHeaders
+			}
+			responses[code] = converted
+		}
+	}
+	out["responses"] = responses
+	return out
+}
+
+// convertParameters converts the path, query and header parameters; body
+// and form parameters are handled by convertOperation
+func convertParameters(value any) []any {
+	params, _ := value.([]any)
+	var out []any
+	for _, param := range params {
+		param, ok := param.(map[string]any)
+		if !ok || param["in"] == "body" || param["in"] == "formData" {
+			continue
+		}
+		converted := map[string]any{"schema": parameterSchema(param)}
+		copyKeys(converted, param, "name", "in", "description", "required", "$ref")
+		out = append(out, converted)
+	}
+	return out
+}
+
+// parameterSchema builds the schema of a non-body Swagger 2.0 parameter,
+// whose type keywords sit on the parameter itself
+func parameterSchema(param map[string]any) map[string]any {
+	schema := map[string]any{}
+	copyKeys(schema, param, "type", "format", "items", "enum", "default", "minimum", "maximum",
+		"exclusiveMinimum", "exclusiveMaximum", "minLength", "maxLength", "pattern", "minItems", "maxItems", "uniqueItems")
+	if schema["type"] == "file" {
+		schema["type"], schema["format"] = "string", "binary"
+	}
+	return schema
+}
+
+func convertSecurityDefinition(definition any) any {
+	d, ok := definition.(map[string]any)
+	if !ok {
+		return definition
+	}
+	switch d["type"] {
+	case "basic":
+		return map[string]any{"type": "http", "scheme": "basic"}
+	case "oauth2":
+		flow := map[string]any{"scopes": d["scopes"]}
+		copyKeys(flow, d, "authorizationUrl", "tokenUrl")
+		flowName, _ := d["flow"].(string)
+		switch flowName {
+		case "application":
+			flowName = "clientCredentials"
+		case "accessCode":
+			flowName = "authorizationCode"
+		}
+		return map[string]any{"type": "oauth2", "flows": map[string]any{flowName: flow}}
+	}
+	return d
+}
+
+func copyKeys(dst, src map[string]any, keys ...string) {
+	for _, key := range keys {
+		if value, ok := src[key]; ok {
+			dst[key] = value
+		}
+	}
+}
+
+// stringList returns a JSON array of strings, or fallback when it is missing
+func stringList(value any, fallback ...string) []string {
+	items, _ := value.([]any)
+	var out []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return fallback
+	}
+	return out
+}
+
+func cloneJSON(spec map[string]any) map[string]any {
+	data, _ := json.Marshal(spec)
+	var clone map[string]any
+	json.Unmarshal(data, &clone)
+	return clone
+}
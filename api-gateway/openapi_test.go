@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenAPIDocsRetriesMissingSource(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "swagger.json")
+	docs := NewOpenAPIDocs(NewGateway(&GatewayConfig{OpenAPI: OpenAPIConfig{
+		CacheTTL: time.Hour,
+		Timeout:  time.Second,
+		Sources:  []OpenAPISourceConfig{{Upstream: "tours", File: file}},
+	}}))
+	status := func() string {
+		var doc struct {
+			Sources []openAPISourceStatus `json:"x-gateway-sources"`
+		}
+		if err := json.Unmarshal(docs.document(context.Background()), &doc); err != nil {
+			t.Fatal(err)
+		}
+		return doc.Sources[0].Status
+	}
+
+	if got := status(); got != "unavailable" {
+		t.Fatalf("status without the spec = %q, want unavailable", got)
+	}
+	if err := os.WriteFile(file, []byte(`{"swagger":"2.0","paths":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "unavailable" {
+		t.Fatalf("status right after = %q, want the cached unavailable", got)
+	}
+	if ttl := time.Until(docs.expires); ttl <= 0 || ttl > openAPIRetryInterval {
+		t.Fatalf("incomplete document cached for %s, want at most %s", ttl, openAPIRetryInterval)
+	}
+
+	docs.expires = time.Now()
+	if got := status(); got != "ok" {
+		t.Fatalf("status after the retry interval = %q, want ok", got)
+	}
+	if ttl := time.Until(docs.expires); ttl <= openAPIRetryInterval {
+		t.Fatalf("complete document cached for %s, want the cache TTL", ttl)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// routePolicy enforces the method allow-list, body limit and deadline of a
+// route before the request reaches the proxy. A Content-Length over the limit
+// is refused right away; chunked bodies are cut off by MaxBytesReader while
+// proxying and answered by Upstream.handleError.
+func routePolicy(rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !rt.allowsMethod(r.Method) {
+			w.Header().Set("Allow", strings.Join(rt.allowedMethods(), ", "))
+			writeJSONError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" is not allowed for this route")
+			return
+		}
+		if rt.MaxBodyBytes > 0 {
+			if r.ContentLength > rt.MaxBodyBytes {
+				writeJSONError(w, http.StatusRequestEntityTooLarge, bodyTooLargeMessage(rt.MaxBodyBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, rt.MaxBodyBytes)
+		}
+		if rt.Timeout > 0 && streamProtocol(r) == "" {
+			ctx, cancel := context.WithTimeout(r.Context(), rt.Timeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rt *route) allowsMethod(method string) bool {
+	if len(rt.Methods) == 0 || slices.Contains(rt.Methods, method) {
+		return true
+	}
+	return method == http.MethodHead && slices.Contains(rt.Methods, http.MethodGet)
+}
+
+// allowedMethods lists the methods for the Allow header of a 405
+func (rt *route) allowedMethods() []string {
+	methods := slices.Clone(rt.Methods)
+	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	return methods
+}
+
+func bodyTooLargeMessage(limit int64) string {
+	return fmt.Sprintf("Request body is larger than %d bytes", limit)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errNoInstances is returned when discovery has not found any instance yet
+var errNoInstances = errors.New("no upstream instances available")
+
+// instance is one server of an upstream pool
+type instance struct {
+	url      *url.URL
+	inFlight atomic.Int64
+
+	// guarded by Pool.mu
+	healthy        bool // verdict of the active probes
+	probeFailures  int
+	probeSuccesses int
+	failures       int // consecutive failed requests (passive)
+	ejectedUntil   time.Time
+}
+
+// InstanceStatus is a snapshot of an instance
+type InstanceStatus struct {
+	URL      string
+	Healthy  bool
+	Ejected  bool
+	InFlight int64
+}
+
+// Pool holds the instances of an upstream and picks one for every request.
+// Instances are probed in the background; ones failing their probes or
+// ejected after repeated failures are skipped until they recover. When no
+// instance is usable all of them are tried anyway, so a broken health check
+// cannot take the whole upstream down. Pools outlive config reloads.
+type Pool struct {
+	name string
+
+	mu        sync.Mutex
+	config    UpstreamConfig
+	instances []*instance
+	next      int
+	now       func() time.Time
+
+	client *http.Client
+	stop   chan struct{}
+}
+
+func NewPool(name string, config UpstreamConfig) *Pool {
+	p := &Pool{name: name, now: time.Now, client: &http.Client{}, stop: make(chan struct{})}
+	p.Configure(config)
+	go p.run()
+	return p
+}
+
+// Configure applies new settings, keeping the state of instances that are
+// still part of the pool
+func (p *Pool) Configure(config UpstreamConfig) {
+	p.mu.Lock()
+	p.config = config
+	p.mu.Unlock()
+
+	if config.Discovery != nil {
+		p.discover()
+	} else {
+		p.setInstances(config.Instances)
+	}
+}
+
+// Stop ends the background probes of a pool removed from the config
+func (p *Pool) Stop() {
+	close(p.stop)
+	upstreamInstanceUp.DeletePartialMatch(map[string]string{"upstream": p.name})
+}
+
+// pick returns the instance for the next attempt, avoiding exclude (the
+// instance of the previous attempt) when there is another one
+func (p *Pool) pick(exclude *instance) *instance {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.instances) == 0 {
+		return nil
+	}
+
+	now := p.now()
+	var candidates []*instance
+	for _, inst := range p.instances {
+		if inst != exclude && inst.usable(now) {
+			candidates = append(candidates, inst)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, inst := range p.instances {
+			if inst != exclude {
+				candidates = append(candidates, inst)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = p.instances
+	}
+
+	p.next++
+	start := p.next % len(candidates)
+	if p.config.Balancer != BalancerLeastConnections {
+		return candidates[start]
+	}
+	// Least connections, ties broken in round-robin order
+	best := candidates[start]
+	for i := 1; i < len(candidates); i++ {
+		candidate := candidates[(start+i)%len(candidates)]
+		if candidate.inFlight.Load() < best.inFlight.Load() {
+			best = candidate
+		}
+	}
+	return best
+}
+
+// report records the outcome of a request for passive ejection
+func (p *Pool) report(inst *instance, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !failed {
+		inst.failures = 0
+		return
+	}
+	inst.failures++
+	if inst.failures < p.config.HealthCheck.PassiveFailures {
+		return
+	}
+	inst.failures = 0
+	inst.ejectedUntil = p.now().Add(p.config.HealthCheck.EjectionTime)
+	upstreamEjections.WithLabelValues(p.name, "passive").Inc()
+	log.Printf("[POOL] %s: ejected %s for %s after %d consecutive failures",
+		p.name, inst.url.Host, p.config.HealthCheck.EjectionTime, p.config.HealthCheck.PassiveFailures)
+	p.updateGauge(inst)
+}
+
+// Snapshot returns the current instances
+func (p *Pool) Snapshot() []InstanceStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	statuses := make([]InstanceStatus, 0, len(p.instances))
+	for _, inst := range p.instances {
+		statuses = append(statuses, InstanceStatus{
+			URL:      inst.url.String(),
+			Healthy:  inst.healthy,
+			Ejected:  now.Before(inst.ejectedUntil),
+			InFlight: inst.inFlight.Load(),
+		})
+	}
+	return statuses
+}
+
+func (p *Pool) run() {
+	lastDiscovery := time.Now()
+	for {
+		p.mu.Lock()
+		interval := p.config.HealthCheck.Interval
+		discovery := p.config.Discovery
+		p.mu.Unlock()
+
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(interval):
+		}
+		if discovery != nil && time.Since(lastDiscovery) >= discovery.Refresh {
+			p.discover()
+			lastDiscovery = time.Now()
+		}
+		p.probeAll()
+	}
+}
+
+// probeAll runs the active health check of every instance concurrently
+func (p *Pool) probeAll() {
+	p.mu.Lock()
+	instances := append([]*instance(nil), p.instances...)
+	healthPath := p.config.HealthPath
+	timeout := p.config.HealthCheck.Timeout
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, inst := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			_, err := probeHealth(ctx, p.client, inst.url.String()+healthPath)
+			p.recordProbe(inst, err)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, inst := range p.instances {
+		p.updateGauge(inst)
+	}
+}
+
+func (p *Pool) recordProbe(inst *instance, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	settings := p.config.HealthCheck
+	if err == nil {
+		inst.probeFailures = 0
+		inst.probeSuccesses++
+		if !inst.healthy && inst.probeSuccesses >= settings.HealthyThreshold {
+			inst.healthy = true
+			log.Printf("[POOL] %s: %s is healthy again", p.name, inst.url.Host)
+		}
+		return
+	}
+	inst.probeSuccesses = 0
+	inst.probeFailures++
+	if inst.healthy && inst.probeFailures >= settings.UnhealthyThreshold {
+		inst.healthy = false
+		upstreamEjections.WithLabelValues(p.name, "active").Inc()
+		log.Printf("[POOL] %s: %s failed %d health checks: %v", p.name, inst.url.Host, inst.probeFailures, err)
+	}
+}
+
+// discover refreshes the instances from DNS; on failure the current
+// instances are kept
+func (p *Pool) discover() {
+	p.mu.Lock()
+	discovery := *p.config.Discovery
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var urls []string
+	if discovery.SRV != "" {
+		_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", discovery.SRV)
+		if err != nil {
+			log.Printf("[POOL] %s: SRV lookup of %s failed, keeping current instances: %v", p.name, discovery.SRV, err)
+			return
+		}
+		for _, record := range records {
+			host := net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port)))
+			urls = append(urls, discovery.Scheme+"://"+host)
+		}
+	} else {
+		host, port, _ := net.SplitHostPort(discovery.Host)
+		addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			log.Printf("[POOL] %s: lookup of %s failed, keeping current instances: %v", p.name, host, err)
+			return
+		}
+		for _, address := range addresses {
+			urls = append(urls, discovery.Scheme+"://"+net.JoinHostPort(address, port))
+		}
+	}
+	sort.Strings(urls)
+	p.setInstances(urls)
+}
+
+// setInstances replaces the instance list, keeping the state of instances
+// that are still present. New instances start healthy.
+func (p *Pool) setInstances(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := map[string]*instance{}
+	for _, inst := range p.instances {
+		current[inst.url.String()] = inst
+	}
+
+	instances := make([]*instance, 0, len(urls))
+	for _, raw := range urls {
+		if inst, ok := current[raw]; ok {
+			instances = append(instances, inst)
+			delete(current, raw)
+			continue
+		}
+		// URLs were validated by GatewayConfig.Validate or built by discover
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		inst := &instance{url: parsed, healthy: true}
+		instances = append(instances, inst)
+		p.updateGauge(inst)
+	}
+	for _, removed := range current {
+		upstreamInstanceUp.DeleteLabelValues(p.name, removed.url.Host)
+	}
+	if len(current) > 0 || len(instances) != len(p.instances) {
+		log.Printf("[POOL] %s: %d instances", p.name, len(instances))
+	}
+	p.instances = instances
+}
+
+func (p *Pool) updateGauge(inst *instance) {
+	up := 0.0
+	if inst.usable(p.now()) {
+		up = 1
+	}
+	upstreamInstanceUp.WithLabelValues(p.name, inst.url.Host).Set(up)
+}
+
+func (inst *instance) usable(now time.Time) bool {
+	return inst.healthy && !now.Before(inst.ejectedUntil)
+}
+
+// trackedBody runs done once when the response body is closed, which is when
+// a request stops counting towards its instance's connections
+type trackedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// trackedConn keeps the body of a 101 response writable, the reverse proxy
+// uses it as the upgraded connection
+type trackedConn struct {
+	*trackedBody
+	io.Writer
+}
+
+func trackBody(body io.ReadCloser, done func()) io.ReadCloser {
+	tracked := &trackedBody{ReadCloser: body, done: done}
+	if conn, ok := body.(io.ReadWriteCloser); ok {
+		return &trackedConn{trackedBody: tracked, Writer: conn}
+	}
+	return tracked
+}
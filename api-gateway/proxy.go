@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Upstream is a proxied backend service with its own transport, breaker and
+// pool of instances. Client shares that transport for calls the gateway
+// makes itself (BFF). StreamProxy carries WebSocket and SSE connections (see
+// stream.go).
+//
+// Target.Host is the upstream name: the transport replaces scheme and host
+// with the instance it picks for each attempt.
+type Upstream struct {
+	Name        string
+	Target      *url.URL
+	Proxy       *httputil.ReverseProxy
+	StreamProxy *httputil.ReverseProxy
+	Client      *http.Client
+	Breaker     *CircuitBreaker
+	Pool        *Pool
+}
+
+func newUpstream(name string, config UpstreamConfig, breaker *CircuitBreaker, pool *Pool) *Upstream {
+	target := &url.URL{Host: name}
+	if len(config.Instances) > 0 {
+		// URLs were validated by GatewayConfig.Validate
+		first, _ := url.Parse(config.Instances[0])
+		target.Scheme, target.Path = first.Scheme, first.Path
+	} else {
+		target.Scheme = config.Discovery.Scheme
+	}
+	u := &Upstream{Name: name, Target: target, Breaker: breaker, Pool: pool}
+
+	transport := &upstreamTransport{
+		base: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: config.Timeouts.Dial, KeepAlive: 30 * time.Second}).DialContext,
+			TLSHandshakeTimeout:   config.Timeouts.Dial,
+			ResponseHeaderTimeout: config.Timeouts.Response,
+			IdleConnTimeout:       config.Timeouts.Idle,
+			MaxIdleConnsPerHost:   32,
+		},
+		name:    name,
+		breaker: breaker,
+		pool:    pool,
+		retry:   *config.Retry,
+	}
+	u.Client = &http.Client{Transport: transport}
+	u.Proxy = u.newProxy(transport)
+
+	// Streams get fresh connections that close when idle and are never
+	// retried, a dropped stream is reconnected by the client
+	u.StreamProxy = u.newProxy(&upstreamTransport{
+		base: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           streamDialer(&net.Dialer{Timeout: config.Timeouts.Dial, KeepAlive: 30 * time.Second}),
+			TLSHandshakeTimeout:   config.Timeouts.Dial,
+			ResponseHeaderTimeout: config.Timeouts.Response,
+			DisableKeepAlives:     true,
+		},
+		name:    name,
+		breaker: breaker,
+		pool:    pool,
+	})
+	u.StreamProxy.FlushInterval = -1
+	return u
+}
+
+func (u *Upstream) newProxy(transport http.RoundTripper) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(u.Target)
+	proxy.Transport = transport
+	proxy.ModifyResponse = modifyResponse
+	proxy.ErrorHandler = u.handleError
+	return proxy
+}
+
+// modifyResponse drops the upstream CORS headers, including Vary: Origin:
+// CORS is owned by the gateway (see corsMiddleware), so the client never sees
+// duplicated or conflicting values. The request id was already set on the
+// response by loggingMiddleware.
+func modifyResponse(resp *http.Response) error {
+	resp.Header.Del(RequestIDHeader)
+	for header := range resp.Header {
+		if strings.HasPrefix(header, "Access-Control-") {
+			resp.Header.Del(header)
+		}
+	}
+	var vary []string
+	for _, value := range resp.Header.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field != "" && field != "Origin" && !strings.HasPrefix(field, "Access-Control-") {
+				vary = append(vary, field)
+			}
+		}
+	}
+	resp.Header.Del("Vary")
+	if len(vary) > 0 {
+		resp.Header.Set("Vary", strings.Join(vary, ", "))
+	}
+	return nil
+}
+
+// handleError turns transport failures into a consistent JSON error body
+func (u *Upstream) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if clientCanceled(r, err) {
+		// The client went away, nobody is left to read the response
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		// The client sent more than the route allows, not an upstream failure
+		writeJSONError(w, http.StatusRequestEntityTooLarge, bodyTooLargeMessage(tooLarge.Limit))
+		return
+	}
+
+	status := http.StatusBadGateway
+	message := "Upstream service unavailable"
+	reason := "unavailable"
+	var netErr net.Error
+	switch {
+	case errors.Is(err, errNoInstances):
+		status = http.StatusServiceUnavailable
+		reason = "no_instances"
+		message = "No upstream instances available"
+	case errors.Is(err, ErrCircuitOpen):
+		status = http.StatusServiceUnavailable
+		reason = "circuit_open"
+		message = "Upstream service temporarily disabled after repeated failures"
+		retryAfter := int(math.Ceil(u.Breaker.RetryAfter().Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		status = http.StatusGatewayTimeout
+		message = "Upstream service timed out"
+		reason = "timeout"
+	}
+	upstreamErrors.WithLabelValues(u.Name, reason).Inc()
+
+	slog.Warn("upstream request failed",
+		"request_id", RequestIDFromContext(r.Context()),
+		"method", r.Method,
+		"path", r.URL.Path,
+		"upstream", u.Name,
+		"status", status,
+		"error", err.Error(),
+	)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message, "upstream": u.Name})
+}
+
+// clientCanceled reports whether err only means that the client went away
+func clientCanceled(r *http.Request, err error) bool {
+	return errors.Is(err, context.Canceled) && r.Context().Err() != nil
+}
+
+// upstreamTransport sends every attempt to an instance picked from the pool,
+// guarded by the circuit breaker, and retries idempotent requests without a
+// body on connection errors and 502/503/504 responses, with exponential
+// backoff, preferring another instance
+type upstreamTransport struct {
+	name    string
+	base    http.RoundTripper
+	breaker *CircuitBreaker
+	pool    *Pool
+	retry   RetryConfig
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if isRetryable(req) {
+		attempts += t.retry.Attempts
+	}
+
+	var resp *http.Response
+	var err error
+	var target *instance
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			upstreamRetries.WithLabelValues(t.name).Inc()
+			backoff := t.retry.Backoff * time.Duration(1<<(attempt-1))
+			select {
+			case <-time.After(backoff):
+			case <-req.Context().Done():
+				return nil, req.Context().Err()
+			}
+		}
+
+		if target = t.pool.pick(target); target == nil {
+			return nil, errNoInstances
+		}
+		if err = t.breaker.Allow(); err != nil {
+			return nil, err
+		}
+		resp, err = t.send(req, target)
+		if tooLarge := new(*http.MaxBytesError); errors.As(err, tooLarge) {
+			// The client's fault (see routePolicy), the upstream is fine
+			t.breaker.Release()
+			return nil, err
+		}
+		canceled := clientCanceled(req, err)
+		failed := err != nil || isUpstreamFailure(resp.StatusCode)
+		if canceled {
+			// Says nothing about the upstream: a trial request must not
+			// open the circuit again, nor count toward passive ejection
+			t.breaker.Release()
+		} else {
+			t.breaker.Record(!failed)
+			t.pool.report(target, err != nil || resp.StatusCode >= 500)
+		}
+
+		if !failed || attempt == attempts-1 || req.Context().Err() != nil {
+			break
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+	return resp, err
+}
+
+// send makes one attempt against an instance; the attempt counts as a
+// connection of the instance until its response body is closed
+func (t *upstreamTransport) send(req *http.Request, target *instance) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = target.url.Scheme
+	out.URL.Host = target.url.Host
+
+	target.inFlight.Add(1)
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		target.inFlight.Add(-1)
+		return nil, err
+	}
+	resp.Body = trackBody(resp.Body, func() { target.inFlight.Add(-1) })
+	return resp, nil
+}
+
+func isRetryable(req *http.Request) bool {
+	return (req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+}
+
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
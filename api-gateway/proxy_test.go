@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		method string
+		body   io.Reader
+		want   bool
+	}{
+		{http.MethodGet, nil, true},
+		{http.MethodHead, nil, true},
+		{http.MethodGet, strings.NewReader("{}"), false},
+		{http.MethodPost, nil, false},
+		{http.MethodPut, nil, false},
+		{http.MethodPatch, nil, false},
+		{http.MethodDelete, nil, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/tours", tt.body)
+		if got := isRetryable(req); got != tt.want {
+			t.Errorf("isRetryable(%s, body %v) = %v, want %v", tt.method, tt.body != nil, got, tt.want)
+		}
+	}
+}
+
+func TestIsUpstreamFailure(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     false,
+		http.StatusInternalServerError: false,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusGatewayTimeout:      true,
+	} {
+		if got := isUpstreamFailure(status); got != want {
+			t.Errorf("isUpstreamFailure(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
+
+// scriptedUpstream answers with the given statuses in turn (the last one
+// repeats) and records when each request arrived
+type scriptedUpstream struct {
+	mu       sync.Mutex
+	statuses []int
+	calls    []time.Time
+}
+
+func (s *scriptedUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.statuses[min(len(s.calls), len(s.statuses)-1)]
+	s.calls = append(s.calls, time.Now())
+	w.WriteHeader(status)
+}
+
+func newTestTransport(t *testing.T, upstreamURL string, retry RetryConfig, breaker CircuitBreakerConfig) *upstreamTransport {
+	pool := NewPool("test", UpstreamConfig{
+		Instances:   []string{upstreamURL},
+		HealthCheck: &HealthCheckConfig{Interval: time.Hour, PassiveFailures: 100, EjectionTime: time.Second},
+	})
+	t.Cleanup(pool.Stop)
+	return &upstreamTransport{
+		name:    "test",
+		base:    http.DefaultTransport,
+		breaker: NewCircuitBreaker("test", breaker),
+		pool:    pool,
+		retry:   retry,
+	}
+}
+
+func TestUpstreamTransportRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		statuses   []int
+		wantStatus int
+		wantCalls  int
+	}{
+		{"GET recovers after 503s", http.MethodGet, []int{503, 503, 200}, 200, 3},
+		{"GET gives up after all attempts", http.MethodGet, []int{502}, 502, 3},
+		{"GET retries 504", http.MethodGet, []int{504, 200}, 200, 2},
+		{"GET does not retry 500", http.MethodGet, []int{500, 200}, 500, 1},
+		{"GET does not retry 404", http.MethodGet, []int{404, 200}, 404, 1},
+		{"POST is never retried", http.MethodPost, []int{503, 200}, 503, 1},
+		{"DELETE is never retried", http.MethodDelete, []int{503, 200}, 503, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &scriptedUpstream{statuses: tt.statuses}
+			server := httptest.NewServer(upstream)
+			defer server.Close()
+			transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 2, Backoff: time.Millisecond},
+				CircuitBreakerConfig{FailureThreshold: 100, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+
+			req := httptest.NewRequest(tt.method, "http://test/api/tours", nil)
+			req.RequestURI = ""
+			resp, err := transport.RoundTrip(req)
+			if err != nil {
+				t.Fatalf("RoundTrip() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if len(upstream.calls) != tt.wantCalls {
+				t.Errorf("upstream got %d requests, want %d", len(upstream.calls), tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestUpstreamTransportBackoffDoubles(t *testing.T) {
+	upstream := &scriptedUpstream{statuses: []int{503}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	backoff := 30 * time.Millisecond
+	transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 2, Backoff: backoff},
+		CircuitBreakerConfig{FailureThreshold: 100, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+
+	req := httptest.NewRequest(http.MethodGet, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if len(upstream.calls) != 3 {
+		t.Fatalf("upstream got %d requests, want 3", len(upstream.calls))
+	}
+	for i, want := range []time.Duration{backoff, 2 * backoff} {
+		if gap := upstream.calls[i+1].Sub(upstream.calls[i]); gap < want {
+			t.Errorf("retry %d came after %s, want at least %s", i+1, gap, want)
+		}
+	}
+}
+
+func TestUpstreamTransportCircuitBreaker(t *testing.T) {
+	upstream := &scriptedUpstream{statuses: []int{503}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 0, Backoff: time.Millisecond},
+		CircuitBreakerConfig{FailureThreshold: 2, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "http://test/api/tours", nil)
+		req.RequestURI = ""
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("request %d: RoundTrip() error = %v", i+1, err)
+		}
+		resp.Body.Close()
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	if _, err := transport.RoundTrip(req); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("RoundTrip() error = %v, want ErrCircuitOpen", err)
+	}
+	if len(upstream.calls) != 2 {
+		t.Errorf("upstream got %d requests, want 2 (open circuit must not call it)", len(upstream.calls))
+	}
+}
+
+func TestUpstreamTransportTooLargeReleasesTrial(t *testing.T) {
+	upstream := &scriptedUpstream{statuses: []int{200}}
+	server := httptest.NewServer(upstream)
+	defer server.Close()
+	transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 0, Backoff: time.Millisecond},
+		CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+	now := time.Unix(1700000000, 0)
+	transport.breaker.now = func() time.Time { return now }
+	transport.breaker.Allow()
+	transport.breaker.Record(false)
+	now = now.Add(time.Minute)
+
+	// Chunked, so only MaxBytesReader notices the body is too large
+	req := httptest.NewRequest(http.MethodPost, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	req.ContentLength = -1
+	req.Body = http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader(strings.Repeat("x", 1<<16))), 1024)
+	_, err := transport.RoundTrip(req)
+	if tooLarge := new(*http.MaxBytesError); !errors.As(err, tooLarge) {
+		t.Fatalf("RoundTrip() error = %v, want *http.MaxBytesError", err)
+	}
+	if state := transport.breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state = %s, want %s", state, CircuitHalfOpen)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("trial after the rejected upload: RoundTrip() error = %v", err)
+	}
+	resp.Body.Close()
+	if state := transport.breaker.State(); state != CircuitClosed {
+		t.Errorf("state = %s, want %s", state, CircuitClosed)
+	}
+}
+
+// hangingUpstream answers only after the client has gone away
+func hangingUpstream() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+}
+
+func TestUpstreamTransportClientCancel(t *testing.T) {
+	server := httptest.NewServer(hangingUpstream())
+	defer server.Close()
+	transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 2, Backoff: time.Millisecond},
+		CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+	now := time.Unix(1700000000, 0)
+	transport.breaker.now = func() time.Time { return now }
+	transport.breaker.Allow()
+	transport.breaker.Record(false)
+	now = now.Add(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RoundTrip() error = %v, want context.Canceled", err)
+	}
+	if state := transport.breaker.State(); state != CircuitHalfOpen {
+		t.Fatalf("state after a client cancel = %s, want %s", state, CircuitHalfOpen)
+	}
+	if err := transport.breaker.Allow(); err != nil {
+		t.Fatalf("trial after a client cancel: Allow() = %v", err)
+	}
+}
+
+func TestUpstreamTransportClientCancelKeepsInstance(t *testing.T) {
+	server := httptest.NewServer(hangingUpstream())
+	defer server.Close()
+	transport := newTestTransport(t, server.URL, RetryConfig{Attempts: 0, Backoff: time.Millisecond},
+		CircuitBreakerConfig{FailureThreshold: 100, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+	transport.pool.Configure(UpstreamConfig{
+		Instances:   []string{server.URL},
+		HealthCheck: &HealthCheckConfig{Interval: time.Hour, PassiveFailures: 1, EjectionTime: time.Minute},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "http://test/api/tours", nil)
+	req.RequestURI = ""
+	if _, err := transport.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RoundTrip() error = %v, want context.Canceled", err)
+	}
+	if status := transport.pool.Snapshot()[0]; status.Ejected {
+		t.Fatalf("instance %s ejected after a client cancel", status.URL)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestUpstreamHandleError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		retryAfter string
+	}{
+		{"connection refused", errors.New("dial tcp: connection refused"), http.StatusBadGateway, ""},
+		{"no instances", errNoInstances, http.StatusServiceUnavailable, ""},
+		{"open circuit", ErrCircuitOpen, http.StatusServiceUnavailable, "1"},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout, ""},
+		{"network timeout", timeoutError{}, http.StatusGatewayTimeout, ""},
+		{"body too large", &http.MaxBytesError{Limit: 1024}, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &Upstream{Name: "test", Breaker: NewCircuitBreaker("test", CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1})}
+			recorder := httptest.NewRecorder()
+			upstream.handleError(recorder, httptest.NewRequest(http.MethodGet, "/api/tours", nil), tt.err)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
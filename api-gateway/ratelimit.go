@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limit keys
+const (
+	RateLimitKeyUser = "user" // authenticated user id, client IP for anonymous requests
+	RateLimitKeyIP   = "ip"   // always the client IP
+)
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets. The in-memory store is enough for a
+// single gateway instance; a shared store (e.g. Redis running the same
+// algorithm in a Lua script) can be plugged in for multiple instances.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (RateLimitResult, error)
+}
+
+// Rate limit store kinds
+const (
+	RateLimitStoreMemory = "memory"
+)
+
+// NewRateLimitStore creates the store selected in the config. The store is
+// picked once at startup; changing it requires a restart.
+func NewRateLimitStore(kind string) (RateLimitStore, error) {
+	switch kind {
+	case "", RateLimitStoreMemory:
+		return NewMemoryRateLimitStore(time.Minute), nil
+	}
+	return nil, fmt.Errorf("unknown rate limit store %q", kind)
+}
+
+type tokenBucket struct {
+	tokens   float64
+	updated  time.Time
+	fullTime time.Duration // how long an untouched bucket takes to refill completely
+}
+
+// MemoryRateLimitStore is an in-process token bucket store
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewMemoryRateLimitStore creates the store and starts a janitor that drops
+// buckets which have been idle long enough to be full again
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: time.Now}
+	go func() {
+		for range time.Tick(cleanupInterval) {
+			s.cleanup()
+		}
+	}()
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, rate float64, burst int) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.fullTime = time.Duration(float64(burst) / rate * float64(time.Second))
+
+	elapsed := now.Sub(bucket.updated).Seconds()
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+elapsed*rate)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return RateLimitResult{Allowed: true, Remaining: int(bucket.tokens)}, nil
+	}
+	wait := (1 - bucket.tokens) / rate
+	return RateLimitResult{Allowed: false, RetryAfter: time.Duration(wait * float64(time.Second))}, nil
+}
+
+func (s *MemoryRateLimitStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) > bucket.fullTime {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RateLimiter enforces the rate limit rules of the active configuration
+type RateLimiter struct {
+	gateway *Gateway
+	store   RateLimitStore
+}
+
+func NewRateLimiter(gateway *Gateway, store RateLimitStore) *RateLimiter {
+	return &RateLimiter{gateway: gateway, store: store}
+}
+
+// Middleware must run after authMiddleware so per-user limits can see the identity
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := l.gateway.Config().RateLimit
+		rule := config.match(r.URL.Path)
+		if rule == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := rule.Name + ":" + rateLimitSubject(r, rule.Key, config.TrustForwardedFor)
+		result, err := l.store.Take(r.Context(), key, rule.Rate, rule.Burst)
+		if err != nil {
+			// Fail open: a broken limiter store must not take the gateway down
+			log.Printf("[RATE LIMIT] Store error for %s: %v", key, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(rule.Burst))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		if !result.Allowed {
+			retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			log.Printf("[RATE LIMIT] %s exceeded rule %q on %s %s", key, rule.Name, r.Method, r.URL.Path)
+			writeJSONError(w, http.StatusTooManyRequests, "Too many requests, retry in "+strconv.Itoa(retryAfter)+"s")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// match returns the rule with the longest prefix matching the path
+func (c *RateLimitConfig) match(path string) *RateLimitRule {
+	var best *RateLimitRule
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if path != rule.Prefix && !strings.HasPrefix(path, rule.Prefix+"/") {
+			continue
+		}
+		if best == nil || len(rule.Prefix) > len(best.Prefix) {
+			best = rule
+		}
+	}
+	return best
+}
+
+func rateLimitSubject(r *http.Request, key string, trustForwardedFor bool) string {
+	if key == RateLimitKeyUser {
+		if partner, ok := PartnerFromContext(r.Context()); ok {
+			return "apikey:" + partner.KeyID
+		}
+		if identity, ok := IdentityFromContext(r.Context()); ok {
+			return "user:" + identity.UserID
+		}
+	}
+	return "ip:" + clientIP(r, trustForwardedFor)
+}
+
+// clientIP returns the caller address. X-Forwarded-For is only honoured when
+// the gateway sits behind a trusted proxy, otherwise clients could pick their
+// own bucket.
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	type take struct {
+		after      time.Duration // time since the previous take
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		takes []take
+	}{
+		{"burst then rejected", 1, 3, []take{
+			{0, true, 2, 0},
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, time.Second},
+		}},
+		{"refills at the rate", 2, 2, []take{
+			{0, true, 1, 0},
+			{0, true, 0, 0},
+			{250 * time.Millisecond, false, 0, 250 * time.Millisecond},
+			{250 * time.Millisecond, true, 0, 0},
+		}},
+		{"never exceeds the burst", 10, 2, []take{
+			{0, true, 1, 0},
+			{time.Hour, true, 1, 0},
+			{0, true, 0, 0},
+			{0, false, 0, 100 * time.Millisecond},
+		}},
+		{"rejections do not use tokens", 1, 1, []take{
+			{0, true, 0, 0},
+			{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+			{500 * time.Millisecond, true, 0, 0},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			store := &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: func() time.Time { return now }}
+
+			for i, want := range tt.takes {
+				now = now.Add(want.after)
+				got, err := store.Take(context.Background(), "rule:ip:10.0.0.1", tt.rate, tt.burst)
+				if err != nil {
+					t.Fatalf("take %d: %v", i, err)
+				}
+				if got.Allowed != want.allowed || got.Remaining != want.remaining || got.RetryAfter != want.retryAfter {
+					t.Fatalf("take %d = %+v, want allowed %v remaining %d retry after %s",
+						i, got, want.allowed, want.remaining, want.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreKeysAreSeparate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: func() time.Time { return now }}
+
+	if result, _ := store.Take(context.Background(), "rule:user:1", 1, 1); !result.Allowed {
+		t.Fatal("first request of user 1 was rejected")
+	}
+	if result, _ := store.Take(context.Background(), "rule:user:2", 1, 1); !result.Allowed {
+		t.Fatal("user 2 shares the bucket of user 1")
+	}
+	if result, _ := store.Take(context.Background(), "rule:user:1", 1, 1); result.Allowed {
+		t.Fatal("second request of user 1 was allowed")
+	}
+}
+
+func TestMemoryRateLimitStoreCleanup(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}, now: func() time.Time { return now }}
+	store.Take(context.Background(), "idle", 1, 10)
+	store.Take(context.Background(), "busy", 1, 10)
+
+	now = now.Add(9 * time.Second)
+	store.Take(context.Background(), "busy", 1, 10)
+	now = now.Add(2 * time.Second)
+	store.cleanup()
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("bucket idle for longer than its refill time was kept")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Error("bucket that is not full yet was dropped")
+	}
+}
+
+func TestRateLimitRuleMatch(t *testing.T) {
+	config := RateLimitConfig{Rules: []RateLimitRule{
+		{Name: "api", Prefix: "/api"},
+		{Name: "tours", Prefix: "/api/tours"},
+	}}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/tours", "tours"},
+		{"/api/tours/1/reviews", "tours"},
+		{"/api/toursxyz", "api"},
+		{"/api/blogs", "api"},
+		{"/graphql", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if rule := config.match(tt.path); rule != nil {
+			got = rule.Name
+		}
+		if got != tt.want {
+			t.Errorf("match(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,183 +1,235 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"net/http/httputil"
-	"net/url"
-
-	"github.com/gorilla/mux"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 )
 
-// setupStakeholdersRoutes sets up routes for stakeholders service
-func setupStakeholdersRoutes(router *mux.Router) {
-	stakeholdersURL, _ := url.Parse("http://stakeholders-service:8081")
-	stakeholdersProxy := httputil.NewSingleHostReverseProxy(stakeholdersURL)
-
-	// Custom response modifier to ensure CORS headers
-	stakeholdersProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
-	}
-
-	// Handle OPTIONS preflight requests for stakeholders
-	router.PathPrefix("/api/stakeholders/").Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-		w.WriteHeader(http.StatusOK)
-	})
+// Gateway dispatches requests using the currently active route table. The
+// table is swapped atomically when the configuration is reloaded, so
+// in-flight requests keep using the table they started with.
+//
+// Circuit breakers outlive reloads so an upstream that is failing stays open
+// when an unrelated part of the file changes. gRPC connections (per address)
+// and stream connection counts (per route) are kept for the same reason.
+type Gateway struct {
+	table atomic.Pointer[routeTable]
+
+	mu             sync.Mutex
+	breakers       map[string]*CircuitBreaker
+	pools          map[string]*Pool
+	grpcClients    map[string]*grpcClient
+	streamLimiters map[string]*streamLimiter
+
+	// set on shutdown, see server.go
+	draining     atomic.Bool
+	streamsDone  chan struct{}
+	closeStreams sync.Once
+}
 
-	// Stakeholders service routes - keep full path
-	router.PathPrefix("/api/stakeholders/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		stakeholdersProxy.ServeHTTP(w, r)
-	})
+type routeTable struct {
+	config     *GatewayConfig
+	upstreams  map[string]*Upstream
+	routes     []*route // longest prefix first
+	grpcRoutes []*grpcRoute
 }
 
-// setupPaymentsRoutes sets up routes for payments service
-func setupPaymentsRoutes(router *mux.Router) {
-	paymentsURL, _ := url.Parse("http://payments-service:8080")
-	paymentsProxy := httputil.NewSingleHostReverseProxy(paymentsURL)
+type route struct {
+	RouteConfig
+	handler http.Handler
+}
 
-	// Custom response modifier to ensure CORS headers
-	paymentsProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
+// NewGateway builds a gateway from a validated configuration
+func NewGateway(config *GatewayConfig) *Gateway {
+	g := &Gateway{
+		breakers:       map[string]*CircuitBreaker{},
+		pools:          map[string]*Pool{},
+		grpcClients:    map[string]*grpcClient{},
+		streamLimiters: map[string]*streamLimiter{},
+		streamsDone:    make(chan struct{}),
 	}
+	g.Apply(config)
+	return g
+}
 
-	// Payments service routes - map /api/payments to /api/shopping-cart
-	router.PathPrefix("/api/payments/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Replace /api/payments with /api/shopping-cart for the backend service
-		r.URL.Path = "/api/shopping-cart" + r.URL.Path[len("/api/payments"):]
-		paymentsProxy.ServeHTTP(w, r)
+// Apply compiles the configuration into a new route table and activates it
+func (g *Gateway) Apply(config *GatewayConfig) {
+	table := &routeTable{config: config, upstreams: map[string]*Upstream{}}
+	for name, upstream := range config.Upstreams {
+		table.upstreams[name] = newUpstream(name, upstream, g.breaker(name, *upstream.CircuitBreaker), g.pool(name, upstream))
+	}
+	g.stopRemovedPools(config)
+
+	for _, rc := range config.Routes {
+		rt := &route{RouteConfig: rc}
+		handler := g.proxyHandler(rt, table.upstreams[rc.Upstream], config)
+		if rc.Canary != nil {
+			canary := g.proxyHandler(rt, table.upstreams[rc.Canary.Upstream], config)
+			handler = canaryHandler(rt, handler, canary, config.RateLimit.TrustForwardedFor)
+		}
+		if rc.Auth == AuthRequired {
+			handler = requireAuth(handler)
+		}
+		rt.handler = routePolicy(rt, handler)
+		table.routes = append(table.routes, rt)
+	}
+	sort.SliceStable(table.routes, func(i, j int) bool {
+		return len(table.routes[i].Prefix) > len(table.routes[j].Prefix)
 	})
+
+	for _, rc := range config.GRPC.Routes {
+		backend := config.GRPC.Backends[rc.Backend]
+		client, err := g.grpcClient(backend.Address)
+		if err != nil {
+			log.Printf("[GRPC] Route %s disabled: %v", rc.Name, err)
+			continue
+		}
+		rt := &grpcRoute{
+			GRPCRouteConfig: rc,
+			client:          client,
+			breaker:         g.breaker("grpc:"+rc.Backend, config.Proxy.CircuitBreaker),
+			timeout:         backend.Timeout,
+		}
+		rt.handler = rt
+		if rc.Auth == AuthRequired {
+			rt.handler = requireAuth(rt)
+		}
+		table.grpcRoutes = append(table.grpcRoutes, rt)
+	}
+
+	g.table.Store(table)
 }
 
-// setupBlogRoutes sets up routes for blog service
-func setupBlogRoutes(router *mux.Router) {
-	blogURL, _ := url.Parse("http://blog-service:8082")
-	blogProxy := httputil.NewSingleHostReverseProxy(blogURL)
+// proxyHandler proxies the requests of a route to one upstream
+func (g *Gateway) proxyHandler(rt *route, upstream *Upstream, config *GatewayConfig) http.Handler {
+	if rt.Stream != nil {
+		return streamProxyHandler(rt, upstream, g.streamLimiter(rt.Name, *rt.Stream), g.streamsDone, config)
+	}
+	return rejectUpgrade(rt, routeProxyHandler(rt, upstream.Name, upstream.Proxy))
+}
 
-	// Custom response modifier to ensure CORS headers
-	blogProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
+// breaker returns the circuit breaker of an upstream, keeping its state
+// across reloads
+func (g *Gateway) breaker(name string, settings CircuitBreakerConfig) *CircuitBreaker {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if b, ok := g.breakers[name]; ok {
+		b.Configure(settings)
+		return b
 	}
+	b := NewCircuitBreaker(name, settings)
+	g.breakers[name] = b
+	return b
+}
 
-	// Handle /api/blog without trailing slash FIRST (more specific route)
-	router.HandleFunc("/api/blog", func(w http.ResponseWriter, r *http.Request) {
-		r.URL.Path = "/api/blogs"
-		blogProxy.ServeHTTP(w, r)
-	})
-	
-	// Blog service routes - map /api/blog to /api/blogs
-	router.PathPrefix("/api/blog/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Replace /api/blog with /api/blogs for the backend service
-		r.URL.Path = "/api/blogs" + r.URL.Path[len("/api/blog"):]
-		blogProxy.ServeHTTP(w, r)
-	})
+// pool returns the instance pool of an upstream, keeping instance health
+// across reloads
+func (g *Gateway) pool(name string, config UpstreamConfig) *Pool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p, ok := g.pools[name]; ok {
+		p.Configure(config)
+		return p
+	}
+	p := NewPool(name, config)
+	g.pools[name] = p
+	return p
 }
 
-// setupToursRoutes sets up routes for tours service
-func setupToursRoutes(router *mux.Router) {
-	toursURL, _ := url.Parse("http://tours-service:8083")
-	toursProxy := httputil.NewSingleHostReverseProxy(toursURL)
+func (g *Gateway) stopRemovedPools(config *GatewayConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for name, p := range g.pools {
+		if _, ok := config.Upstreams[name]; !ok {
+			p.Stop()
+			delete(g.pools, name)
+		}
+	}
+}
 
-	// Custom response modifier to ensure CORS headers
-	toursProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
+// grpcClient returns the connection to a gRPC address, keeping it (and the
+// schemas loaded through it) across reloads
+func (g *Gateway) grpcClient(address string) (*grpcClient, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.grpcClients[address]; ok {
+		return c, nil
+	}
+	c, err := newGRPCClient(address)
+	if err != nil {
+		return nil, err
 	}
+	g.grpcClients[address] = c
+	return c, nil
+}
 
-	// Tours service routes - both with and without trailing slash
-	router.PathPrefix("/api/tours/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		toursProxy.ServeHTTP(w, r)
-	})
-	router.HandleFunc("/api/tours", func(w http.ResponseWriter, r *http.Request) {
-		toursProxy.ServeHTTP(w, r)
-	})
+// Upstream returns an upstream of the active configuration
+func (g *Gateway) Upstream(name string) *Upstream {
+	return g.table.Load().upstreams[name]
 }
 
-// setupEncountersRoutes sets up routes for encounters service
-func setupEncountersRoutes(router *mux.Router) {
-	encountersURL, _ := url.Parse("http://encounters-service:8084")
-	encountersProxy := httputil.NewSingleHostReverseProxy(encountersURL)
+// Config returns the active configuration
+func (g *Gateway) Config() *GatewayConfig {
+	return g.table.Load().config
+}
 
-	// Custom response modifier to ensure CORS headers
-	encountersProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	table := g.table.Load()
+	if rt := table.matchGRPC(r.Method, r.URL.Path); rt != nil {
+		rt.handler.ServeHTTP(w, r)
+		return
 	}
-
-	// Encounters service routes for tourist position
-	router.PathPrefix("/api/tourist-position/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		encountersProxy.ServeHTTP(w, r)
-	})
-	
-	// Encounters service routes for tour executions
-	router.PathPrefix("/api/tour-executions/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		encountersProxy.ServeHTTP(w, r)
-	})
-	
-	// Keep the original encounters prefix for compatibility
-	router.PathPrefix("/api/encounters/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		encountersProxy.ServeHTTP(w, r)
-	})
+	rt := table.match(r.URL.Path)
+	if rt == nil {
+		writeJSONError(w, http.StatusNotFound, "No route for "+r.URL.Path)
+		return
+	}
+	rt.handler.ServeHTTP(w, r)
 }
 
-// setupFollowerRoutes sets up routes for follower service
-func setupFollowerRoutes(router *mux.Router) {
-	followerURL, _ := url.Parse("http://follower-service:8086")
-	followerProxy := httputil.NewSingleHostReverseProxy(followerURL)
-
-	// Custom response modifier to ensure CORS headers
-	followerProxy.ModifyResponse = func(resp *http.Response) error {
-		resp.Header.Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		resp.Header.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		resp.Header.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		resp.Header.Set("Access-Control-Allow-Credentials", "true")
-		resp.Header.Set("Access-Control-Max-Age", "86400")
-		return nil
-	}
-
-	// Handle OPTIONS preflight requests for follower
-	router.PathPrefix("/api/followers/").Methods("OPTIONS").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:4200")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Accept, Origin, X-Requested-With")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-		w.WriteHeader(http.StatusOK)
-	})
+func (t *routeTable) match(path string) *route {
+	for _, rt := range t.routes {
+		if path == rt.Prefix || strings.HasPrefix(path, rt.Prefix+"/") {
+			return rt
+		}
+	}
+	return nil
+}
 
-	// Follower service routes - map /api/followers to root
-	router.PathPrefix("/api/followers/").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Replace /api/followers with empty string for the backend service
-		r.URL.Path = r.URL.Path[len("/api/followers"):]
-		if r.URL.Path == "" {
-			r.URL.Path = "/"
+// matchGRPC returns the transcoded route for a method and path; gRPC routes
+// take precedence over proxy prefixes
+func (t *routeTable) matchGRPC(method, path string) *grpcRoute {
+	for _, rt := range t.grpcRoutes {
+		if _, ok := rt.match(method, path); ok {
+			return rt
 		}
-		followerProxy.ServeHTTP(w, r)
+	}
+	return nil
+}
+
+// rewritePath replaces the route prefix with the configured rewrite target
+func (rt *route) rewritePath(path string) string {
+	if rt.Rewrite == nil {
+		return path
+	}
+	rewritten := *rt.Rewrite + strings.TrimPrefix(path, rt.Prefix)
+	if rewritten == "" {
+		return "/"
+	}
+	return rewritten
+}
+
+func routeProxyHandler(rt *route, upstream string, proxy *httputil.ReverseProxy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setLogUpstream(r, upstream)
+		r.URL.Path = rt.rewritePath(r.URL.Path)
+		r.URL.RawPath = ""
+		signForUpstream(r.Context(), r.Header, r.Method, r.URL.Path)
+		proxy.ServeHTTP(w, r)
 	})
 }
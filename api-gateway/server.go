@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+var tlsVersions = map[string]uint16{"1.2": tls.VersionTLS12, "1.3": tls.VersionTLS13}
+
+// serve runs the gateway until SIGINT or SIGTERM and then drains it: the
+// readiness probe fails, streams are closed and in-flight requests get up to
+// ShutdownTimeout to finish
+func serve(handler http.Handler, gateway *Gateway, config ServerConfig) {
+	server := newServer(config.Addr, handler, config)
+	server.RegisterOnShutdown(gateway.CloseStreams)
+	errs := make(chan error, 2)
+
+	go func() {
+		var err error
+		if config.TLS != nil {
+			server.TLSConfig = &tls.Config{MinVersion: tlsVersions[config.TLS.MinVersion]}
+			log.Printf("Listening on %s (HTTPS, HTTP/2)", config.Addr)
+			err = server.ListenAndServeTLS(config.TLS.CertFile, config.TLS.KeyFile)
+		} else {
+			log.Printf("Listening on %s (HTTP, h2c: %t)", config.Addr, config.H2C)
+			err = server.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	var redirect *http.Server
+	if config.TLS != nil && config.TLS.RedirectAddr != "" {
+		redirect = newServer(config.TLS.RedirectAddr, httpsRedirect(config.Addr, handler), config)
+		go func() {
+			log.Printf("Redirecting HTTP on %s to HTTPS", config.TLS.RedirectAddr)
+			if err := redirect.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		log.Fatalf("Server failed: %v", err)
+	case sig := <-quit:
+		log.Printf("Received %s, draining (delay %s, timeout %s)...", sig, config.DrainDelay, config.ShutdownTimeout)
+	}
+
+	gateway.Drain()
+	time.Sleep(config.DrainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Shutdown timed out, closing remaining connections: %v", err)
+		server.Close()
+	}
+	log.Println("Server exiting")
+}
+
+func newServer(addr string, handler http.Handler, config ServerConfig) *http.Server {
+	// Without TLS, HTTP/2 is only available as h2c, e.g. behind a proxy
+	// that terminates TLS
+	if config.H2C && config.TLS == nil {
+		handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: config.IdleTimeout})
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+	}
+}
+
+// httpsRedirect sends plain HTTP requests to the HTTPS address with 308, so
+// the method and body are kept. Health endpoints are still served for
+// probes that do not speak TLS.
+func httpsRedirect(tlsAddr string, health http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/health/") {
+			health.ServeHTTP(w, r)
+			return
+		}
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// Drain marks the gateway as shutting down; the readiness probe fails from
+// now on so load balancers stop sending new requests
+func (g *Gateway) Drain() {
+	g.draining.Store(true)
+}
+
+func (g *Gateway) Draining() bool {
+	return g.draining.Load()
+}
+
+// CloseStreams ends every open WebSocket and SSE connection. http.Server
+// does not wait for hijacked connections and would wait for SSE responses
+// until the shutdown timeout, so clients are told to reconnect instead.
+func (g *Gateway) CloseStreams() {
+	g.closeStreams.Do(func() {
+		close(g.streamsDone)
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stream protocols
+const (
+	StreamWebSocket = "websocket"
+	StreamSSE       = "sse"
+)
+
+// streamProtocol returns the streaming protocol a request asks for, or ""
+func streamProtocol(r *http.Request) string {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") && headerHasToken(r.Header, "Connection", "upgrade") {
+		return StreamWebSocket
+	}
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return StreamSSE
+	}
+	return ""
+}
+
+func headerHasToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// streamLimiter counts the open connections of one stream route. It lives
+// in the Gateway so the counts survive config reloads.
+type streamLimiter struct {
+	mu       sync.Mutex
+	settings StreamConfig
+	open     int
+	clients  map[string]int
+}
+
+// acquire reserves a connection slot for a client. It returns a release
+// function, or the reason the connection is refused.
+func (l *streamLimiter) acquire(client string) (func(), string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open >= l.settings.MaxConnections {
+		return nil, "max_connections"
+	}
+	if l.clients[client] >= l.settings.MaxPerClient {
+		return nil, "max_per_client"
+	}
+	l.open++
+	l.clients[client]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.open--
+			if l.clients[client]--; l.clients[client] <= 0 {
+				delete(l.clients, client)
+			}
+		})
+	}, ""
+}
+
+func (l *streamLimiter) configure(settings StreamConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settings = settings
+}
+
+// streamLimiter returns the limiter of a stream route, keeping its counts
+// across reloads
+func (g *Gateway) streamLimiter(route string, settings StreamConfig) *streamLimiter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if l, ok := g.streamLimiters[route]; ok {
+		l.configure(settings)
+		return l
+	}
+	l := &streamLimiter{settings: settings, clients: map[string]int{}}
+	g.streamLimiters[route] = l
+	return l
+}
+
+// streamProxyHandler serves a route with stream settings. WebSocket and SSE
+// requests go through the upstream's StreamProxy once the origin and the
+// connection limits allow it; plain requests are proxied as usual. Streams
+// are ended when done is closed, so they do not hold up a shutdown.
+func streamProxyHandler(rt *route, upstream *Upstream, limiter *streamLimiter, done <-chan struct{}, config *GatewayConfig) http.Handler {
+	plain := routeProxyHandler(rt, upstream.Name, upstream.Proxy)
+	stream := routeProxyHandler(rt, upstream.Name, upstream.StreamProxy)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		protocol := streamProtocol(r)
+		if protocol == "" {
+			plain.ServeHTTP(w, r)
+			return
+		}
+
+		// CORS does not apply to WebSockets, so cross-site pages must be
+		// refused here or they could open connections with the user's token
+		if origin := r.Header.Get("Origin"); protocol == StreamWebSocket && origin != "" && !config.CORS.allows(origin) {
+			streamRejections.WithLabelValues(rt.Name, "origin").Inc()
+			writeJSONError(w, http.StatusForbidden, "Origin not allowed")
+			return
+		}
+
+		release, reason := limiter.acquire(rateLimitSubject(r, RateLimitKeyUser, config.RateLimit.TrustForwardedFor))
+		if release == nil {
+			streamRejections.WithLabelValues(rt.Name, reason).Inc()
+			w.Header().Set("Retry-After", "5")
+			if reason == "max_per_client" {
+				writeJSONError(w, http.StatusTooManyRequests, "Too many open connections for this client")
+			} else {
+				writeJSONError(w, http.StatusServiceUnavailable, "Too many open connections")
+			}
+			return
+		}
+
+		stats := &streamStats{idleTimeout: rt.Stream.IdleTimeout}
+		start := time.Now()
+		streamConnections.WithLabelValues(rt.Name, protocol).Inc()
+		// Deferred: a stream ended by the idle timeout or the client aborts
+		// the proxy with a panic
+		defer func() {
+			release()
+			streamConnections.WithLabelValues(rt.Name, protocol).Dec()
+			streamDuration.WithLabelValues(rt.Name, protocol).Observe(time.Since(start).Seconds())
+			streamBytes.WithLabelValues(rt.Name, "upstream").Add(float64(stats.sent.Load()))
+			streamBytes.WithLabelValues(rt.Name, "downstream").Add(float64(stats.received.Load()))
+			slog.Info("stream closed",
+				"request_id", RequestIDFromContext(r.Context()),
+				"route", rt.Name,
+				"protocol", protocol,
+				"duration_ms", time.Since(start).Milliseconds(),
+				"bytes_upstream", stats.sent.Load(),
+				"bytes_downstream", stats.received.Load(),
+			)
+		}()
+
+		// The server read/write timeouts would cut long-lived streams; the
+		// idle timeout of the route bounds them instead
+		controller := http.NewResponseController(w)
+		controller.SetReadDeadline(time.Time{})
+		controller.SetWriteDeadline(time.Time{})
+
+		ctx, cancel := context.WithCancel(context.WithValue(r.Context(), streamStatsKey{}, stats))
+		defer cancel()
+		go func() {
+			select {
+			case <-done:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		stream.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// rejectUpgrade refuses WebSocket upgrades on routes without stream settings;
+// the regular transport has no idle timeout and would pin the connection
+func rejectUpgrade(rt *route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if streamProtocol(r) == StreamWebSocket {
+			streamRejections.WithLabelValues(rt.Name, "not_stream_route").Inc()
+			writeJSONError(w, http.StatusBadRequest, "WebSocket is not enabled for this route")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// streamStats travels in the request context to the dialer, which applies
+// the route's idle timeout and counts the relayed bytes
+type streamStats struct {
+	idleTimeout time.Duration
+	sent        atomic.Int64 // client -> upstream
+	received    atomic.Int64 // upstream -> client
+}
+
+type streamStatsKey struct{}
+
+// streamDialer wraps connections opened for streams so that every read and
+// write pushes the idle deadline forward
+func streamDialer(dialer *net.Dialer) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		if stats, ok := ctx.Value(streamStatsKey{}).(*streamStats); ok {
+			return &idleConn{Conn: conn, stats: stats}, nil
+		}
+		return conn, nil
+	}
+}
+
+type idleConn struct {
+	net.Conn
+	stats *streamStats
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.stats.idleTimeout))
+	n, err := c.Conn.Read(b)
+	c.stats.received.Add(int64(n))
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	c.Conn.SetDeadline(time.Now().Add(c.stats.idleTimeout))
+	n, err := c.Conn.Write(b)
+	c.stats.sent.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// grpcMaxBody matches the default maximum message size of a gRPC server
+const grpcMaxBody = 4 << 20
+
+// grpcClient is a connection to a gRPC backend. Method descriptors are loaded
+// through the backend's reflection service on first use, so the gateway
+// needs no generated code: a new RPC only needs a route in the config file.
+type grpcClient struct {
+	conn *grpc.ClientConn
+
+	mu      sync.Mutex
+	methods map[string]protoreflect.MethodDescriptor
+}
+
+func newGRPCClient(address string) (*grpcClient, error) {
+	// NewClient does not connect yet, the first call does
+	// round_robin spreads calls over every address the name resolves to
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(`{"loadBalancingConfig": [{"round_robin": {}}]}`),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &grpcClient{conn: conn, methods: map[string]protoreflect.MethodDescriptor{}}, nil
+}
+
+// method resolves package.Service/Method to its descriptor
+func (c *grpcClient) method(ctx context.Context, rpc string) (protoreflect.MethodDescriptor, error) {
+	c.mu.Lock()
+	method, ok := c.methods[rpc]
+	c.mu.Unlock()
+	if ok {
+		return method, nil
+	}
+
+	serviceName, methodName, _ := strings.Cut(rpc, "/")
+	files, err := c.reflect(ctx, serviceName)
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(serviceName))
+	if err != nil {
+		return nil, fmt.Errorf("service %s not found", serviceName)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", serviceName)
+	}
+	method = service.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found", rpc)
+	}
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s is streaming, only unary calls are transcoded", rpc)
+	}
+
+	c.mu.Lock()
+	c.methods[rpc] = method
+	c.mu.Unlock()
+	return method, nil
+}
+
+// reflect downloads the file defining symbol together with its dependencies.
+// Files the gateway already links (well-known types) are taken from the
+// global registry.
+func (c *grpcClient) reflect(ctx context.Context, symbol string) (*protoregistry.Files, error) {
+	stream, err := reflectionpb.NewServerReflectionClient(c.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	pending := []*reflectionpb.ServerReflectionRequest{{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	}}
+	for len(pending) > 0 {
+		request := pending[0]
+		pending = pending[1:]
+		if err := stream.Send(request); err != nil {
+			return nil, err
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if failure := response.GetErrorResponse(); failure != nil {
+			return nil, fmt.Errorf("reflection: %s", failure.GetErrorMessage())
+		}
+
+		for _, raw := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			file := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, file); err != nil {
+				return nil, fmt.Errorf("reflection: %w", err)
+			}
+			protos[file.GetName()] = file
+		}
+		// The server usually sends the dependencies along; ask for any it left out
+		for _, file := range protos {
+			for _, dependency := range file.GetDependency() {
+				if _, ok := protos[dependency]; ok {
+					continue
+				}
+				if known, err := protoregistry.GlobalFiles.FindFileByPath(dependency); err == nil {
+					protos[dependency] = protodesc.ToFileDescriptorProto(known)
+					continue
+				}
+				protos[dependency] = nil // requested
+				pending = append(pending, &reflectionpb.ServerReflectionRequest{
+					MessageRequest: &reflectionpb.ServerReflectionRequest_FileByFilename{FileByFilename: dependency},
+				})
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for name, file := range protos {
+		if file == nil {
+			return nil, fmt.Errorf("reflection: dependency %s was not returned", name)
+		}
+		set.File = append(set.File, file)
+	}
+	return protodesc.NewFiles(set)
+}
+
+// grpcRoute transcodes one HTTP method and path template to a unary RPC
+type grpcRoute struct {
+	GRPCRouteConfig
+	client  *grpcClient
+	breaker *CircuitBreaker
+	timeout time.Duration
+	handler http.Handler
+}
+
+// match reports whether the request targets this route and returns the
+// values of the {field} path segments
+func (rt *grpcRoute) match(method, path string) (map[string]string, bool) {
+	if method != rt.Method {
+		return nil, false
+	}
+	return pathVariables(rt.Path, path)
+}
+
+func (rt *grpcRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	setLogUpstream(r, rt.Backend)
+	ctx, cancel := context.WithTimeout(r.Context(), rt.timeout)
+	defer cancel()
+
+	method, err := rt.client.method(ctx, rt.RPC)
+	if err != nil {
+		upstreamErrors.WithLabelValues(rt.Backend, "schema").Inc()
+		slog.Warn("grpc schema unavailable",
+			"request_id", RequestIDFromContext(r.Context()),
+			"upstream", rt.Backend,
+			"rpc", rt.RPC,
+			"error", err.Error(),
+		)
+		writeJSONError(w, http.StatusBadGateway, "gRPC service unavailable")
+		return
+	}
+
+	request := dynamicpb.NewMessage(method.Input())
+	if err := rt.decodeRequest(w, r, request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := rt.breaker.Allow(); err != nil {
+		rt.writeStatus(w, r, status.New(codes.Unavailable, err.Error()))
+		return
+	}
+	response := dynamicpb.NewMessage(method.Output())
+	err = rt.client.conn.Invoke(outgoingContext(ctx, r, "/"+rt.RPC), "/"+rt.RPC, request, response)
+	code := status.Code(err)
+	rt.breaker.Record(code != codes.Unavailable && code != codes.DeadlineExceeded)
+	if err != nil {
+		rt.writeStatus(w, r, status.Convert(err))
+		return
+	}
+
+	body, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(response)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Could not encode response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// decodeRequest fills the request message from the JSON body, then the query
+// parameters and finally the path, so the path always wins
+func (rt *grpcRoute) decodeRequest(w http.ResponseWriter, r *http.Request, message *dynamicpb.Message) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete && r.Body != nil {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, grpcMaxBody))
+		if err != nil {
+			return fmt.Errorf("could not read request body")
+		}
+		if len(body) > 0 {
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, message); err != nil {
+				return fmt.Errorf("invalid request body: %v", err)
+			}
+		}
+	}
+
+	for name, values := range r.URL.Query() {
+		// Parameters that are not fields (cache busters and the like) are ignored
+		if err := setField(message, name, values); err != nil && !errors.Is(err, errUnknownField) {
+			return err
+		}
+	}
+
+	vars, _ := pathVariables(rt.Path, r.URL.Path)
+	for name, value := range vars {
+		if err := setField(message, name, []string{value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeStatus maps a gRPC status to the HTTP status grpc-gateway would use
+func (rt *grpcRoute) writeStatus(w http.ResponseWriter, r *http.Request, st *status.Status) {
+	httpStatus := httpStatusFromCode(st.Code())
+	if httpStatus >= 500 {
+		upstreamErrors.WithLabelValues(rt.Backend, strings.ToLower(st.Code().String())).Inc()
+		slog.Warn("grpc request failed",
+			"request_id", RequestIDFromContext(r.Context()),
+			"upstream", rt.Backend,
+			"rpc", rt.RPC,
+			"grpc_code", st.Code().String(),
+			"error", st.Message(),
+		)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(map[string]string{"error": st.Message(), "code": st.Code().String()})
+}
+
+// outgoingContext forwards the request id, the token and the signed identity
+// as gRPC metadata. The identity is signed for the call as the server sees it
+// on the wire: POST to /package.Service/Method.
+func outgoingContext(ctx context.Context, r *http.Request, fullMethod string) context.Context {
+	md := metadata.MD{}
+	if requestID := RequestIDFromContext(r.Context()); requestID != "" {
+		md.Set(RequestIDHeader, requestID)
+	}
+	if token := r.Header.Get("Authorization"); token != "" {
+		md.Set("Authorization", token)
+	}
+	identity := http.Header{}
+	signForUpstream(r.Context(), identity, http.MethodPost, fullMethod)
+	for header, values := range identity {
+		md.Set(header, values...)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // client closed request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// setField sets a top-level scalar or repeated scalar field, found by its JSON
+// or proto name, from string values
+func setField(message *dynamicpb.Message, name string, values []string) error {
+	fields := message.Descriptor().Fields()
+	field := fields.ByJSONName(name)
+	if field == nil {
+		field = fields.ByName(protoreflect.Name(name))
+	}
+	if field == nil {
+		return fmt.Errorf("%w %s", errUnknownField, name)
+	}
+	if field.Message() != nil || field.IsMap() {
+		return fmt.Errorf("field %s can only be set in the request body", name)
+	}
+
+	if field.IsList() {
+		list := message.Mutable(field).List()
+		for _, value := range values {
+			parsed, err := parseScalar(field, value)
+			if err != nil {
+				return err
+			}
+			list.Append(parsed)
+		}
+		return nil
+	}
+	parsed, err := parseScalar(field, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	message.Set(field, parsed)
+	return nil
+}
+
+func parseScalar(field protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	invalid := fmt.Errorf("invalid value %q for field %s", value, field.JSONName())
+	switch field.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfBool(b), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt32(int32(n)), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfInt64(n), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint32(uint32(n)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfUint64(n), nil
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.EnumKind:
+		if enumValue := field.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		n, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return protoreflect.Value{}, invalid
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	}
+	return protoreflect.Value{}, invalid
+}
+
+// pathVariables matches a path against a template such as
+// /api/grpc/tours/{tourId} and returns the values of its {name} segments
+func pathVariables(template, path string) (map[string]string, bool) {
+	templateSegments := strings.Split(strings.Trim(template, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateSegments) != len(pathSegments) {
+		return nil, false
+	}
+	vars := map[string]string{}
+	for i, segment := range templateSegments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			vars[strings.Trim(segment, "{}")] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return vars, true
+}
@@ -12,6 +12,14 @@ import (
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Ako je gateway već proverio token, koristimo potpisani identitet iz zaglavlja
+		if identity, ok := identityFromGateway(c.Request); ok {
+			c.Set("username", identity.Username)
+			c.Set("role", identity.Role)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
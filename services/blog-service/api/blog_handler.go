@@ -3,6 +3,7 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors" // <-- DODAT IMPORT
 	"io"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"blog-service/domain"
+	"blog-service/logging"
 	"blog-service/service"
 
 	"github.com/gin-gonic/gin"
@@ -305,7 +307,7 @@ func (h *BlogHandler) GetBlogsFromFollowing(c *gin.Context) {
 	}
 
 	// Pozovi follower service da dobijemo listu korisnika koje pratimo
-	followedUserIds, err := h.getFollowedUserIds(userID.(string))
+	followedUserIds, err := h.getFollowedUserIds(c.Request.Context(), userID.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get followed users"})
 		return
@@ -322,7 +324,7 @@ func (h *BlogHandler) GetBlogsFromFollowing(c *gin.Context) {
 }
 
 // Helper funkcija za pozivanje follower service-a
-func (h *BlogHandler) getFollowedUserIds(userID string) ([]string, error) {
+func (h *BlogHandler) getFollowedUserIds(ctx context.Context, userID string) ([]string, error) {
 	// Pozovi follower service preko HTTP klijenta
 	client := &http.Client{}
 	
@@ -338,11 +340,16 @@ func (h *BlogHandler) getFollowedUserIds(userID string) ([]string, error) {
 		return nil, err
 	}
 	
-	req, err := http.NewRequest("GET", "http://follower-service:8086/api/followed-users", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://follower-service:8086/api/followed-users", nil)
 	if err != nil {
 		return nil, err
 	}
 
+	// Prosleđujemo correlation id da bi se zahtev pratio i kroz follower-service
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set(logging.RequestIDHeader, requestID)
+	}
+
 	// Dodaj Authorization header
 	req.Header.Set("Authorization", "Bearer "+tokenString)
 	
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"blog-service/logging"
 	"blog-service/startup"
 )
 
@@ -13,6 +14,7 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	logging.Setup("blog-service")
 	server := startup.NewServer()
 	server.Start()
 }
\ No newline at end of file
@@ -6,6 +6,8 @@ import (
 	"context"
 	"log"
 
+	"blog-service/metrics"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -13,7 +15,7 @@ import (
 func ConnectDB() *mongo.Client {
 	// ISPRAVKA: Umesto imena servisa 'mongo-db', koristimo 'localhost'
 	// jer se program izvršava van Docker mreže.
-	clientOptions := options.Client().ApplyURI("mongodb://mongo-db:27017")
+	clientOptions := options.Client().ApplyURI("mongodb://mongo-db:27017").SetMonitor(metrics.MongoMonitor())
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
 		log.Fatal("Error connecting to MongoDB: ", err)
@@ -1,6 +1,8 @@
 package startup
 
 import (
+	"net/http"
+
 	"blog-service/api"
 	// 1. Dodajemo import za docs (koji će uskoro biti kreiran)
 	_ "blog-service/docs"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	// 2. Dodajemo importe za swagger
 	swaggerFiles "github.com/swaggo/files"
@@ -26,7 +29,9 @@ func NewServer() *Server {
 	blogService := service.NewBlogService(blogRepo)
 	blogHandler := api.NewBlogHandler(blogService)
 
-	router := gin.Default()
+	router := gin.New()
+	// Recovery + JSON access log sa X-Request-ID umesto podrazumevanog gin logger-a
+	router.Use(gin.Recovery(), api.RequestLogger(), api.Metrics())
 	
 	// CORS ... (ostaje isto)
 	config := cors.DefaultConfig()
@@ -38,6 +43,18 @@ func NewServer() *Server {
 	// 3. Dodajemo rutu za Swagger UI
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Health check koji koristi API gateway - proverava i konekciju ka bazi
+	router.GET("/health", func(c *gin.Context) {
+		if err := mongoClient.Ping(c.Request.Context(), nil); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "down", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "up"})
+	})
+
+	// Prometheus metrike (HTTP, baza, gRPC)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 apiRoutes := router.Group("/api/blogs")
     {
         // Primenjujemo middleware na POST rutu
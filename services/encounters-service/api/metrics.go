@@ -0,0 +1,26 @@
+package api
+
+import (
+	"strconv"
+	"time"
+
+	"encounters-service/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics records the request count and latency of every request under its
+// route template (e.g. /api/tours/:id)
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		metrics.HTTPDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
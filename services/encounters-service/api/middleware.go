@@ -21,6 +21,15 @@ type AppClaims struct {
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Ako je gateway već proverio token, koristimo potpisani identitet iz zaglavlja
+		if identity, ok := identityFromGateway(c.Request); ok {
+			c.Set("userId", identity.UserID)
+			c.Set("username", identity.Username)
+			c.Set("role", identity.Role)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
@@ -77,7 +77,7 @@ func (h *TourExecutionHandler) CheckPosition(c *gin.Context) {
 		return
 	}
 
-	updatedExecution, err := h.service.CheckPosition(userId.(string), dto.Latitude, dto.Longitude)
+	updatedExecution, err := h.service.CheckPosition(c.Request.Context(), userId.(string), dto.Latitude, dto.Longitude)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
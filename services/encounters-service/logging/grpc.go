@@ -0,0 +1,20 @@
+package logging
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// requestIDMetadataKey is the gRPC metadata equivalent of RequestIDHeader
+const requestIDMetadataKey = "x-request-id"
+
+// UnaryClientInterceptor forwards the request id from the context to the
+// called service
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, requestIDMetadataKey, requestID)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// RequestIDHeader carries the correlation id assigned by the API gateway
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// Setup makes slog (and the standard log package) write JSON lines tagged
+// with the service name
+func Setup(service string) {
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("service", service))
+}
+
+// RequestIDFromHeader returns the incoming request id, or a new one when the
+// header is missing or malformed
+func RequestIDFromHeader(h http.Header) string {
+	if id := h.Get(RequestIDHeader); validRequestID(id) {
+		return id
+	}
+	return NewRequestID()
+}
+
+// NewRequestID returns a random 128-bit hex id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID stores the request id in the context
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request id stored in the context, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,10 @@
 
 package main
 
-import "encounters-service/startup"
+import (
+	"encounters-service/logging"
+	"encounters-service/startup"
+)
 
 // @title           Encounters Service API
 // @version         1.0
@@ -23,6 +26,7 @@ import "encounters-service/startup"
 // @in header
 // @name Authorization
 func main() {
+	logging.Setup("encounters-service")
 	server := startup.NewServer()
 	server.Start()
 }
\ No newline at end of file
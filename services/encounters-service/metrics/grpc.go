@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_client_handled_total",
+		Help: "gRPC calls made by the client, by method and status code.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	grpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "gRPC client call latency, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_method"})
+)
+
+// UnaryClientInterceptor counts and times every outgoing unary call
+func UnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	grpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	grpcHandled.WithLabelValues(method, status.Code(err).String()).Inc()
+	return err
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "mongodb_command_duration_seconds",
+	Help:    "MongoDB command latency, by command, collection and outcome.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"command", "collection", "status"})
+
+// MongoMonitor times every command sent by the client, so all repositories
+// are covered without instrumenting them one by one
+func MongoMonitor() *event.CommandMonitor {
+	var collections sync.Map // request id -> collection of the started command
+	observe := func(e event.CommandFinishedEvent, status string) {
+		collection, _ := collections.LoadAndDelete(e.RequestID)
+		name, _ := collection.(string)
+		mongoDuration.WithLabelValues(e.CommandName, name, status).Observe(e.Duration.Seconds())
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// For find, insert, update, delete, aggregate... the first element
+			// of the command holds the collection name
+			if element, err := e.Command.IndexErr(0); err == nil {
+				if name, ok := element.Value().StringValueOK(); ok {
+					collections.Store(e.RequestID, name)
+				}
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observe(e.CommandFinishedEvent, "ok")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observe(e.CommandFinishedEvent, "error")
+		},
+	}
+}
@@ -1,7 +1,7 @@
 // Code generated by protoc-gen-go-grpc. DO NOT EDIT.
 // versions:
 // - protoc-gen-go-grpc v1.2.0
-// - protoc             v6.32.0
+// - protoc             (unknown)
 // source: proto/tours/tours.proto
 
 package tours
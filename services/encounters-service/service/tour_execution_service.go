@@ -4,6 +4,8 @@ package service
 import (
 	"context"
 	"encounters-service/domain"
+	"encounters-service/logging"
+	"encounters-service/metrics"
 	"encounters-service/repository"
 	"errors"
 	"fmt"
@@ -12,6 +14,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"log"
 	"math"
+	"sort"
 	"time"
 	
 	"encounters-service/proto/tours" // Uvozimo proto kod
@@ -29,7 +32,7 @@ const KeyPointCompletionThreshold = 100.0 // Povećano na 500 metara radi lakše
 
 type TourExecutionService interface {
 	StartTour(execution *domain.TourExecution) (*domain.TourExecution, error)
-	CheckPosition(userId string, currentLatitude, currentLongitude float64) (*domain.TourExecution, error)
+	CheckPosition(ctx context.Context, userId string, currentLatitude, currentLongitude float64) (*domain.TourExecution, error)
 	CompleteTour(executionId string) (*domain.TourExecution, error)
 	AbandonTour(executionId string) (*domain.TourExecution, error)
 	GetActiveByUser(userId string) (*domain.TourExecution, error) // <-- DODAJTE OVU METODU
@@ -43,7 +46,11 @@ type tourExecutionService struct {
 // Ažuriramo konstruktor da kreira gRPC klijenta
 func NewTourExecutionService(repo repository.TourExecutionRepository) TourExecutionService {
 	// Uspostavljamo gRPC konekciju ka tours-service koji radi na portu 8086
-	conn, err := grpc.Dial("tours-service:8086", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Interceptori prosleđuju X-Request-ID kao gRPC metadata i mere svaki poziv
+	conn, err := grpc.Dial("tours-service:8086",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(logging.UnaryClientInterceptor, metrics.UnaryClientInterceptor),
+	)
 	if err != nil {
 		log.Fatalf("did not connect to tours-service: %v", err)
 	}
@@ -77,7 +84,7 @@ func (s *tourExecutionService) StartTour(execution *domain.TourExecution) (*doma
 // --- PREPRAVLJENA CheckPosition METODA ---
 // encounters-service/service/tour_execution_service.go
 
-func (s *tourExecutionService) CheckPosition(userId string, currentLatitude, currentLongitude float64) (*domain.TourExecution, error) {
+func (s *tourExecutionService) CheckPosition(ctx context.Context, userId string, currentLatitude, currentLongitude float64) (*domain.TourExecution, error) {
 	activeExecution, err := s.repo.GetActiveByUser(userId)
 	if err != nil {
 		return nil, errors.New("no active tour found for this user")
@@ -86,7 +93,7 @@ func (s *tourExecutionService) CheckPosition(userId string, currentLatitude, cur
 	log.Printf("--- Checking position for user %s ---", userId)
 	log.Printf("Tourist current position: Lat=%f, Lon=%f", currentLatitude, currentLongitude)
 
-	tourResponse, err := s.toursClient.GetTourById(context.Background(), &tours.GetTourByIdRequest{TourId: activeExecution.TourId})
+	tourResponse, err := s.toursClient.GetTourById(ctx, &tours.GetTourByIdRequest{TourId: activeExecution.TourId})
 	if err != nil {
 		log.Printf("gRPC call to tours-service failed: %v", err)
 		return nil, fmt.Errorf("could not get tour details via gRPC: %w", err)
@@ -96,14 +103,28 @@ func (s *tourExecutionService) CheckPosition(userId string, currentLatitude, cur
 	
 	// --- NOVA, PAMETNIJA LOGIKA ---
 	
-	// 1. Određujemo koja je sledeća ključna tačka na redu
-	completedCount := len(activeExecution.CompletedKeyPoints)
-	if completedCount >= len(tourResponse.KeyPoints) {
+	// 1. Određujemo koja je sledeća ključna tačka na redu: prva po redosledu
+	// (order) koja još nije kompletirana. Stabilno sortiranje čuva redosled
+	// iz niza ako tours-service ne šalje order.
+	keyPoints := tourResponse.KeyPoints
+	sort.SliceStable(keyPoints, func(i, j int) bool {
+		return keyPoints[i].GetOrder() < keyPoints[j].GetOrder()
+	})
+	completed := make(map[string]bool, len(activeExecution.CompletedKeyPoints))
+	for _, cp := range activeExecution.CompletedKeyPoints {
+		completed[cp.KeyPointId.Hex()] = true
+	}
+	var nextKeyPointProto *tours.KeyPoint
+	for _, kp := range keyPoints {
+		if !completed[kp.Id] {
+			nextKeyPointProto = kp
+			break
+		}
+	}
+	if nextKeyPointProto == nil {
 		log.Println("All key points already completed.")
 		return activeExecution, nil // Sve je već završeno
 	}
-	
-	nextKeyPointProto := tourResponse.KeyPoints[completedCount]
 	nextKeyPointId, _ := primitive.ObjectIDFromHex(nextKeyPointProto.Id)
 
 	// 2. Računamo distancu SAMO do te sledeće tačke
@@ -5,13 +5,15 @@ import (
 	"context"
 	"log"
 
+	"encounters-service/metrics"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func ConnectDB() *mongo.Client {
 	// Podesite vaš connection string za MongoDB
-	clientOptions := options.Client().ApplyURI("mongodb://mongo-db:27017")
+	clientOptions := options.Client().ApplyURI("mongodb://mongo-db:27017").SetMonitor(metrics.MongoMonitor())
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 
 	if err != nil {
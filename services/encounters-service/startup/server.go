@@ -1,6 +1,8 @@
 package startup
 
 import (
+	"net/http"
+
 	"encounters-service/api"
 	"encounters-service/repository"
 	"encounters-service/service"
@@ -8,6 +10,7 @@ import (
 	_ "encounters-service/docs"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -29,7 +32,9 @@ func NewServer() *Server {
 	tourExecutionService := service.NewTourExecutionService(tourExecutionRepo)
 	tourExecutionHandler := api.NewTourExecutionHandler(tourExecutionService)
 	
-	router := gin.Default()
+	router := gin.New()
+	// Recovery + JSON access log sa X-Request-ID umesto podrazumevanog gin logger-a
+	router.Use(gin.Recovery(), api.RequestLogger(), api.Metrics())
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"*"}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
@@ -38,6 +43,18 @@ func NewServer() *Server {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Health check koji koristi API gateway - proverava i konekciju ka bazi
+	router.GET("/health", func(c *gin.Context) {
+		if err := mongoClient.Ping(c.Request.Context(), nil); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "down", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "up"})
+	})
+
+	// Prometheus metrike (HTTP, baza, gRPC)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	apiGroup := router.Group("/api")
 	{
 		// Rute za Simulator Pozicije
@@ -198,6 +198,11 @@ func (h *FollowerHandler) GetFollowedUserIds(w http.ResponseWriter, r *http.Requ
 
 // getUserIDFromToken extracts user ID from JWT token
 func (h *FollowerHandler) getUserIDFromToken(r *http.Request) string {
+	// Trust the identity forwarded by the API gateway when enabled
+	if identity, ok := identityFromGateway(r); ok {
+		return identity.UserID
+	}
+
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
 		return ""
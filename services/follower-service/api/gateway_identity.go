@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GatewayIdentity is the user identity already verified by the API gateway
+type GatewayIdentity struct {
+	UserID   string
+	Username string
+	Role     string
+}
+
+// Maximum age of a gateway signature we still accept
+const gatewaySignatureMaxAge = 5 * time.Minute
+
+var (
+	trustGateway          = os.Getenv("TRUST_GATEWAY") == "true"
+	gatewayIdentitySecret = []byte(os.Getenv("GATEWAY_IDENTITY_SECRET"))
+)
+
+// A service that trusts the gateway without a secret would silently fall back
+// to validating the JWT itself, hiding the misconfiguration
+func init() {
+	if trustGateway && len(gatewayIdentitySecret) == 0 {
+		log.Fatal("TRUST_GATEWAY=true requires GATEWAY_IDENTITY_SECRET")
+	}
+}
+
+// identityFromGateway returns the identity from the X-User-* headers when the
+// service is configured to trust the gateway (TRUST_GATEWAY=true) and the
+// signature is valid. The signature covers the method and path as well, so
+// the headers cannot be replayed against another endpoint. Otherwise the
+// service keeps validating the JWT itself.
+func identityFromGateway(r *http.Request) (*GatewayIdentity, bool) {
+	if !trustGateway || len(gatewayIdentitySecret) == 0 {
+		return nil, false
+	}
+
+	signature := r.Header.Get("X-Gateway-Signature")
+	timestamp := r.Header.Get("X-Gateway-Timestamp")
+	if signature == "" || timestamp == "" {
+		return nil, false
+	}
+
+	issuedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	age := time.Since(time.Unix(issuedAt, 0))
+	if age > gatewaySignatureMaxAge || age < -gatewaySignatureMaxAge {
+		return nil, false
+	}
+
+	identity := &GatewayIdentity{
+		UserID:   r.Header.Get("X-User-Id"),
+		Username: r.Header.Get("X-User-Username"),
+		Role:     r.Header.Get("X-User-Role"),
+	}
+	mac := hmac.New(sha256.New, gatewayIdentitySecret)
+	mac.Write([]byte(r.Method + "|" + r.URL.Path + "|" + identity.UserID + "|" + identity.Username + "|" + identity.Role + "|" + timestamp))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, false
+	}
+	return identity, true
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"follower-service/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// Metrics records the request count and latency of every request under its
+// route template (e.g. /api/users/{userId}/followers)
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		route := "unmatched"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(recorder.status)).Inc()
+		metrics.HTTPDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"follower-service/logging"
+)
+
+// RequestLogger picks up the X-Request-ID set by the API gateway (or creates
+// one), echoes it back and writes one JSON access log line per request
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := logging.RequestIDFromHeader(r.Header)
+		r = r.WithContext(logging.WithRequestID(r.Context(), requestID))
+		w.Header().Set(logging.RequestIDHeader, requestID)
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		user := ""
+		if identity, ok := identityFromGateway(r); ok {
+			user = identity.Username
+		}
+		slog.Info("request",
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"user", user,
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
+
+// statusRecorder captures the status code and body size of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
@@ -0,0 +1,9 @@
+// Package docs holds the OpenAPI (Swagger 2.0) description of the follower
+// service. The service has no swag annotations, so swagger.json is written by
+// hand: update it together with the routes in startup/server.go.
+package docs
+
+import _ "embed"
+
+//go:embed swagger.json
+var SwaggerJSON []byte
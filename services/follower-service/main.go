@@ -1,9 +1,11 @@
 package main
 
 import (
+	"follower-service/logging"
 	"follower-service/startup"
 )
 
 func main() {
+	logging.Setup("follower-service")
 	startup.StartServer()
 }
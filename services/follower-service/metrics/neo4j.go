@@ -0,0 +1,25 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var neo4jDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "neo4j_query_duration_seconds",
+	Help:    "Neo4j repository operation latency, by operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"operation"})
+
+// ObserveQuery starts timing a repository operation; call the returned
+// function when it is done:
+//
+//	defer metrics.ObserveQuery("GetFollowers")()
+func ObserveQuery(operation string) func() {
+	start := time.Now()
+	return func() {
+		neo4jDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	}
+}
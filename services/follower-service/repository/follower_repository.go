@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"follower-service/domain"
+	"follower-service/metrics"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
@@ -22,6 +23,7 @@ func NewFollowerRepository(driver neo4j.DriverWithContext) *FollowerRepository {
 
 // CreateUser creates or updates a user node in Neo4j
 func (r *FollowerRepository) CreateUser(ctx context.Context, user domain.User) error {
+	defer metrics.ObserveQuery("CreateUser")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -55,6 +57,7 @@ func (r *FollowerRepository) CreateUser(ctx context.Context, user domain.User) e
 
 // FollowUser creates a FOLLOWS relationship between two users
 func (r *FollowerRepository) FollowUser(ctx context.Context, followerID, followingID string) error {
+	defer metrics.ObserveQuery("FollowUser")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -81,6 +84,7 @@ func (r *FollowerRepository) FollowUser(ctx context.Context, followerID, followi
 
 // UnfollowUser removes a FOLLOWS relationship between two users
 func (r *FollowerRepository) UnfollowUser(ctx context.Context, followerID, followingID string) error {
+	defer metrics.ObserveQuery("UnfollowUser")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -104,6 +108,7 @@ func (r *FollowerRepository) UnfollowUser(ctx context.Context, followerID, follo
 
 // GetFollowers returns all users who follow the given user
 func (r *FollowerRepository) GetFollowers(ctx context.Context, userID string) ([]domain.User, error) {
+	defer metrics.ObserveQuery("GetFollowers")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -142,6 +147,7 @@ func (r *FollowerRepository) GetFollowers(ctx context.Context, userID string) ([
 
 // GetFollowing returns all users that the given user follows
 func (r *FollowerRepository) GetFollowing(ctx context.Context, userID string) ([]domain.User, error) {
+	defer metrics.ObserveQuery("GetFollowing")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -180,6 +186,7 @@ func (r *FollowerRepository) GetFollowing(ctx context.Context, userID string) ([
 
 // IsFollowing checks if follower follows following
 func (r *FollowerRepository) IsFollowing(ctx context.Context, followerID, followingID string) (bool, error) {
+	defer metrics.ObserveQuery("IsFollowing")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -209,6 +216,7 @@ func (r *FollowerRepository) IsFollowing(ctx context.Context, followerID, follow
 
 // GetRecommendations returns user recommendations based on mutual connections
 func (r *FollowerRepository) GetRecommendations(ctx context.Context, userID string, limit int) ([]domain.UserRecommendation, error) {
+	defer metrics.ObserveQuery("GetRecommendations")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -261,6 +269,7 @@ func (r *FollowerRepository) GetRecommendations(ctx context.Context, userID stri
 
 // GetFollowedUserIds returns IDs of users that the given user follows (for blog filtering)
 func (r *FollowerRepository) GetFollowedUserIds(ctx context.Context, userID string) ([]string, error) {
+	defer metrics.ObserveQuery("GetFollowedUserIds")()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
 
@@ -293,6 +302,7 @@ func (r *FollowerRepository) GetFollowedUserIds(ctx context.Context, userID stri
 
 // GetAllUsers returns all users from the database
 func (r *FollowerRepository) GetAllUsers(ctx context.Context) ([]domain.User, error) {
+	defer metrics.ObserveQuery("GetAllUsers")()
 	query := `
 		MATCH (u:User)
 		RETURN u.id as id, u.username as username, u.email as email, 
@@ -10,10 +10,12 @@ import (
 	"time"
 
 	"follower-service/api"
+	"follower-service/docs"
 	"follower-service/repository"
 	"follower-service/service"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func StartServer() {
@@ -37,12 +39,26 @@ func StartServer() {
 	// Setup routes
 	router := mux.NewRouter()
 
-	// Health check
+	// Health check (also verifies the Neo4j connection, used by the API gateway)
 	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if err := driver.VerifyConnectivity(r.Context()); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("Follower Service is unhealthy: " + err.Error()))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Follower Service is healthy"))
 	}).Methods("GET")
 
+	// Prometheus metrics (HTTP and Neo4j)
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	// OpenAPI description, merged into the unified API docs by the gateway
+	router.HandleFunc("/swagger/doc.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(docs.SwaggerJSON)
+	}).Methods("GET")
+
 	// API routes
 	apiRouter := router.PathPrefix("/api").Subrouter()
 
@@ -65,13 +81,8 @@ func StartServer() {
 	// For blog service integration
 	apiRouter.HandleFunc("/followed-users", followerHandler.GetFollowedUserIds).Methods("GET")
 
-	// Logging middleware
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("%s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
-			next.ServeHTTP(w, r)
-		})
-	})
+	// JSON access log with the X-Request-ID propagated by the API gateway
+	router.Use(api.RequestLogger, api.Metrics)
 
 	// Start server
 	port := os.Getenv("PORT")
@@ -48,4 +48,10 @@ app.UseCors(MyAllowSpecificOrigins);
 app.UseAuthorization();
 app.MapControllers();
 
+// Health check koji koristi API gateway - proverava i konekciju ka bazi
+app.MapGet("/health", async (PaymentsDbContext db) =>
+    await db.Database.CanConnectAsync()
+        ? Results.Ok(new { status = "up" })
+        : Results.Json(new { status = "down" }, statusCode: StatusCodes.Status503ServiceUnavailable));
+
 app.Run();
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// GatewayIdentity je identitet korisnika koji je API gateway već proverio
+type GatewayIdentity struct {
+	UserID   string
+	Username string
+	Role     string
+}
+
+// Maksimalna starost potpisa koju prihvatamo od gateway-a
+const gatewaySignatureMaxAge = 5 * time.Minute
+
+var (
+	trustGateway          = os.Getenv("TRUST_GATEWAY") == "true"
+	gatewayIdentitySecret = []byte(os.Getenv("GATEWAY_IDENTITY_SECRET"))
+)
+
+// Servis koji veruje gateway-u bez tajne bi neprimetno prešao na proveru JWT-a,
+// pa se takva konfiguracija odbija pri pokretanju
+func init() {
+	if trustGateway && len(gatewayIdentitySecret) == 0 {
+		log.Fatal("TRUST_GATEWAY=true zahteva GATEWAY_IDENTITY_SECRET")
+	}
+}
+
+// identityFromGateway vraća identitet iz X-User-* zaglavlja ako je servis
+// podešen da veruje gateway-u (TRUST_GATEWAY=true) i ako je potpis ispravan.
+// Potpis obuhvata i metodu i putanju, pa se zaglavlja ne mogu ponovo
+// iskoristiti za drugi endpoint. U suprotnom servis nastavlja da sam
+// proverava JWT.
+func identityFromGateway(r *http.Request) (*GatewayIdentity, bool) {
+	if !trustGateway || len(gatewayIdentitySecret) == 0 {
+		return nil, false
+	}
+
+	signature := r.Header.Get("X-Gateway-Signature")
+	timestamp := r.Header.Get("X-Gateway-Timestamp")
+	if signature == "" || timestamp == "" {
+		return nil, false
+	}
+
+	issuedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	age := time.Since(time.Unix(issuedAt, 0))
+	if age > gatewaySignatureMaxAge || age < -gatewaySignatureMaxAge {
+		return nil, false
+	}
+
+	identity := &GatewayIdentity{
+		UserID:   r.Header.Get("X-User-Id"),
+		Username: r.Header.Get("X-User-Username"),
+		Role:     r.Header.Get("X-User-Role"),
+	}
+	mac := hmac.New(sha256.New, gatewayIdentitySecret)
+	mac.Write([]byte(r.Method + "|" + r.URL.Path + "|" + identity.UserID + "|" + identity.Username + "|" + identity.Role + "|" + timestamp))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, false
+	}
+	return identity, true
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"log/slog"
+	"time"
+
+	"stakeholders-service/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestLogger picks up the X-Request-ID set by the API gateway (or creates
+// one), echoes it back and writes one JSON access log line per request
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := logging.RequestIDFromHeader(c.Request.Header)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+		c.Header(logging.RequestIDHeader, requestID)
+
+		c.Next()
+
+		bytes := c.Writer.Size()
+		if bytes < 0 {
+			bytes = 0
+		}
+		attrs := []any{
+			"request_id", requestID,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"bytes", bytes,
+			"duration_ms", time.Since(start).Milliseconds(),
+			"user", c.GetString("username"),
+			"client_ip", c.ClientIP(),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "error", c.Errors.String())
+		}
+		slog.Info("request", attrs...)
+	}
+}
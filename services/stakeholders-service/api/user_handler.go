@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"stakeholders-service/domain"
 	"stakeholders-service/service"
 
@@ -120,6 +121,55 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// @Summary Javni profil korisnika
+// @Description Vraća javne podatke o profilu (ime, prezime, slika, bio, moto) za zadatog korisnika.
+// @Produce json
+// @Param username path string true "Korisničko ime"
+// @Success 200 {object} domain.PublicProfile "Javni profil"
+// @Failure 404 {object} map[string]string "Korisnik nije pronađen"
+// @Router /stakeholders/users/{username} [get]
+func (h *UserHandler) GetPublicProfile(c *gin.Context) {
+	user, err := h.service.GetProfile(c.Param("username"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Korisnik nije pronađen"})
+		return
+	}
+	c.JSON(http.StatusOK, user.PublicProfile())
+}
+
+// maxPublicProfiles ograničava broj korisnika u jednom zahtevu za javne profile
+const maxPublicProfiles = 100
+
+// @Summary Javni profili više korisnika
+// @Description Vraća javne profile za listu korisničkih imena odvojenih zarezom. Nepostojeći korisnici se izostavljaju.
+// @Produce json
+// @Param usernames query string true "Korisnička imena, npr. pera,mika (najviše 100)"
+// @Success 200 {array} domain.PublicProfile "Javni profili"
+// @Failure 400 {object} map[string]string "Neispravan zahtev"
+// @Router /stakeholders/users [get]
+func (h *UserHandler) GetPublicProfiles(c *gin.Context) {
+	usernames := []string{}
+	seen := map[string]bool{}
+	for _, username := range strings.Split(c.Query("usernames"), ",") {
+		username = strings.TrimSpace(username)
+		if username != "" && !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 || len(usernames) > maxPublicProfiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parametar usernames mora sadržati od 1 do 100 korisničkih imena"})
+		return
+	}
+
+	profiles, err := h.service.GetPublicProfiles(usernames)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Interna greška servera"})
+		return
+	}
+	c.JSON(http.StatusOK, profiles)
+}
+
 // NOVA HANDLER METODA za ažuriranje profila
 // @Summary Ažuriranje profila ulogovanog korisnika
 // @Security ApiKeyAuth
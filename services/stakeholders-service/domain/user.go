@@ -17,3 +17,25 @@ type User struct {
     Biography      string `json:"biography" example:"Ja sam Pera..."`
     Motto          string `json:"motto" example:"Carpe diem"`
 }
+
+// PublicProfile su podaci o korisniku koje sme da vidi bilo ko (npr. autori recenzija)
+type PublicProfile struct {
+    Username       string `json:"username" example:"turista123"`
+    FirstName      string `json:"firstName" example:"Pera"`
+    LastName       string `json:"lastName" example:"Peric"`
+    ProfilePicture string `json:"profilePicture" example:"path/to/image.jpg"`
+    Biography      string `json:"biography" example:"Ja sam Pera..."`
+    Motto          string `json:"motto" example:"Carpe diem"`
+}
+
+// PublicProfile vraća javni deo profila, bez email-a, lozinke i uloge
+func (u *User) PublicProfile() PublicProfile {
+    return PublicProfile{
+        Username:       u.Username,
+        FirstName:      u.FirstName,
+        LastName:       u.LastName,
+        ProfilePicture: u.ProfilePicture,
+        Biography:      u.Biography,
+        Motto:          u.Motto,
+    }
+}
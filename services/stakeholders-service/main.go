@@ -1,6 +1,7 @@
 package main
 
 import (
+	"stakeholders-service/logging"
 	"stakeholders-service/startup"
 )
 
@@ -13,6 +14,8 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	logging.Setup("stakeholders-service")
+
 	// 1. Inicijalizujemo konekciju sa bazom
 	driver := startup.NewDriver()
 
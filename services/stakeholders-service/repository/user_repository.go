@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"stakeholders-service/domain"
+	"stakeholders-service/metrics"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 )
@@ -12,6 +13,7 @@ type UserRepository interface {
 	Create(user *domain.User) error
 	GetAll() ([]*domain.User, error)
 	GetByUsername(username string) (*domain.User, error)
+	GetByUsernames(usernames []string) ([]*domain.User, error)
 	Update(user *domain.User) error
 	UpdateBlockedStatus(username string, isBlocked bool) error
 }
@@ -25,6 +27,7 @@ func NewUserRepository(driver neo4j.DriverWithContext) UserRepository {
 }
 
 func (r *userRepository) Create(user *domain.User) error {
+	defer metrics.ObserveQuery("Create")()
 	ctx := context.Background()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
@@ -56,6 +59,7 @@ func (r *userRepository) Create(user *domain.User) error {
 
 // ### ISPRAVLJENA GETALL METODA ###
 func (r *userRepository) GetAll() ([]*domain.User, error) {
+	defer metrics.ObserveQuery("GetAll")()
 	ctx := context.Background()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -104,6 +108,7 @@ func (r *userRepository) GetAll() ([]*domain.User, error) {
 
 
 func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
+	defer metrics.ObserveQuery("GetByUsername")()
 	ctx := context.Background()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
 	defer session.Close(ctx)
@@ -144,7 +149,49 @@ func (r *userRepository) GetByUsername(username string) (*domain.User, error) {
 	return user.(*domain.User), nil
 }
 
+// GetByUsernames vraća sve postojeće korisnike iz liste jednim upitom (nepostojeći se preskaču)
+func (r *userRepository) GetByUsernames(usernames []string) ([]*domain.User, error) {
+	defer metrics.ObserveQuery("GetByUsernames")()
+	ctx := context.Background()
+	session := r.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+	defer session.Close(ctx)
+
+	users, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+		query := "MATCH (u:User) WHERE u.username IN $usernames RETURN u"
+		result, err := tx.Run(ctx, query, map[string]any{"usernames": usernames})
+		if err != nil {
+			return nil, err
+		}
+
+		users := []*domain.User{}
+		for result.Next(ctx) {
+			userNode, ok := result.Record().Get("u")
+			if !ok {
+				continue
+			}
+			props := userNode.(neo4j.Node).Props
+			users = append(users, &domain.User{
+				Username:       props["username"].(string),
+				Email:          props["email"].(string),
+				Role:           props["role"].(string),
+				IsBlocked:      props["isBlocked"].(bool),
+				FirstName:      props["firstName"].(string),
+				LastName:       props["lastName"].(string),
+				ProfilePicture: props["profilePicture"].(string),
+				Biography:      props["biography"].(string),
+				Motto:          props["motto"].(string),
+			})
+		}
+		return users, result.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users.([]*domain.User), nil
+}
+
 func (r *userRepository) Update(user *domain.User) error {
+	defer metrics.ObserveQuery("Update")()
 	ctx := context.Background()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
@@ -169,6 +216,7 @@ func (r *userRepository) Update(user *domain.User) error {
 }
 
 func (r *userRepository) UpdateBlockedStatus(username string, isBlocked bool) error {
+	defer metrics.ObserveQuery("UpdateBlockedStatus")()
 	ctx := context.Background()
 	session := r.driver.NewSession(ctx, neo4j.SessionConfig{})
 	defer session.Close(ctx)
@@ -19,6 +19,7 @@ type UserService interface {
 	GetAll() ([]*domain.User, error)
 	Login(username, password string) (string, error)
 	GetProfile(username string) (*domain.User, error)
+	GetPublicProfiles(usernames []string) ([]domain.PublicProfile, error)
 	UpdateProfile(user *domain.User) (*domain.User, error)
 	SetBlockedStatus(username string, isBlocked bool) error
 }
@@ -93,6 +94,19 @@ func (s *userService) GetProfile(username string) (*domain.User, error) {
 	return user, nil
 }
 
+// GetPublicProfiles vraća javne profile više korisnika odjednom (npr. za autore recenzija)
+func (s *userService) GetPublicProfiles(usernames []string) ([]domain.PublicProfile, error) {
+	users, err := s.repo.GetByUsernames(usernames)
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]domain.PublicProfile, 0, len(users))
+	for _, user := range users {
+		profiles = append(profiles, user.PublicProfile())
+	}
+	return profiles, nil
+}
+
 func (s *userService) UpdateProfile(user *domain.User) (*domain.User, error) {
 	err := s.repo.Update(user)
 	if err != nil {
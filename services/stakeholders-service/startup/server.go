@@ -2,6 +2,7 @@ package startup
 
 import (
 	"fmt"
+	"net/http"
 	"stakeholders-service/api"
 	_ "stakeholders-service/docs"
 	"stakeholders-service/repository"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -19,7 +21,9 @@ type Server struct {
 }
 
 func NewServer(driver neo4j.DriverWithContext) *Server {
-	router := gin.Default()
+	router := gin.New()
+	// Recovery + JSON access log sa X-Request-ID umesto podrazumevanog gin logger-a
+	router.Use(gin.Recovery(), api.RequestLogger(), api.Metrics())
 	router.RedirectTrailingSlash = false
 
 	config := cors.DefaultConfig()
@@ -30,6 +34,18 @@ func NewServer(driver neo4j.DriverWithContext) *Server {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Health check koji koristi API gateway - proverava i konekciju ka bazi
+	router.GET("/health", func(c *gin.Context) {
+		if err := driver.VerifyConnectivity(c.Request.Context()); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "down", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "up"})
+	})
+
+	// Prometheus metrike (HTTP, baza, gRPC)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	userRepo := repository.NewUserRepository(driver)
 	userService := service.NewUserService(userRepo)
 	userHandler := api.NewUserHandler(userService)
@@ -40,6 +56,8 @@ func NewServer(driver neo4j.DriverWithContext) *Server {
 		// Javne rute
 		apiRoutes.POST("/stakeholders/register", userHandler.Register)
 		apiRoutes.POST("/stakeholders/login", userHandler.Login)
+		apiRoutes.GET("/stakeholders/users", userHandler.GetPublicProfiles)
+		apiRoutes.GET("/stakeholders/users/:username", userHandler.GetPublicProfile)
 
 		// Rute za ulogovane korisnike
 		apiRoutes.GET("/stakeholders/profile", api.AuthMiddleware(), userHandler.GetProfile)
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +18,14 @@ type Claims struct {
 
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Ako je gateway već proverio token, koristimo potpisani identitet iz zaglavlja
+		if identity, ok := identityFromGateway(c.Request); ok {
+			c.Set("username", identity.Username)
+			c.Set("role", identity.Role)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
@@ -48,3 +57,12 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SharedCache dozvoljava deljenim keševima (API gateway) da čuvaju odgovor
+// maxAge sekundi, dok browser svaki put revalidira preko ETag-a
+func SharedCache(maxAge int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=0, s-maxage="+strconv.Itoa(maxAge))
+		c.Next()
+	}
+}
@@ -1,13 +1,17 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"tours-service/domain"
 	"tours-service/service"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type TourHandler struct {
@@ -49,10 +53,27 @@ func (h *TourHandler) Create(c *gin.Context) {
 }
 
 // @Summary Prikaz tura kreiranih od strane ulogovanog autora
-// @Description Vraća listu svih tura koje je kreirao autor čiji se token koristi.
+// @Description Vraća stranicu tura koje je kreirao autor čiji se token koristi.
 // @Produce  json
+// @Param   tags query string false "Tagovi odvojeni zarezom; tura mora imati sve"
+// @Param   minDifficulty query int false "Najmanja težina"
+// @Param   maxDifficulty query int false "Najveća težina"
+// @Param   minPrice query number false "Najmanja cena"
+// @Param   maxPrice query number false "Najveća cena"
+// @Param   minDistance query number false "Najmanja dužina (km)"
+// @Param   maxDistance query number false "Najveća dužina (km)"
+// @Param   transport query string false "Način prevoza" Enums(walking, bicycle, car)
+// @Param   status query string false "Status ture" Enums(draft, published, archived)
+// @Param   q query string false "Pretraga naziva, opisa i tagova"
+// @Param   bbox query string false "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju"
+// @Param   sort query string false "Polje za sortiranje; bez njega najnovije su prve" Enums(publishedAt, price, rating, distance)
+// @Param   order query string false "Smer sortiranja (publishedAt i rating podrazumevano desc)" Enums(asc, desc)
+// @Param   page query int false "Stranica, od 1"
+// @Param   pageSize query int false "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture"
 // @Security ApiKeyAuth
 // @Success 200 {array} domain.Tour "Lista tura"
+// @Header  200 {integer} X-Total-Count "Ukupan broj tura koje odgovaraju filterima"
+// @Failure 400 {object} map[string]string "Greška: Neispravan parametar upita"
 // @Failure 401 {object} map[string]string "Greška: Korisnik nije autorizovan"
 // @Router /tours/my-tours [get]
 func (h *TourHandler) GetByAuthor(c *gin.Context) {
@@ -61,26 +82,54 @@ func (h *TourHandler) GetByAuthor(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Korisnik nije autorizovan"})
 		return
 	}
-	tours, err := h.service.GetByAuthorId(authorUsername.(string))
+	query, err := parseTourQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tours, err := h.service.GetByAuthorId(authorUsername.(string), query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom preuzimanja tura"})
 		return
 	}
-	c.JSON(http.StatusOK, tours)
+	writeTourPage(c, tours)
 }
 
 // @Summary Prikaz svih tura
-// @Description Vraća listu svih tura dostupnih u sistemu.
+// @Description Vraća stranicu tura dostupnih u sistemu, sa filterima i sortiranjem.
 // @Produce  json
+// @Param   tags query string false "Tagovi odvojeni zarezom; tura mora imati sve"
+// @Param   minDifficulty query int false "Najmanja težina"
+// @Param   maxDifficulty query int false "Najveća težina"
+// @Param   minPrice query number false "Najmanja cena"
+// @Param   maxPrice query number false "Najveća cena"
+// @Param   minDistance query number false "Najmanja dužina (km)"
+// @Param   maxDistance query number false "Najveća dužina (km)"
+// @Param   transport query string false "Način prevoza" Enums(walking, bicycle, car)
+// @Param   author query string false "Autor ture"
+// @Param   status query string false "Status ture" Enums(draft, published, archived)
+// @Param   q query string false "Pretraga naziva, opisa i tagova"
+// @Param   bbox query string false "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju"
+// @Param   sort query string false "Polje za sortiranje; bez njega najnovije su prve" Enums(publishedAt, price, rating, distance)
+// @Param   order query string false "Smer sortiranja (publishedAt i rating podrazumevano desc)" Enums(asc, desc)
+// @Param   page query int false "Stranica, od 1"
+// @Param   pageSize query int false "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture"
 // @Success 200 {array} domain.Tour "Lista svih tura"
+// @Header  200 {integer} X-Total-Count "Ukupan broj tura koje odgovaraju filterima"
+// @Failure 400 {object} map[string]string "Greška: Neispravan parametar upita"
 // @Router /tours [get]
 func (h *TourHandler) GetAll(c *gin.Context) {
-	tours, err := h.service.GetAll()
+	query, err := parseTourQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tours, err := h.service.GetAll(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom preuzimanja tura"})
 		return
 	}
-	c.JSON(http.StatusOK, tours)
+	writeTourPage(c, tours)
 }
 
 // @Summary Dodavanje recenzije na turu
@@ -114,13 +163,15 @@ func (h *TourHandler) AddReview(c *gin.Context) {
 }
 
 // @Summary Dodavanje ključne tačke na turu
-// @Description Dodaje novu ključnu tačku na turu sa datim ID-jem. Samo autor ture može dodati tačku.
+// @Description Dodaje novu ključnu tačku na turu sa datim ID-jem. Samo autor ture može dodati tačku. Bez parametra position tačka se dodaje na kraj.
 // @Accept  json
 // @Produce  json
 // @Param   id   path   string  true  "ID Ture"
+// @Param   position query int false "Redni broj (od 1) na koji se tačka umeće; ostale se pomeraju"
 // @Param   keyPoint body domain.TourKeyPoint true "Podaci o ključnoj tački"
 // @Security ApiKeyAuth
 // @Success 200 {object} domain.TourKeyPoint "Uspešno dodata ključna tačka"
+// @Failure 400 {object} map[string]string "Greška: Neispravan format zahteva, koordinate ili pozicija"
 // @Router /tours/{id}/keypoints [post]
 func (h *TourHandler) AddKeyPoint(c *gin.Context) {
 	tourId := c.Param("id")
@@ -129,6 +180,15 @@ func (h *TourHandler) AddKeyPoint(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Neispravan format zahteva"})
 		return
 	}
+	position := 0
+	if value := c.Query("position"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Pozicija mora biti ceo broj veći od 0"})
+			return
+		}
+		position = parsed
+	}
 	
 	// --- POCETAK PROVERE AUTORIZACIJE ---
 	authorUsername, exists := c.Get("username")
@@ -149,7 +209,11 @@ func (h *TourHandler) AddKeyPoint(c *gin.Context) {
 	}
 	// --- KRAJ PROVERE AUTORIZACIJE ---
 
-	if err := h.service.AddKeyPoint(tourId, &keyPoint); err != nil {
+	if err := h.service.AddKeyPoint(tourId, &keyPoint, position); err != nil {
+		if errors.Is(err, service.ErrInvalidCoordinates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("!!! SERVER ERROR - AddKeyPoint: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom dodavanja ključne tačke"})
 		return
@@ -166,6 +230,8 @@ func (h *TourHandler) AddKeyPoint(c *gin.Context) {
 // @Param   keyPoint body domain.TourKeyPoint true "Novi podaci o ključnoj tački"
 // @Security ApiKeyAuth
 // @Success 200 {object} domain.TourKeyPoint "Uspešno ažurirana ključna tačka"
+// @Failure 400 {object} map[string]string "Greška: Neispravan format zahteva ili koordinate"
+// @Failure 404 {object} map[string]string "Greška: Tura ili ključna tačka nije pronađena"
 // @Router /tours/{id}/keypoints/{keypointId} [put]
 func (h *TourHandler) UpdateKeyPoint(c *gin.Context) {
 	tourId := c.Param("id")
@@ -205,6 +271,14 @@ func (h *TourHandler) UpdateKeyPoint(c *gin.Context) {
 	keyPoint.TourId = tourId
 
 	if err := h.service.UpdateKeyPoint(tourId, &keyPoint); err != nil {
+		if errors.Is(err, service.ErrInvalidCoordinates) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ključna tačka nije pronađena"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom ažuriranja ključne tačke"})
 		return
 	}
@@ -249,6 +323,66 @@ func (h *TourHandler) DeleteKeyPoint(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Ključna tačka je uspešno obrisana."})
 }
 
+type reorderKeyPointsRequest struct {
+	KeyPointIds []string `json:"keyPointIds" binding:"required"`
+}
+
+// @Summary Promena redosleda ključnih tačaka
+// @Description Postavlja novi redosled ključnih tačaka ture u jednom upisu. Lista mora sadržati ID svake ključne tačke ture tačno jednom. Distanca i putanja se računaju ponovo.
+// @Accept  json
+// @Produce  json
+// @Param   id   path   string  true  "ID Ture"
+// @Param   order body reorderKeyPointsRequest true "ID-jevi ključnih tačaka u novom redosledu"
+// @Security ApiKeyAuth
+// @Success 200 {object} domain.Tour "Tura sa novim redosledom"
+// @Failure 400 {object} map[string]string "Greška: Lista nije redosled svih ključnih tačaka ture"
+// @Failure 401 {object} map[string]string "Greška: Korisnik nije autorizovan"
+// @Failure 403 {object} map[string]string "Greška: Nemate dozvolu"
+// @Failure 409 {object} map[string]string "Greška: Ključne tačke su u međuvremenu izmenjene"
+// @Router /tours/{id}/keypoints/order [put]
+func (h *TourHandler) ReorderKeyPoints(c *gin.Context) {
+	tourId := c.Param("id")
+	var request reorderKeyPointsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Neispravan format zahteva"})
+		return
+	}
+
+	// --- POCETAK PROVERE AUTORIZACIJE ---
+	authorUsername, exists := c.Get("username")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Korisnik nije autorizovan"})
+		return
+	}
+
+	tour, err := h.service.GetById(tourId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tura nije pronađena"})
+		return
+	}
+
+	if tour.AuthorId != authorUsername.(string) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Nemate dozvolu da menjate ovu turu"})
+		return
+	}
+	// --- KRAJ PROVERE AUTORIZACIJE ---
+
+	updatedTour, err := h.service.ReorderKeyPoints(tourId, request.KeyPointIds)
+	switch {
+	case errors.Is(err, service.ErrInvalidKeyPointOrder):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrKeyPointsChanged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("!!! SERVER ERROR - ReorderKeyPoints: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom promene redosleda ključnih tačaka"})
+		return
+	}
+	c.JSON(http.StatusOK, updatedTour)
+}
+
 // @Summary Prikaz jedne ture po ID-ju
 // @Description Vraća detalje specifične ture na osnovu njenog ID-ja.
 // @Produce  json
@@ -379,17 +513,39 @@ func (h *TourHandler) Archive(c *gin.Context) {
 }
 
 // @Summary Prikaz svih objavljenih tura (za turiste)
-// @Description Vraća listu tura koje imaju status 'published'. Za svaku turu prikazuje samo prvu ključnu tačku.
+// @Description Vraća stranicu tura koje imaju status 'published'. Za svaku turu prikazuje samo prvu ključnu tačku.
 // @Produce  json
+// @Param   tags query string false "Tagovi odvojeni zarezom; tura mora imati sve"
+// @Param   minDifficulty query int false "Najmanja težina"
+// @Param   maxDifficulty query int false "Najveća težina"
+// @Param   minPrice query number false "Najmanja cena"
+// @Param   maxPrice query number false "Najveća cena"
+// @Param   minDistance query number false "Najmanja dužina (km)"
+// @Param   maxDistance query number false "Najveća dužina (km)"
+// @Param   transport query string false "Način prevoza" Enums(walking, bicycle, car)
+// @Param   author query string false "Autor ture"
+// @Param   q query string false "Pretraga naziva, opisa i tagova"
+// @Param   bbox query string false "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju"
+// @Param   sort query string false "Polje za sortiranje; bez njega najnovije su prve" Enums(publishedAt, price, rating, distance)
+// @Param   order query string false "Smer sortiranja (publishedAt i rating podrazumevano desc)" Enums(asc, desc)
+// @Param   page query int false "Stranica, od 1"
+// @Param   pageSize query int false "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture"
 // @Success 200 {array} domain.Tour "Lista objavljenih tura"
+// @Header  200 {integer} X-Total-Count "Ukupan broj tura koje odgovaraju filterima"
+// @Failure 400 {object} map[string]string "Greška: Neispravan parametar upita"
 // @Router /tours/published [get]
 func (h *TourHandler) GetPublished(c *gin.Context) {
-	tours, err := h.service.GetPublished()
+	query, err := parseTourQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tours, err := h.service.GetPublished(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom preuzimanja objavljenih tura"})
 		return
 	}
-	c.JSON(http.StatusOK, tours)
+	writeTourPage(c, tours)
 }
 
 // @Summary Ponovno aktiviranje ture
@@ -427,15 +583,111 @@ func (h *TourHandler) Reactivate(c *gin.Context) {
 }
 
 // @Summary Prikaz svih arhiviranih tura
-// @Description Vraća listu tura koje imaju status 'archived'. Za svaku turu prikazuje samo prvu ključnu tačku.
+// @Description Vraća stranicu tura koje imaju status 'archived'. Za svaku turu prikazuje samo prvu ključnu tačku.
 // @Produce 	json
+// @Param   tags query string false "Tagovi odvojeni zarezom; tura mora imati sve"
+// @Param   minDifficulty query int false "Najmanja težina"
+// @Param   maxDifficulty query int false "Najveća težina"
+// @Param   minPrice query number false "Najmanja cena"
+// @Param   maxPrice query number false "Najveća cena"
+// @Param   minDistance query number false "Najmanja dužina (km)"
+// @Param   maxDistance query number false "Najveća dužina (km)"
+// @Param   transport query string false "Način prevoza" Enums(walking, bicycle, car)
+// @Param   author query string false "Autor ture"
+// @Param   q query string false "Pretraga naziva, opisa i tagova"
+// @Param   bbox query string false "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju"
+// @Param   sort query string false "Polje za sortiranje; bez njega najnovije su prve" Enums(publishedAt, price, rating, distance)
+// @Param   order query string false "Smer sortiranja (publishedAt i rating podrazumevano desc)" Enums(asc, desc)
+// @Param   page query int false "Stranica, od 1"
+// @Param   pageSize query int false "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture"
 // @Success 200 {array} domain.Tour "Lista arhiviranih tura"
+// @Header  200 {integer} X-Total-Count "Ukupan broj tura koje odgovaraju filterima"
+// @Failure 400 {object} map[string]string "Greška: Neispravan parametar upita"
 // @Router /tours/archived [get]
 func (h *TourHandler) GetArchived(c *gin.Context) {
-	tours, err := h.service.GetArchived()
+	query, err := parseTourQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tours, err := h.service.GetArchived(query)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom preuzimanja arhiviranih tura"})
 		return
 	}
-	c.JSON(http.StatusOK, tours)
-}
\ No newline at end of file
+	writeTourPage(c, tours)
+}
+
+const (
+	defaultNearbyRadiusKm = 10.0
+	maxNearbyRadiusKm     = 500.0
+)
+
+// @Summary Objavljene ture u blizini
+// @Description Vraća objavljene ture čija je početna ili bilo koja ključna tačka u krugu od radiusKm oko lokacije, najbliže prve. Za svaku turu prikazuje samo prvu ključnu tačku; nearestKm je udaljenost najbliže ključne tačke.
+// @Produce  json
+// @Param   lat query number true "Geografska širina"
+// @Param   lon query number true "Geografska dužina"
+// @Param   radiusKm query number false "Poluprečnik u kilometrima (podrazumevano 10, najviše 500)"
+// @Param   tags query string false "Tagovi odvojeni zarezom; tura mora imati sve"
+// @Param   minDifficulty query int false "Najmanja težina"
+// @Param   maxDifficulty query int false "Najveća težina"
+// @Param   minPrice query number false "Najmanja cena"
+// @Param   maxPrice query number false "Najveća cena"
+// @Param   minDistance query number false "Najmanja dužina (km)"
+// @Param   maxDistance query number false "Najveća dužina (km)"
+// @Param   transport query string false "Način prevoza" Enums(walking, bicycle, car)
+// @Param   author query string false "Autor ture"
+// @Param   page query int false "Stranica, od 1"
+// @Param   pageSize query int false "Broj tura po stranici (podrazumevano 20, najviše 100)"
+// @Success 200 {array} domain.NearbyTour "Ture u blizini"
+// @Header  200 {integer} X-Total-Count "Ukupan broj tura u krugu koje odgovaraju filterima"
+// @Failure 400 {object} map[string]string "Greška: Neispravna lokacija, poluprečnik ili parametar upita"
+// @Router /tours/nearby [get]
+func (h *TourHandler) GetNearby(c *gin.Context) {
+	latitude, err := floatParam(c, "lat")
+	if err != nil || latitude == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parametar lat je obavezan i mora biti broj"})
+		return
+	}
+	longitude, err := floatParam(c, "lon")
+	if err != nil || longitude == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parametar lon je obavezan i mora biti broj"})
+		return
+	}
+	radiusKm := defaultNearbyRadiusKm
+	if value, err := floatParam(c, "radiusKm"); err != nil || value != nil && (*value <= 0 || *value > maxNearbyRadiusKm) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Parametar radiusKm mora biti broj veći od 0 i najviše %g", maxNearbyRadiusKm)})
+		return
+	} else if value != nil {
+		radiusKm = *value
+	}
+
+	query, err := parseTourQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Rezultat je uvek sortiran po udaljenosti, a $geoNear ne podržava pretragu teksta
+	if query.SortBy != "" || query.Text != "" || query.Within != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parametri sort, q i bbox nisu podržani uz pretragu po blizini"})
+		return
+	}
+	// Pretraga po blizini je uvek podeljena na stranice
+	if query.PageSize == 0 {
+		query.PageSize = domain.DefaultTourPageSize
+	}
+
+	tours, err := h.service.GetNearby(*latitude, *longitude, radiusKm, query)
+	if errors.Is(err, service.ErrInvalidCoordinates) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("!!! SERVER ERROR - GetNearby: %v\n", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Greška prilikom pretrage tura u blizini"})
+		return
+	}
+	c.Header(TotalCountHeader, strconv.FormatInt(tours.Total, 10))
+	c.JSON(http.StatusOK, tours.Tours)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tours-service/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TotalCountHeader nosi ukupan broj tura koje odgovaraju filterima; telo
+// odgovora ostaje niz tura jedne stranice
+const TotalCountHeader = "X-Total-Count"
+
+// parseTourQuery čita filtere, sortiranje i stranicu liste tura iz query
+// parametara:
+//
+//	tags=planina,jezero          ture sa svim navedenim tagovima
+//	minDifficulty, maxDifficulty opseg težine
+//	minPrice, maxPrice           opseg cene
+//	minDistance, maxDistance     opseg dužine u kilometrima
+//	transport=walking            ture sa vremenom za dati prevoz
+//	author, status               autor i status ture
+//	q=tekst                      pretraga naziva, opisa i tagova
+//	bbox=minLon,minLat,maxLon,maxLat  ture sa ključnom tačkom u prikazu mape
+//	sort=publishedAt|price|rating|distance, order=asc|desc
+//	page (od 1), pageSize (podrazumevano 20 uz page, najviše 100)
+//
+// Bez page i pageSize lista nije podeljena na stranice (PageSize je 0), kao
+// pre uvođenja stranica, jer frontend očekuje sve ture.
+func parseTourQuery(c *gin.Context) (domain.TourQuery, error) {
+	query := domain.TourQuery{
+		AuthorId: c.Query("author"),
+		Text:     strings.TrimSpace(c.Query("q")),
+		Page:     1,
+	}
+	for _, value := range c.QueryArray("tags") {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+
+	var err error
+	if query.MinDifficulty, err = intParam(c, "minDifficulty"); err != nil {
+		return query, err
+	}
+	if query.MaxDifficulty, err = intParam(c, "maxDifficulty"); err != nil {
+		return query, err
+	}
+	if query.MinPrice, err = floatParam(c, "minPrice"); err != nil {
+		return query, err
+	}
+	if query.MaxPrice, err = floatParam(c, "maxPrice"); err != nil {
+		return query, err
+	}
+	if query.MinDistance, err = floatParam(c, "minDistance"); err != nil {
+		return query, err
+	}
+	if query.MaxDistance, err = floatParam(c, "maxDistance"); err != nil {
+		return query, err
+	}
+
+	switch transport := domain.TransportType(c.Query("transport")); transport {
+	case "", domain.TransportTypeWalking, domain.TransportTypeBicycle, domain.TransportTypeCar:
+		query.Transport = transport
+	default:
+		return query, errors.New("parametar transport mora biti walking, bicycle ili car")
+	}
+	switch status := domain.TourStatus(c.Query("status")); status {
+	case "", domain.TourStatusDraft, domain.TourStatusPublished, domain.TourStatusArchived:
+		query.Status = status
+	default:
+		return query, errors.New("parametar status mora biti draft, published ili archived")
+	}
+
+	if value := c.Query("bbox"); value != "" {
+		if query.Within, err = parseBoundingBox(value); err != nil {
+			return query, err
+		}
+	}
+
+	switch sortBy := domain.TourSortField(c.Query("sort")); sortBy {
+	case "":
+	case domain.TourSortPublishedAt, domain.TourSortRating:
+		// Najnovije i najbolje ocenjene su podrazumevano prve
+		query.SortBy, query.Descending = sortBy, true
+	case domain.TourSortPrice, domain.TourSortDistance:
+		query.SortBy = sortBy
+	default:
+		return query, errors.New("parametar sort mora biti publishedAt, price, rating ili distance")
+	}
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		query.Descending = false
+	case "desc":
+		query.Descending = true
+	default:
+		return query, errors.New("parametar order mora biti asc ili desc")
+	}
+
+	if page, err := intParam(c, "page"); err != nil {
+		return query, err
+	} else if page != nil {
+		if *page < 1 {
+			return query, errors.New("parametar page mora biti veći od 0")
+		}
+		query.Page = *page
+		query.PageSize = domain.DefaultTourPageSize
+	}
+	if pageSize, err := intParam(c, "pageSize"); err != nil {
+		return query, err
+	} else if pageSize != nil {
+		if *pageSize < 1 || *pageSize > domain.MaxTourPageSize {
+			return query, fmt.Errorf("parametar pageSize mora biti između 1 i %d", domain.MaxTourPageSize)
+		}
+		query.PageSize = *pageSize
+	}
+	return query, nil
+}
+
+// parseBoundingBox čita pravougaonik u GeoJSON bbox redosledu
+// (minLon,minLat,maxLon,maxLat)
+func parseBoundingBox(value string) (*domain.BoundingBox, error) {
+	invalid := errors.New("parametar bbox mora biti minLon,minLat,maxLon,maxLat, sa min manjim od max")
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return nil, invalid
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, invalid
+		}
+		coords[i] = parsed
+	}
+	box := &domain.BoundingBox{MinLongitude: coords[0], MinLatitude: coords[1], MaxLongitude: coords[2], MaxLatitude: coords[3]}
+	if !domain.ValidCoordinates(box.MinLatitude, box.MinLongitude) || !domain.ValidCoordinates(box.MaxLatitude, box.MaxLongitude) ||
+		box.MinLongitude >= box.MaxLongitude || box.MinLatitude >= box.MaxLatitude {
+		return nil, invalid
+	}
+	return box, nil
+}
+
+func intParam(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("parametar %s mora biti ceo broj", name)
+	}
+	return &parsed, nil
+}
+
+func floatParam(c *gin.Context, name string) (*float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parametar %s mora biti broj", name)
+	}
+	return &parsed, nil
+}
+
+// writeTourPage šalje stranicu tura kao niz, sa ukupnim brojem u zaglavlju
+func writeTourPage(c *gin.Context, page *domain.TourPage) {
+	c.Header(TotalCountHeader, strconv.FormatInt(page.Total, 10))
+	c.JSON(http.StatusOK, page.Tours)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+	"tours-service/domain"
+
+	"github.com/gin-gonic/gin"
+)
+
+func queryContext(rawQuery string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/api/tours?"+rawQuery, nil)
+	return c
+}
+
+func TestParseTourQueryPaging(t *testing.T) {
+	tests := []struct {
+		query        string
+		wantPage     int
+		wantPageSize int
+		wantErr      bool
+	}{
+		{"", 1, 0, false}, // bez stranica, kao pre uvođenja stranica
+		{"page=3", 3, domain.DefaultTourPageSize, false},
+		{"pageSize=50", 1, 50, false},
+		{"page=2&pageSize=1", 2, 1, false},
+		{"pageSize=100", 1, domain.MaxTourPageSize, false},
+		{"pageSize=101", 0, 0, true},
+		{"pageSize=0", 0, 0, true},
+		{"pageSize=-5", 0, 0, true},
+		{"page=0", 0, 0, true},
+		{"page=-1", 0, 0, true},
+		{"page=abc", 0, 0, true},
+		{"pageSize=1.5", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, err := parseTourQuery(queryContext(tt.query))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTourQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if query.Page != tt.wantPage || query.PageSize != tt.wantPageSize {
+				t.Errorf("page %d, pageSize %d, want %d, %d", query.Page, query.PageSize, tt.wantPage, tt.wantPageSize)
+			}
+		})
+	}
+}
+
+func TestParseTourQueryFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		check   func(domain.TourQuery) bool
+		wantErr bool
+	}{
+		{"tags", "tags=planina,%20jezero&tags=reka", func(q domain.TourQuery) bool {
+			return len(q.Tags) == 3 && q.Tags[0] == "planina" && q.Tags[1] == "jezero" && q.Tags[2] == "reka"
+		}, false},
+		{"ranges", "minDifficulty=2&maxPrice=10.5", func(q domain.TourQuery) bool {
+			return *q.MinDifficulty == 2 && q.MaxDifficulty == nil && *q.MaxPrice == 10.5 && q.MinPrice == nil
+		}, false},
+		{"rating sorts descending by default", "sort=rating", func(q domain.TourQuery) bool {
+			return q.SortBy == domain.TourSortRating && q.Descending
+		}, false},
+		{"price sorts ascending by default", "sort=price", func(q domain.TourQuery) bool {
+			return q.SortBy == domain.TourSortPrice && !q.Descending
+		}, false},
+		{"explicit order", "sort=publishedAt&order=asc", func(q domain.TourQuery) bool {
+			return q.SortBy == domain.TourSortPublishedAt && !q.Descending
+		}, false},
+		{"bbox", "bbox=19.7,45.2,19.9,45.3", func(q domain.TourQuery) bool {
+			return q.Within != nil && q.Within.MinLongitude == 19.7 && q.Within.MaxLatitude == 45.3
+		}, false},
+		{"unknown sort", "sort=name", nil, true},
+		{"unknown order", "order=up", nil, true},
+		{"unknown transport", "transport=boat", nil, true},
+		{"unknown status", "status=deleted", nil, true},
+		{"non-numeric range", "minPrice=free", nil, true},
+		{"inverted bbox", "bbox=19.9,45.2,19.7,45.3", nil, true},
+		{"bbox out of range", "bbox=-190,45.2,19.7,45.3", nil, true},
+		{"short bbox", "bbox=19.7,45.2,19.9", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := parseTourQuery(queryContext(tt.query))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTourQuery(%q) error = %v, wantErr %v", tt.query, err, tt.wantErr)
+			}
+			if !tt.wantErr && !tt.check(query) {
+				t.Errorf("parseTourQuery(%q) = %+v", tt.query, query)
+			}
+		})
+	}
+}
@@ -3,8 +3,14 @@ package api
 
 import (
 	"context"
+	"errors"
 	"tours-service/service"
 	"tours-service/proto/tours" // Uvozimo generisani proto kod
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type ToursGrpcHandler struct {
@@ -19,9 +25,16 @@ func NewToursGrpcHandler(service service.TourService) *ToursGrpcHandler {
 // Implementiramo GetTourById metodu definisanu u .proto fajlu
 func (h *ToursGrpcHandler) GetTourById(ctx context.Context, req *tours.GetTourByIdRequest) (*tours.GetTourByIdResponse, error) {
 	// Pozivamo postojeći servis da dobavimo turu iz baze
+	// Greške vraćamo kao gRPC status, gateway ih prevodi u odgovarajući HTTP status
 	tour, err := h.service.GetById(req.TourId)
+	if errors.Is(err, primitive.ErrInvalidHex) {
+		return nil, status.Error(codes.InvalidArgument, "invalid tour id")
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, status.Error(codes.NotFound, "tour not found")
+	}
 	if err != nil {
-		return nil, err
+		return nil, status.Error(codes.Internal, err.Error())
 	}
 
 	// Mapiramo naš domain.Tour na proto.GetTourByIdResponse
@@ -48,6 +61,7 @@ func (h *ToursGrpcHandler) GetTourById(ctx context.Context, req *tours.GetTourBy
 			Latitude:    kp.Latitude,
 			Longitude:   kp.Longitude,
 			ImageUrl:    kp.ImageUrl,
+			Order:       int32(kp.Order),
 		})
 	}
 
@@ -0,0 +1,68 @@
+// Komanda backfill-routes ponovo računa distancu, putanju i procenjena
+// vremena postojećih tura u kolekciji tours-db.tours, istim putem kojim ih
+// servis računa posle izmene ključnih tačaka. Koristi iste environment
+// varijable kao servis (MONGO_URI, ROUTING_*, ORS_API_KEY):
+//
+//	docker compose run --rm tours-service /backfill-routes -missing -delay 1.5s
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+	"tours-service/logging"
+	"tours-service/repository"
+	"tours-service/routing"
+	"tours-service/service"
+	"tours-service/startup"
+)
+
+func main() {
+	missing := flag.Bool("missing", false, "samo ture koje još nemaju putanju (routeGeometry)")
+	offline := flag.Bool("offline", false, "i ture čija je ruta procenjena vazdušnom linijom")
+	delay := flag.Duration("delay", 0, "pauza između tura, zbog ograničenja javnog ORS API-ja (npr. 1.5s)")
+	dryRun := flag.Bool("dry-run", false, "samo ispisuje ture koje bi bile obrađene")
+	flag.Parse()
+
+	logging.Setup("tours-backfill-routes")
+	mongoClient := startup.ConnectDB()
+	tourRepo := repository.NewTourRepository(mongoClient)
+	tourService := service.NewTourService(tourRepo, routing.FromEnv())
+
+	tours, err := tourRepo.GetAll()
+	if err != nil {
+		log.Fatalf("Neuspešno čitanje tura: %v", err)
+	}
+
+	var processed, failed int
+	for _, tour := range tours {
+		// -missing i -offline biraju ture; bez njih se obrađuju sve
+		selected := !*missing && !*offline ||
+			*missing && tour.RouteGeometry == nil ||
+			*offline && tour.RouteProvider == "offline"
+		if !selected || len(tour.KeyPoints) < 2 && tour.Distance == 0 {
+			continue
+		}
+		if *dryRun {
+			log.Printf("Tura %s (%s): %d ključnih tačaka, distanca %.2f km", tour.ID.Hex(), tour.Name, len(tour.KeyPoints), tour.Distance)
+			processed++
+			continue
+		}
+		if processed > 0 && *delay > 0 {
+			time.Sleep(*delay)
+		}
+
+		updated, err := tourService.RecomputeRoute(tour.ID.Hex())
+		processed++
+		if err != nil {
+			failed++
+			log.Printf("Tura %s: greška: %v", tour.ID.Hex(), err)
+			continue
+		}
+		log.Printf("Tura %s: %.2f km -> %.2f km (%s)", tour.ID.Hex(), tour.Distance, updated.Distance, updated.RouteProvider)
+	}
+	log.Printf("Obrađeno tura: %d od %d, neuspešno: %d", processed, len(tours), failed)
+	if failed > 0 {
+		log.Fatal("Backfill nije uspeo za sve ture")
+	}
+}
@@ -17,11 +17,129 @@ const docTemplate = `{
     "paths": {
         "/tours": {
             "get": {
-                "description": "Vraća listu svih tura dostupnih u sistemu.",
+                "description": "Vraća stranicu tura dostupnih u sistemu, sa filterima i sortiranjem.",
                 "produces": [
                     "application/json"
                 ],
                 "summary": "Prikaz svih tura",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tagovi odvojeni zarezom; tura mora imati sve",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najmanja težina",
+                        "name": "minDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najveća težina",
+                        "name": "maxDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja cena",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća cena",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja dužina (km)",
+                        "name": "minDistance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća dužina (km)",
+                        "name": "maxDistance",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "walking",
+                            "bicycle",
+                            "car"
+                        ],
+                        "type": "string",
+                        "description": "Način prevoza",
+                        "name": "transport",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Autor ture",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "draft",
+                            "published",
+                            "archived"
+                        ],
+                        "type": "string",
+                        "description": "Status ture",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pretraga naziva, opisa i tagova",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "publishedAt",
+                            "price",
+                            "rating",
+                            "distance"
+                        ],
+                        "type": "string",
+                        "description": "Polje za sortiranje; bez njega najnovije su prve",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Smer sortiranja (publishedAt i rating podrazumevano desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stranica, od 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Lista svih tura",
@@ -30,6 +148,21 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/domain.Tour"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Ukupan broj tura koje odgovaraju filterima"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan parametar upita",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
@@ -89,11 +222,118 @@ const docTemplate = `{
         },
         "/tours/archived": {
             "get": {
-                "description": "Vraća listu tura koje imaju status 'archived'. Za svaku turu prikazuje samo prvu ključnu tačku.",
+                "description": "Vraća stranicu tura koje imaju status 'archived'. Za svaku turu prikazuje samo prvu ključnu tačku.",
                 "produces": [
                     "application/json"
                 ],
                 "summary": "Prikaz svih arhiviranih tura",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tagovi odvojeni zarezom; tura mora imati sve",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najmanja težina",
+                        "name": "minDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najveća težina",
+                        "name": "maxDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja cena",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća cena",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja dužina (km)",
+                        "name": "minDistance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća dužina (km)",
+                        "name": "maxDistance",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "walking",
+                            "bicycle",
+                            "car"
+                        ],
+                        "type": "string",
+                        "description": "Način prevoza",
+                        "name": "transport",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Autor ture",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pretraga naziva, opisa i tagova",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "publishedAt",
+                            "price",
+                            "rating",
+                            "distance"
+                        ],
+                        "type": "string",
+                        "description": "Polje za sortiranje; bez njega najnovije su prve",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Smer sortiranja (publishedAt i rating podrazumevano desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stranica, od 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Lista arhiviranih tura",
@@ -102,6 +342,21 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/domain.Tour"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Ukupan broj tura koje odgovaraju filterima"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan parametar upita",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
@@ -114,11 +369,123 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Vraća listu svih tura koje je kreirao autor čiji se token koristi.",
+                "description": "Vraća stranicu tura koje je kreirao autor čiji se token koristi.",
                 "produces": [
                     "application/json"
                 ],
                 "summary": "Prikaz tura kreiranih od strane ulogovanog autora",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tagovi odvojeni zarezom; tura mora imati sve",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najmanja težina",
+                        "name": "minDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najveća težina",
+                        "name": "maxDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja cena",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća cena",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja dužina (km)",
+                        "name": "minDistance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća dužina (km)",
+                        "name": "maxDistance",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "walking",
+                            "bicycle",
+                            "car"
+                        ],
+                        "type": "string",
+                        "description": "Način prevoza",
+                        "name": "transport",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "draft",
+                            "published",
+                            "archived"
+                        ],
+                        "type": "string",
+                        "description": "Status ture",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pretraga naziva, opisa i tagova",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "publishedAt",
+                            "price",
+                            "rating",
+                            "distance"
+                        ],
+                        "type": "string",
+                        "description": "Polje za sortiranje; bez njega najnovije su prve",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Smer sortiranja (publishedAt i rating podrazumevano desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stranica, od 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Lista tura",
@@ -127,6 +494,21 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/domain.Tour"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Ukupan broj tura koje odgovaraju filterima"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan parametar upita",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     },
                     "401": {
@@ -141,13 +523,248 @@ const docTemplate = `{
                 }
             }
         },
+        "/tours/nearby": {
+            "get": {
+                "description": "Vraća objavljene ture čija je početna ili bilo koja ključna tačka u krugu od radiusKm oko lokacije, najbliže prve. Za svaku turu prikazuje samo prvu ključnu tačku; nearestKm je udaljenost najbliže ključne tačke.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Objavljene ture u blizini",
+                "parameters": [
+                    {
+                        "type": "number",
+                        "description": "Geografska širina",
+                        "name": "lat",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Geografska dužina",
+                        "name": "lon",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Poluprečnik u kilometrima (podrazumevano 10, najviše 500)",
+                        "name": "radiusKm",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Tagovi odvojeni zarezom; tura mora imati sve",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najmanja težina",
+                        "name": "minDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najveća težina",
+                        "name": "maxDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja cena",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća cena",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja dužina (km)",
+                        "name": "minDistance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća dužina (km)",
+                        "name": "maxDistance",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "walking",
+                            "bicycle",
+                            "car"
+                        ],
+                        "type": "string",
+                        "description": "Način prevoza",
+                        "name": "transport",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Autor ture",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stranica, od 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Broj tura po stranici (podrazumevano 20, najviše 100)",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ture u blizini",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/domain.NearbyTour"
+                            }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Ukupan broj tura u krugu koje odgovaraju filterima"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravna lokacija, poluprečnik ili parametar upita",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
         "/tours/published": {
             "get": {
-                "description": "Vraća listu tura koje imaju status 'published'. Za svaku turu prikazuje samo prvu ključnu tačku.",
+                "description": "Vraća stranicu tura koje imaju status 'published'. Za svaku turu prikazuje samo prvu ključnu tačku.",
                 "produces": [
                     "application/json"
                 ],
                 "summary": "Prikaz svih objavljenih tura (za turiste)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Tagovi odvojeni zarezom; tura mora imati sve",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najmanja težina",
+                        "name": "minDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Najveća težina",
+                        "name": "maxDifficulty",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja cena",
+                        "name": "minPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća cena",
+                        "name": "maxPrice",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najmanja dužina (km)",
+                        "name": "minDistance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Najveća dužina (km)",
+                        "name": "maxDistance",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "walking",
+                            "bicycle",
+                            "car"
+                        ],
+                        "type": "string",
+                        "description": "Način prevoza",
+                        "name": "transport",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Autor ture",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Pretraga naziva, opisa i tagova",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Prikaz mape minLon,minLat,maxLon,maxLat, bez prelaska 180. meridijana; ture sa ključnom tačkom u njemu. Granice prate paralele do oko 30 m, a oblasti bliže polu od 0.1° se ne pretražuju",
+                        "name": "bbox",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "publishedAt",
+                            "price",
+                            "rating",
+                            "distance"
+                        ],
+                        "type": "string",
+                        "description": "Polje za sortiranje; bez njega najnovije su prve",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Smer sortiranja (publishedAt i rating podrazumevano desc)",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Stranica, od 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Broj tura po stranici (podrazumevano 20 uz page, najviše 100); bez page i pageSize vraćaju se sve ture",
+                        "name": "pageSize",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Lista objavljenih tura",
@@ -156,6 +773,21 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/domain.Tour"
                             }
+                        },
+                        "headers": {
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "Ukupan broj tura koje odgovaraju filterima"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan parametar upita",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
                         }
                     }
                 }
@@ -261,7 +893,7 @@ const docTemplate = `{
                         "ApiKeyAuth": []
                     }
                 ],
-                "description": "Dodaje novu ključnu tačku na turu sa datim ID-jem. Samo autor ture može dodati tačku.",
+                "description": "Dodaje novu ključnu tačku na turu sa datim ID-jem. Samo autor ture može dodati tačku. Bez parametra position tačka se dodaje na kraj.",
                 "consumes": [
                     "application/json"
                 ],
@@ -277,6 +909,12 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "integer",
+                        "description": "Redni broj (od 1) na koji se tačka umeće; ostale se pomeraju",
+                        "name": "position",
+                        "in": "query"
+                    },
                     {
                         "description": "Podaci o ključnoj tački",
                         "name": "keyPoint",
@@ -293,6 +931,94 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/domain.TourKeyPoint"
                         }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan format zahteva, koordinate ili pozicija",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/tours/{id}/keypoints/order": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Postavlja novi redosled ključnih tačaka ture u jednom upisu. Lista mora sadržati ID svake ključne tačke ture tačno jednom. Distanca i putanja se računaju ponovo.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Promena redosleda ključnih tačaka",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID Ture",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "ID-jevi ključnih tačaka u novom redosledu",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.reorderKeyPointsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tura sa novim redosledom",
+                        "schema": {
+                            "$ref": "#/definitions/domain.Tour"
+                        }
+                    },
+                    "400": {
+                        "description": "Greška: Lista nije redosled svih ključnih tačaka ture",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Greška: Korisnik nije autorizovan",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Greška: Nemate dozvolu",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "409": {
+                        "description": "Greška: Ključne tačke su u međuvremenu izmenjene",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             }
@@ -343,6 +1069,24 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/domain.TourKeyPoint"
                         }
+                    },
+                    "400": {
+                        "description": "Greška: Neispravan format zahteva ili koordinate",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Greška: Tura ili ključna tačka nije pronađena",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     }
                 }
             },
@@ -623,6 +1367,153 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "api.reorderKeyPointsRequest": {
+            "type": "object",
+            "required": [
+                "keyPointIds"
+            ],
+            "properties": {
+                "keyPointIds": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "domain.GeoPoint": {
+            "type": "object",
+            "properties": {
+                "coordinates": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "type": {
+                    "type": "string",
+                    "example": "Point"
+                }
+            }
+        },
+        "domain.LineString": {
+            "type": "object",
+            "properties": {
+                "coordinates": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "type": {
+                    "type": "string",
+                    "example": "LineString"
+                }
+            }
+        },
+        "domain.NearbyTour": {
+            "type": "object",
+            "required": [
+                "description",
+                "difficulty",
+                "name"
+            ],
+            "properties": {
+                "archivedAt": {
+                    "description": "Vreme arhiviranja",
+                    "type": "string"
+                },
+                "authorId": {
+                    "type": "string"
+                },
+                "averageRating": {
+                    "description": "Prosečna ocena recenzija, 0 bez recenzija",
+                    "type": "number"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "difficulty": {
+                    "type": "integer"
+                },
+                "distance": {
+                    "description": "--- NOVA POLJA ---",
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "keyPoints": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.TourKeyPoint"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "nearestKm": {
+                    "type": "number"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "publishedAt": {
+                    "description": "Vreme objave",
+                    "type": "string"
+                },
+                "reviews": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.TourReview"
+                    }
+                },
+                "routeGeometry": {
+                    "description": "Putanja kroz ključne tačke",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.LineString"
+                        }
+                    ]
+                },
+                "routeProvider": {
+                    "description": "Ko je izračunao rutu (ors, osrm, offline)",
+                    "type": "string"
+                },
+                "startPoint": {
+                    "description": "Lokacija prve ključne tačke, postavlja je servis",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.GeoPoint"
+                        }
+                    ]
+                },
+                "status": {
+                    "description": "IZMENA: Koristimo novi tip",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.TourStatus"
+                        }
+                    ]
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "transportInfo": {
+                    "description": "Lista vremena putovanja",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/domain.TourTransport"
+                    }
+                }
+            }
+        },
         "domain.Tour": {
             "type": "object",
             "required": [
@@ -638,6 +1529,10 @@ const docTemplate = `{
                 "authorId": {
                     "type": "string"
                 },
+                "averageRating": {
+                    "description": "Prosečna ocena recenzija, 0 bez recenzija",
+                    "type": "number"
+                },
                 "description": {
                     "type": "string"
                 },
@@ -673,6 +1568,26 @@ const docTemplate = `{
                         "$ref": "#/definitions/domain.TourReview"
                     }
                 },
+                "routeGeometry": {
+                    "description": "Putanja kroz ključne tačke",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.LineString"
+                        }
+                    ]
+                },
+                "routeProvider": {
+                    "description": "Ko je izračunao rutu (ors, osrm, offline)",
+                    "type": "string"
+                },
+                "startPoint": {
+                    "description": "Lokacija prve ključne tačke, postavlja je servis",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.GeoPoint"
+                        }
+                    ]
+                },
                 "status": {
                     "description": "IZMENA: Koristimo novi tip",
                     "allOf": [
@@ -716,12 +1631,24 @@ const docTemplate = `{
                 "latitude": {
                     "type": "number"
                 },
+                "location": {
+                    "description": "GeoJSON iz latitude/longitude, postavlja ga servis",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/domain.GeoPoint"
+                        }
+                    ]
+                },
                 "longitude": {
                     "type": "number"
                 },
                 "name": {
                     "type": "string"
                 },
+                "order": {
+                    "description": "Redni broj u turi (od 1), postavlja ga servis",
+                    "type": "integer"
+                },
                 "tourId": {
                     "type": "string"
                 }
@@ -778,6 +1705,9 @@ const docTemplate = `{
         "domain.TourTransport": {
             "type": "object",
             "properties": {
+                "estimated": {
+                    "type": "boolean"
+                },
                 "timeInMinutes": {
                     "type": "integer"
                 },
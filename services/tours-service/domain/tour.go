@@ -26,9 +26,12 @@ const (
 )
 
 // --- NOVO: Struktura za čuvanje vremena putovanja ---
+// Vreme koje autor ne unese (0) procenjuje se iz rute kroz ključne tačke i
+// označava sa Estimated, pa se preračunava kada se ruta promeni
 type TourTransport struct {
 	Type          TransportType `bson:"type" json:"type"`
 	TimeInMinutes int           `bson:"timeInMinutes" json:"timeInMinutes"`
+	Estimated     bool          `bson:"estimated" json:"estimated"`
 }
 
 // Tour definiše model ture u sistemu
@@ -42,6 +45,7 @@ type Tour struct {
 	Status        TourStatus         `bson:"status" json:"status"` // IZMENA: Koristimo novi tip
 	Price         float64            `bson:"price" json:"price"`
 	Reviews       []TourReview       `bson:"reviews" json:"reviews"`
+	AverageRating float64            `bson:"averageRating" json:"averageRating"` // Prosečna ocena recenzija, 0 bez recenzija
 	KeyPoints     []TourKeyPoint     `bson:"keyPoints" json:"keyPoints"`
 	
 	// --- NOVA POLJA ---
@@ -49,4 +53,28 @@ type Tour struct {
 	TransportInfo []TourTransport `bson:"transportInfo" json:"transportInfo"` // Lista vremena putovanja
 	PublishedAt   *time.Time      `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"` // Vreme objave
 	ArchivedAt    *time.Time      `bson:"archivedAt,omitempty" json:"archivedAt,omitempty"` // Vreme arhiviranja
-}
\ No newline at end of file
+	RouteGeometry *LineString     `bson:"routeGeometry,omitempty" json:"routeGeometry,omitempty"` // Putanja kroz ključne tačke
+	RouteProvider string          `bson:"routeProvider,omitempty" json:"routeProvider,omitempty"` // Ko je izračunao rutu (ors, osrm, offline)
+	StartPoint    *GeoPoint       `bson:"startPoint,omitempty" json:"startPoint,omitempty"` // Lokacija prve ključne tačke, postavlja je servis
+}
+
+// LineString je GeoJSON linija; koordinate su [longitude, latitude] parovi
+type LineString struct {
+	Type        string      `bson:"type" json:"type" example:"LineString"`
+	Coordinates [][]float64 `bson:"coordinates" json:"coordinates"`
+}
+
+// GeoPoint je GeoJSON tačka; koordinate su [longitude, latitude]
+type GeoPoint struct {
+	Type        string    `bson:"type" json:"type" example:"Point"`
+	Coordinates []float64 `bson:"coordinates" json:"coordinates"`
+}
+
+func NewGeoPoint(latitude, longitude float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+// ValidCoordinates proverava da li su geografska širina i dužina u dozvoljenom opsegu
+func ValidCoordinates(latitude, longitude float64) bool {
+	return latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
@@ -13,4 +13,6 @@ type TourKeyPoint struct {
 	Latitude    float64            `bson:"latitude" json:"latitude" binding:"required"`
 	Longitude   float64            `bson:"longitude" json:"longitude" binding:"required"`
 	ImageUrl    string             `bson:"imageUrl" json:"imageUrl"`
+	Order       int                `bson:"order" json:"order"` // Redni broj u turi (od 1), postavlja ga servis
+	Location    *GeoPoint          `bson:"location,omitempty" json:"location,omitempty"` // GeoJSON iz latitude/longitude, postavlja ga servis
 }
\ No newline at end of file
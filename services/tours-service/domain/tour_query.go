@@ -0,0 +1,69 @@
+// domain/tour_query.go
+
+package domain
+
+// TourSortField je polje po kome se sortiraju liste tura
+type TourSortField string
+
+const (
+	TourSortPublishedAt TourSortField = "publishedAt"
+	TourSortPrice       TourSortField = "price"
+	TourSortRating      TourSortField = "rating"
+	TourSortDistance    TourSortField = "distance"
+)
+
+const (
+	DefaultTourPageSize = 20
+	MaxTourPageSize     = 100
+)
+
+// TourQuery opisuje filtere, sortiranje i stranicu liste tura. Prazna polja
+// (nil za opsege) ne filtriraju; bez SortBy najnovije ture su prve, a uz
+// pretragu teksta najrelevantnije.
+type TourQuery struct {
+	Tags          []string // Tura mora imati sve navedene tagove
+	MinDifficulty *int
+	MaxDifficulty *int
+	MinPrice      *float64
+	MaxPrice      *float64
+	MinDistance   *float64 // U kilometrima
+	MaxDistance   *float64
+	Transport     TransportType
+	AuthorId      string
+	Status        TourStatus
+	Text          string       // Pretraga naziva, opisa i tagova
+	Within        *BoundingBox // Ture sa bar jednom ključnom tačkom u pravougaoniku
+
+	SortBy     TourSortField
+	Descending bool
+
+	Page     int // Od 1
+	PageSize int // 0 vraća sve ture
+}
+
+// TourPage je jedna stranica liste tura i ukupan broj tura koje odgovaraju filterima
+type TourPage struct {
+	Tours []*Tour
+	Total int64
+}
+
+// BoundingBox je pravougaonik prikaza mape; ne prelazi 180. meridijan
+type BoundingBox struct {
+	MinLongitude float64
+	MinLatitude  float64
+	MaxLongitude float64
+	MaxLatitude  float64
+}
+
+// NearbyTour je tura iz pretrage po blizini, sa udaljenošću njene najbliže
+// ključne tačke od tražene lokacije
+type NearbyTour struct {
+	Tour      `bson:",inline"`
+	NearestKm float64 `bson:"nearestKm" json:"nearestKm"`
+}
+
+// NearbyTourPage je jedna stranica pretrage po blizini, najbliže ture prve
+type NearbyTourPage struct {
+	Tours []*NearbyTour
+	Total int64
+}
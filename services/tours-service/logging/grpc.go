@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadataKey is the gRPC metadata equivalent of RequestIDHeader
+const requestIDMetadataKey = "x-request-id"
+
+// UnaryServerInterceptor picks up the caller's request id from the metadata
+// and writes one JSON log line per gRPC call
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	requestID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadataKey); len(values) > 0 && validRequestID(values[0]) {
+			requestID = values[0]
+		}
+	}
+	if requestID == "" {
+		requestID = NewRequestID()
+	}
+	ctx = WithRequestID(ctx, requestID)
+
+	resp, err := handler(ctx, req)
+
+	attrs := []any{
+		"request_id", requestID,
+		"grpc_method", info.FullMethod,
+		"grpc_code", status.Code(err).String(),
+		"duration_ms", time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err.Error())
+	}
+	slog.Info("grpc request", attrs...)
+	return resp, err
+}
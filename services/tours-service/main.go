@@ -5,11 +5,15 @@ import (
 	"log"
 	"net"
 	"tours-service/api"
+	"tours-service/logging"
+	"tours-service/metrics"
 	"tours-service/repository"
+	"tours-service/routing"
 	"tours-service/service"
 	"tours-service/startup"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
 	"tours-service/proto/tours" // Uvozimo generisani proto kod
 )
 
@@ -22,6 +26,8 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	logging.Setup("tours-service")
+
 	// Pokrećemo gRPC server u pozadini (u posebnoj "gorutini")
 	// da ne bi blokirao pokretanje HTTP servera.
 	go startGrpcServer()
@@ -42,16 +48,19 @@ func startGrpcServer() {
 	// Inicijalizujemo slojeve, slično kao za REST
 	mongoClient := startup.ConnectDB()
 	tourRepo := repository.NewTourRepository(mongoClient)
-	tourService := service.NewTourService(tourRepo)
+	tourService := service.NewTourService(tourRepo, routing.FromEnv())
 	
 	// Kreiramo instancu našeg novog gRPC hendlera
 	toursHandler := api.NewToursGrpcHandler(tourService)
 
 	// Kreiramo novi gRPC server
-	grpcServer := grpc.NewServer()
+	// Interceptori preuzimaju X-Request-ID iz metadata, loguju i mere svaki poziv
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(logging.UnaryServerInterceptor, metrics.UnaryServerInterceptor))
 	
 	// Registrujemo naš hendler na server
 	tours.RegisterToursServiceServer(grpcServer, toursHandler)
+	// Reflection objavljuje proto šeme, API gateway iz njih pravi REST/JSON rute
+	reflection.Register(grpcServer)
 
 	log.Println("gRPC server is listening on port 8086")
 	// Pokrećemo server da sluša na definisanom portu
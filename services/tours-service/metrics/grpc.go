@@ -0,0 +1,33 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grpc_server_handled_total",
+		Help: "gRPC calls handled by the server, by method and status code.",
+	}, []string{"grpc_method", "grpc_code"})
+
+	grpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "gRPC server call latency, by method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"grpc_method"})
+)
+
+// UnaryServerInterceptor counts and times every unary call
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	grpcDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+	grpcHandled.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+	return resp, err
+}
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// HTTP metrics, labeled by the route template (not the raw path) so ids in
+// the URL do not create a new series per request
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests handled, by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// RoutingRequests counts route calculations, by provider (ors, osrm,
+// offline), transport type and result (ok, error)
+var RoutingRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "routing_requests_total",
+	Help: "Route distance calculations, by provider, transport type and result.",
+}, []string{"provider", "transport", "result"})
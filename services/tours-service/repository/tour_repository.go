@@ -2,26 +2,35 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"math"
+	"time"
 	"tours-service/domain"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type TourRepository interface {
 	Create(tour *domain.Tour) error
 	GetById(tourId string) (*domain.Tour, error) // <-- DODATI
-	GetByAuthorId(authorId string) ([]*domain.Tour, error)
+	Find(query domain.TourQuery) (*domain.TourPage, error)
 	GetAll() ([]*domain.Tour, error)                          // <-- DODATO
 	AddReview(tourId string, review *domain.TourReview) error // <-- DODATO
 	// NOVE METODE ZA KEY POINTS
-	AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error
+	AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint, position int) error
 	UpdateKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error
 	DeleteKeyPoint(tourId, keyPointId string) error
+	ReorderKeyPoints(tourId string, keyPointIds []primitive.ObjectID) (bool, error)
+	EnsureKeyPointOrder() (int64, error)
 	Update(tour *domain.Tour) error // <-- DODATI NOVU METODU
-	GetPublished() ([]*domain.Tour, error) // <-- DODATA NOVA METODA
-	GetArchived() ([]*domain.Tour, error) // NOVO
+	UpdateRoute(tour *domain.Tour) (bool, error)
+	EnsureIndexes() error
+	EnsureAverageRating() (int64, error)
+	EnsureKeyPointLocations() (int64, error)
+	Nearby(latitude, longitude, radiusKm float64, query domain.TourQuery) (*domain.NearbyTourPage, error)
 }
 
 type tourRepository struct {
@@ -56,10 +65,126 @@ func (r *tourRepository) GetById(tourId string) (*domain.Tour, error) {
 	return &tour, nil
 }
 
-func (r *tourRepository) GetByAuthorId(authorId string) ([]*domain.Tour, error) {
-	var tours []*domain.Tour
-	filter := bson.M{"authorId": authorId}
-	cursor, err := r.tours.Find(context.TODO(), filter)
+// sortFields mapira polja za sortiranje na polja dokumenta
+var sortFields = map[domain.TourSortField]string{
+	domain.TourSortPublishedAt: "publishedAt",
+	domain.TourSortPrice:       "price",
+	domain.TourSortRating:      "averageRating",
+	domain.TourSortDistance:    "distance",
+}
+
+// tourFilter pravi Mongo filter iz filtera upita (bez sortiranja i stranice)
+func tourFilter(query domain.TourQuery) bson.M {
+	filter := bson.M{}
+	if query.Status != "" {
+		filter["status"] = query.Status
+	}
+	if query.AuthorId != "" {
+		filter["authorId"] = query.AuthorId
+	}
+	if len(query.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": query.Tags}
+	}
+	if query.Transport != "" {
+		filter["transportInfo.type"] = query.Transport
+	}
+	if query.Text != "" {
+		filter["$text"] = bson.M{"$search": query.Text}
+	}
+	setRange(filter, "difficulty", query.MinDifficulty, query.MaxDifficulty)
+	setRange(filter, "price", query.MinPrice, query.MaxPrice)
+	setRange(filter, "distance", query.MinDistance, query.MaxDistance)
+	if query.Within != nil {
+		within := bson.A{}
+		for _, polygon := range boundingBoxPolygons(*query.Within) {
+			within = append(within, bson.M{"keyPoints.location": bson.M{"$geoWithin": bson.M{"$geometry": polygon}}})
+		}
+		if len(within) > 0 {
+			filter["$or"] = within
+		} else {
+			// Pravougaonik je ceo uz pol, gde nema ključnih tačaka
+			filter["_id"] = bson.M{"$in": bson.A{}}
+		}
+	}
+	return filter
+}
+
+const (
+	// Ivice poligona na 2dsphere indeksu su delovi velikih krugova, a ne
+	// paralele; sa temenima na svakih pola stepena dužine gornja i donja ivica
+	// odstupaju od paralele najviše oko 30 m
+	boundingBoxEdgeStep = 0.5
+	// Širi prikaz se deli na više poligona, svaki manji od polulopte
+	maxBoundingBoxPolygonWidth = 90.0
+	// Na samom polu bi se temena ivice poklopila, što S2 ne prihvata
+	maxBoundingBoxLatitude = 89.9
+)
+
+// boundingBoxPolygons pretvara pravougaonik dužina/širina u GeoJSON poligone
+// čija unija ga prati do na boundingBoxEdgeStep
+func boundingBoxPolygons(box domain.BoundingBox) []bson.M {
+	minLat := math.Max(box.MinLatitude, -maxBoundingBoxLatitude)
+	maxLat := math.Min(box.MaxLatitude, maxBoundingBoxLatitude)
+	if minLat >= maxLat {
+		return nil
+	}
+	parts := int(math.Ceil((box.MaxLongitude - box.MinLongitude) / maxBoundingBoxPolygonWidth))
+	width := (box.MaxLongitude - box.MinLongitude) / float64(parts)
+
+	var polygons []bson.M
+	for part := 0; part < parts; part++ {
+		west := box.MinLongitude + float64(part)*width
+		east := west + width
+		if part == parts-1 {
+			east = box.MaxLongitude
+		}
+		steps := int(math.Ceil((east - west) / boundingBoxEdgeStep))
+
+		// Prsten ide suprotno od kazaljke na satu: donja ivica ka istoku,
+		// gornja nazad ka zapadu; bočne ivice su meridijani, pa su tačne
+		ring := bson.A{}
+		for i := 0; i <= steps; i++ {
+			ring = append(ring, bson.A{west + (east-west)*float64(i)/float64(steps), minLat})
+		}
+		for i := steps; i >= 0; i-- {
+			ring = append(ring, bson.A{west + (east-west)*float64(i)/float64(steps), maxLat})
+		}
+		ring = append(ring, bson.A{west, minLat})
+		polygons = append(polygons, bson.M{"type": "Polygon", "coordinates": bson.A{ring}})
+	}
+	return polygons
+}
+
+// Find vraća jednu stranicu tura koje odgovaraju upitu (sve ture ako PageSize
+// nije zadat) i njihov ukupan broj
+func (r *tourRepository) Find(query domain.TourQuery) (*domain.TourPage, error) {
+	filter := tourFilter(query)
+
+	// _id na kraju čini redosled stabilnim između stranica
+	direction := 1
+	if query.Descending {
+		direction = -1
+	}
+	var sort bson.D
+	switch {
+	case query.SortBy != "":
+		sort = bson.D{{Key: sortFields[query.SortBy], Value: direction}, {Key: "_id", Value: direction}}
+	case query.Text != "":
+		sort = bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}, {Key: "_id", Value: -1}}
+	default:
+		sort = bson.D{{Key: "_id", Value: -1}}
+	}
+	opts := options.Find().SetSort(sort)
+	if query.PageSize > 0 {
+		opts.SetSkip(int64((query.Page - 1) * query.PageSize)).SetLimit(int64(query.PageSize))
+	}
+
+	total, err := r.tours.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	tours := []*domain.Tour{}
+	cursor, err := r.tours.Find(context.TODO(), filter, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +192,105 @@ func (r *tourRepository) GetByAuthorId(authorId string) ([]*domain.Tour, error)
 	if err = cursor.All(context.TODO(), &tours); err != nil {
 		return nil, err
 	}
-	return tours, nil
+	return &domain.TourPage{Tours: tours, Total: total}, nil
+}
+
+// Nearby vraća stranicu tura sa bar jednom ključnom tačkom (među njima je i
+// početna) u krugu od radiusKm oko tačke, najbliže prve. Filteri upita se
+// primenjuju, osim pretrage teksta koju $geoNear ne podržava.
+func (r *tourRepository) Nearby(latitude, longitude, radiusKm float64, query domain.TourQuery) (*domain.NearbyTourPage, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":               domain.NewGeoPoint(latitude, longitude),
+			"key":                "keyPoints.location",
+			"distanceField":      "nearestKm",
+			"distanceMultiplier": 0.001,
+			"maxDistance":        radiusKm * 1000,
+			"spherical":          true,
+			"query":              tourFilter(query),
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"tours": bson.A{
+				bson.M{"$skip": (query.Page - 1) * query.PageSize},
+				bson.M{"$limit": query.PageSize},
+			},
+			"total": bson.A{bson.M{"$count": "count"}},
+		}}},
+	}
+	cursor, err := r.tours.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	var results []struct {
+		Tours []*domain.NearbyTour `bson:"tours"`
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+	}
+	if err = cursor.All(context.TODO(), &results); err != nil {
+		return nil, err
+	}
+	page := &domain.NearbyTourPage{Tours: []*domain.NearbyTour{}}
+	if len(results) > 0 {
+		if results[0].Tours != nil {
+			page.Tours = results[0].Tours
+		}
+		if len(results[0].Total) > 0 {
+			page.Total = results[0].Total[0].Count
+		}
+	}
+	return page, nil
+}
+
+// setRange dodaje filter opsega [min, max] za polje; nil granica se ne proverava
+func setRange[T int | float64](filter bson.M, field string, min, max *T) {
+	bounds := bson.M{}
+	if min != nil {
+		bounds["$gte"] = *min
+	}
+	if max != nil {
+		bounds["$lte"] = *max
+	}
+	if len(bounds) > 0 {
+		filter[field] = bounds
+	}
+}
+
+// EnsureIndexes pravi indekse za filtere i sortiranja liste tura. Liste se
+// uglavnom filtriraju po statusu ili autoru, pa su oni prvi u indeksima.
+func (r *tourRepository) EnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := r.tours.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "publishedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "price", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "averageRating", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "distance", Value: 1}}},
+		{Keys: bson.D{{Key: "authorId", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "transportInfo.type", Value: 1}}},
+		// Pretraga po blizini i po pravougaoniku mape
+		{Keys: bson.D{{Key: "keyPoints.location", Value: "2dsphere"}}},
+		// Tekstualna pretraga; "none" isključuje stemovanje jer srpski nije podržan
+		{
+			Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}, {Key: "tags", Value: "text"}},
+			Options: options.Index().SetName("tours_text").SetDefaultLanguage("none").SetWeights(bson.M{"name": 5, "tags": 3, "description": 1}),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	// startPoint je uvek lokacija prve ključne tačke, pa se pretražuje preko
+	// keyPoints.location; ranije napravljen indeks samo usporava upise
+	if _, err := r.tours.Indexes().DropOne(ctx, "startPoint_2dsphere"); err != nil {
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || commandErr.Name != "IndexNotFound" {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *tourRepository) GetAll() ([]*domain.Tour, error) {
@@ -82,39 +305,144 @@ func (r *tourRepository) GetAll() ([]*domain.Tour, error) {
 	return tours, nil
 }
 
+// setAverageRating je korak pipeline-a koji iz recenzija računa averageRating
+var setAverageRating = bson.D{{Key: "$set", Value: bson.M{
+	"averageRating": bson.M{"$ifNull": bson.A{bson.M{"$avg": "$reviews.rating"}, 0}},
+}}}
+
+// AddReview dodaje recenziju i u istom upisu ponovo računa prosečnu ocenu
 func (r *tourRepository) AddReview(tourId string, review *domain.TourReview) error {
 	objID, err := primitive.ObjectIDFromHex(tourId)
 	if err != nil {
 		return err
 	}
 	filter := bson.M{"_id": objID}
-	update := bson.M{"$push": bson.M{"reviews": review}}
-	_, err = r.tours.UpdateOne(context.TODO(), filter, update)
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"reviews": bson.M{"$concatArrays": bson.A{
+			bson.M{"$ifNull": bson.A{"$reviews", bson.A{}}},
+			bson.A{bson.M{"$literal": review}},
+		}}}}},
+		setAverageRating,
+	}
+	_, err = r.tours.UpdateOne(context.TODO(), filter, pipeline)
 	return err
 }
 
-func (r *tourRepository) AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error {
+// EnsureAverageRating računa averageRating turama sačuvanim pre uvođenja tog
+// polja. Vraća broj izmenjenih tura.
+func (r *tourRepository) EnsureAverageRating() (int64, error) {
+	filter := bson.M{"averageRating": bson.M{"$exists": false}}
+	result, err := r.tours.UpdateMany(context.TODO(), filter, mongo.Pipeline{setAverageRating})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// renumberKeyPoints je korak pipeline-a koji ključnim tačkama postavlja
+// order prema poziciji u nizu (od 1). Niz se uvek čuva sortiran po redosledu.
+var renumberKeyPoints = bson.D{{Key: "$set", Value: bson.M{"keyPoints": bson.M{"$map": bson.M{
+	"input": bson.M{"$range": bson.A{0, bson.M{"$size": "$keyPoints"}}},
+	"as":    "i",
+	"in": bson.M{"$mergeObjects": bson.A{
+		bson.M{"$arrayElemAt": bson.A{"$keyPoints", "$$i"}},
+		bson.M{"order": bson.M{"$add": bson.A{"$$i", 1}}},
+	}},
+}}}}}
+
+// setStartPoint je korak pipeline-a koji startPoint postavlja na lokaciju prve
+// ključne tačke, a uklanja ga kada tačaka nema
+var setStartPoint = bson.D{{Key: "$set", Value: bson.M{"startPoint": bson.M{"$cond": bson.A{
+	bson.M{"$gt": bson.A{bson.M{"$size": "$keyPoints"}, 0}},
+	bson.M{"$arrayElemAt": bson.A{"$keyPoints.location", 0}},
+	"$$REMOVE",
+}}}}}
+
+// AddKeyPoint umeće ključnu tačku na zadatu poziciju (od 1) i prenumeriše
+// ostale u istom upisu. Pozicija 0 ili veća od broja tačaka dodaje na kraj.
+// U keyPoint.Order upisuje dodeljeni redni broj.
+func (r *tourRepository) AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint, position int) error {
 	tourObjID, err := primitive.ObjectIDFromHex(tourId)
 	if err != nil {
 		return err
 	}
-	filter := bson.M{"_id": tourObjID}
-	update := bson.M{"$push": bson.M{"keyPoints": keyPoint}}
-	_, err = r.tours.UpdateOne(context.TODO(), filter, update)
-	return err
+	keyPoints := bson.M{"$ifNull": bson.A{"$keyPoints", bson.A{}}}
+	// $literal da se vrednosti koje počinju sa $ ne tumače kao putanje polja
+	inserted := bson.A{bson.M{"$literal": keyPoint}}
+	var merged bson.M
+	if position <= 0 {
+		merged = bson.M{"$concatArrays": bson.A{keyPoints, inserted}}
+	} else {
+		merged = bson.M{"$concatArrays": bson.A{
+			bson.M{"$slice": bson.A{keyPoints, position - 1}},
+			inserted,
+			bson.M{"$slice": bson.A{keyPoints, position - 1, bson.M{"$max": bson.A{bson.M{"$size": keyPoints}, 1}}}},
+		}}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"keyPoints": merged}}},
+		renumberKeyPoints,
+		setStartPoint,
+	}
+
+	var tour domain.Tour
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.tours.FindOneAndUpdate(context.TODO(), bson.M{"_id": tourObjID}, pipeline, opts).Decode(&tour)
+	if err != nil {
+		return err
+	}
+	for _, kp := range tour.KeyPoints {
+		if kp.ID == keyPoint.ID {
+			keyPoint.Order = kp.Order
+		}
+	}
+	return nil
 }
 
+// UpdateKeyPoint menja podatke ključne tačke; redosled ostaje isti, za njega
+// služi ReorderKeyPoints. Ako je tačka prva, menja se i startPoint.
 func (r *tourRepository) UpdateKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error {
 	tourObjID, err := primitive.ObjectIDFromHex(tourId)
 	if err != nil {
 		return err
 	}
 	filter := bson.M{"_id": tourObjID, "keyPoints._id": keyPoint.ID}
-	update := bson.M{"$set": bson.M{"keyPoints.$": keyPoint}}
-	_, err = r.tours.UpdateOne(context.TODO(), filter, update)
-	return err
+	changes := bson.M{
+		"tourId":      keyPoint.TourId,
+		"name":        keyPoint.Name,
+		"description": keyPoint.Description,
+		"latitude":    keyPoint.Latitude,
+		"longitude":   keyPoint.Longitude,
+		"imageUrl":    keyPoint.ImageUrl,
+		"location":    keyPoint.Location,
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"keyPoints": bson.M{"$map": bson.M{
+			"input": "$keyPoints",
+			"as":    "kp",
+			"in": bson.M{"$cond": bson.A{
+				bson.M{"$eq": bson.A{"$$kp._id", keyPoint.ID}},
+				bson.M{"$mergeObjects": bson.A{"$$kp", bson.M{"$literal": changes}}},
+				"$$kp",
+			}},
+		}}}}},
+		setStartPoint,
+	}
+	var tour domain.Tour
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.tours.FindOneAndUpdate(context.TODO(), filter, pipeline, opts).Decode(&tour)
+	if err != nil {
+		return err
+	}
+	for _, kp := range tour.KeyPoints {
+		if kp.ID == keyPoint.ID {
+			keyPoint.Order = kp.Order
+		}
+	}
+	return nil
 }
 
+// DeleteKeyPoint uklanja ključnu tačku i prenumeriše preostale u istom upisu
 func (r *tourRepository) DeleteKeyPoint(tourId, keyPointId string) error {
 	tourObjID, err := primitive.ObjectIDFromHex(tourId)
 	if err != nil {
@@ -125,11 +453,89 @@ func (r *tourRepository) DeleteKeyPoint(tourId, keyPointId string) error {
 		return err
 	}
 	filter := bson.M{"_id": tourObjID}
-	update := bson.M{"$pull": bson.M{"keyPoints": bson.M{"_id": keyPointObjID}}}
-	_, err = r.tours.UpdateOne(context.TODO(), filter, update)
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"keyPoints": bson.M{"$filter": bson.M{
+			"input": bson.M{"$ifNull": bson.A{"$keyPoints", bson.A{}}},
+			"as":    "kp",
+			"cond":  bson.M{"$ne": bson.A{"$$kp._id", keyPointObjID}},
+		}}}}},
+		renumberKeyPoints,
+		setStartPoint,
+	}
+	_, err = r.tours.UpdateOne(context.TODO(), filter, pipeline)
 	return err
 }
 
+// ReorderKeyPoints slaže ključne tačke ture redom iz keyPointIds u jednom
+// upisu. Upis se primenjuje samo ako tura ima tačno te tačke; vraća false
+// kada ih u međuvremenu neko dodao ili obrisao (ili tura ne postoji).
+func (r *tourRepository) ReorderKeyPoints(tourId string, keyPointIds []primitive.ObjectID) (bool, error) {
+	tourObjID, err := primitive.ObjectIDFromHex(tourId)
+	if err != nil {
+		return false, err
+	}
+	filter := bson.M{"_id": tourObjID, "keyPoints": bson.M{"$size": len(keyPointIds)}}
+	if len(keyPointIds) > 0 {
+		// $all sa praznom listom ne pronalazi nijedan dokument
+		filter["keyPoints._id"] = bson.M{"$all": keyPointIds}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"keyPoints": bson.M{"$map": bson.M{
+			"input": keyPointIds,
+			"as":    "id",
+			"in": bson.M{"$arrayElemAt": bson.A{bson.M{"$filter": bson.M{
+				"input": "$keyPoints",
+				"as":    "kp",
+				"cond":  bson.M{"$eq": bson.A{"$$kp._id", "$$id"}},
+			}}, 0}},
+		}}}}},
+		renumberKeyPoints,
+		setStartPoint,
+	}
+	result, err := r.tours.UpdateOne(context.TODO(), filter, pipeline)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// EnsureKeyPointLocations postavlja GeoJSON lokacije ključnih tačaka i
+// startPoint turama sačuvanim pre uvođenja tih polja. Vraća broj izmenjenih tura.
+func (r *tourRepository) EnsureKeyPointLocations() (int64, error) {
+	filter := bson.M{"$or": bson.A{
+		bson.M{"keyPoints": bson.M{"$elemMatch": bson.M{"location": bson.M{"$exists": false}}}},
+		bson.M{"keyPoints.0": bson.M{"$exists": true}, "startPoint": bson.M{"$exists": false}},
+	}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"keyPoints": bson.M{"$map": bson.M{
+			"input": "$keyPoints",
+			"as":    "kp",
+			"in": bson.M{"$mergeObjects": bson.A{"$$kp", bson.M{"location": bson.M{
+				"type":        "Point",
+				"coordinates": bson.A{"$$kp.longitude", "$$kp.latitude"},
+			}}}},
+		}}}}},
+		setStartPoint,
+	}
+	result, err := r.tours.UpdateMany(context.TODO(), filter, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// EnsureKeyPointOrder postavlja order ključnim tačkama tura sačuvanih pre
+// uvođenja tog polja, prema njihovoj poziciji u nizu. Vraća broj izmenjenih tura.
+func (r *tourRepository) EnsureKeyPointOrder() (int64, error) {
+	filter := bson.M{"keyPoints": bson.M{"$elemMatch": bson.M{"order": bson.M{"$exists": false}}}}
+	pipeline := mongo.Pipeline{renumberKeyPoints}
+	result, err := r.tours.UpdateMany(context.TODO(), filter, pipeline)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
 func (r *tourRepository) Update(tour *domain.Tour) error {
 	filter := bson.M{"_id": tour.ID}
 	update := bson.M{"$set": tour}
@@ -137,35 +543,38 @@ func (r *tourRepository) Update(tour *domain.Tour) error {
 	return err
 }
 
-func (r *tourRepository) GetPublished() ([]*domain.Tour, error) {
-	var tours []*domain.Tour
-	// Filter koji vraća samo dokumente gde je status "published"
-	filter := bson.M{"status": domain.TourStatusPublished}
-	cursor, err := r.tours.Find(context.TODO(), filter)
-	if err != nil {
-		return nil, err
+// UpdateRoute upisuje samo polja rute (distancu, vremena prevoza i putanju), i
+// to samo ako ključne tačke i dalje imaju iste koordinate, istim redom, kao
+// one iz kojih je ruta izračunata. Vraća false kada su se tačke u
+// međuvremenu promenile; tada novu rutu upisuje izmena koja ih je promenila.
+func (r *tourRepository) UpdateRoute(tour *domain.Tour) (bool, error) {
+	filter := bson.M{"_id": tour.ID}
+	if len(tour.KeyPoints) > 0 {
+		latitudes := make([]float64, len(tour.KeyPoints))
+		longitudes := make([]float64, len(tour.KeyPoints))
+		for i, kp := range tour.KeyPoints {
+			latitudes[i], longitudes[i] = kp.Latitude, kp.Longitude
+		}
+		filter["$expr"] = bson.M{"$and": bson.A{
+			bson.M{"$eq": bson.A{"$keyPoints.latitude", latitudes}},
+			bson.M{"$eq": bson.A{"$keyPoints.longitude", longitudes}},
+		}}
+	} else {
+		filter["keyPoints.0"] = bson.M{"$exists": false}
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &tours); err != nil {
-		return nil, err
+	update := bson.M{"$set": bson.M{
+		"distance":      tour.Distance,
+		"transportInfo": tour.TransportInfo,
+	}}
+	if tour.RouteGeometry != nil {
+		update["$set"].(bson.M)["routeGeometry"] = tour.RouteGeometry
+		update["$set"].(bson.M)["routeProvider"] = tour.RouteProvider
+	} else {
+		update["$unset"] = bson.M{"routeGeometry": "", "routeProvider": ""}
 	}
-	return tours, nil
-}
-
-// NOVO: Funkcija za dobavljanje arhiviranih tura
-func (r *tourRepository) GetArchived() ([]*domain.Tour, error) {
-	var tours []*domain.Tour
-	// Filter koji vraća samo dokumente gde je status "archived"
-	filter := bson.M{"status": domain.TourStatusArchived} // Pretpostavka da konstanta postoji
-	cursor, err := r.tours.Find(context.TODO(), filter)
+	result, err := r.tours.UpdateOne(context.TODO(), filter, update)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer cursor.Close(context.TODO())
-
-	if err = cursor.All(context.TODO(), &tours); err != nil {
-		return nil, err
-	}
-	return tours, nil
-}
\ No newline at end of file
+	return result.MatchedCount == 1, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"math"
+	"testing"
+	"tours-service/domain"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestBoundingBoxPolygons(t *testing.T) {
+	tests := []struct {
+		name      string
+		box       domain.BoundingBox
+		polygons  int
+		minLat    float64
+		maxLat    float64
+		maxVertex int
+	}{
+		{"map viewport", domain.BoundingBox{MinLongitude: 19.7, MinLatitude: 45.2, MaxLongitude: 19.9, MaxLatitude: 45.3}, 1, 45.2, 45.3, 5},
+		{"continent", domain.BoundingBox{MinLongitude: -10, MinLatitude: 35, MaxLongitude: 40, MaxLatitude: 70}, 1, 35, 70, 203},
+		{"wider than a polygon", domain.BoundingBox{MinLongitude: -100, MinLatitude: -20, MaxLongitude: 100, MaxLatitude: 20}, 3, -20, 20, 0},
+		{"whole world", domain.BoundingBox{MinLongitude: -180, MinLatitude: -90, MaxLongitude: 180, MaxLatitude: 90}, 4, -maxBoundingBoxLatitude, maxBoundingBoxLatitude, 0},
+		{"only near the pole", domain.BoundingBox{MinLongitude: 0, MinLatitude: 89.95, MaxLongitude: 10, MaxLatitude: 90}, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polygons := boundingBoxPolygons(tt.box)
+			if len(polygons) != tt.polygons {
+				t.Fatalf("got %d polygons, want %d", len(polygons), tt.polygons)
+			}
+
+			west := tt.box.MinLongitude
+			for _, polygon := range polygons {
+				ring := polygon["coordinates"].(bson.A)[0].(bson.A)
+				if tt.maxVertex > 0 && len(ring) > tt.maxVertex {
+					t.Errorf("ring has %d vertices, want at most %d", len(ring), tt.maxVertex)
+				}
+				first, last := ring[0].(bson.A), ring[len(ring)-1].(bson.A)
+				if first[0] != last[0] || first[1] != last[1] {
+					t.Fatalf("ring is not closed: %v ... %v", first, last)
+				}
+				if first[0].(float64) != west {
+					t.Errorf("polygon starts at longitude %v, want %v", first[0], west)
+				}
+
+				east := west
+				for i, vertex := range ring {
+					lon, lat := vertex.(bson.A)[0].(float64), vertex.(bson.A)[1].(float64)
+					if lat != tt.minLat && lat != tt.maxLat {
+						t.Fatalf("vertex %v is off the box edges", vertex)
+					}
+					east = math.Max(east, lon)
+					if i > 0 {
+						previous := ring[i-1].(bson.A)[0].(float64)
+						if step := math.Abs(lon - previous); step > boundingBoxEdgeStep+1e-9 {
+							t.Fatalf("edge step %v between %v and %v exceeds %v", step, previous, lon, boundingBoxEdgeStep)
+						}
+					}
+				}
+				if east-west > maxBoundingBoxPolygonWidth+1e-9 {
+					t.Errorf("polygon is %v degrees wide", east-west)
+				}
+				west = east
+			}
+			if tt.polygons > 0 && west != tt.box.MaxLongitude {
+				t.Errorf("polygons end at longitude %v, want %v", west, tt.box.MaxLongitude)
+			}
+		})
+	}
+}
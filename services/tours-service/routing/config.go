@@ -0,0 +1,86 @@
+package routing
+
+import (
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"tours-service/domain"
+)
+
+// FromEnv bira provajdera na osnovu environment varijabli:
+//
+//	ROUTING_PROVIDER  ors, osrm ili offline (podrazumevano ors ako je
+//	                  postavljen ORS_API_KEY, inače offline)
+//	ROUTING_URL       adresa ORS/OSRM servera (za ors podrazumevano javni API)
+//	ORS_API_KEY       ključ javnog ORS API-ja
+//	ROUTING_TIMEOUT   rok za jedan poziv (podrazumevano 10s)
+//	ROUTING_PROFILES  izmene profila, npr. walking=foot-hiking,car=driving-hgv
+//
+// ORS i OSRM koriste vazdušnu liniju kao rezervu kada nisu dostupni.
+func FromEnv() Provider {
+	offline := NewGreatCircle()
+	apiKey := os.Getenv("ORS_API_KEY")
+	name := os.Getenv("ROUTING_PROVIDER")
+	if name == "" {
+		name = "offline"
+		if apiKey != "" {
+			name = "ors"
+		}
+	}
+
+	timeout := 10 * time.Second
+	if value := os.Getenv("ROUTING_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Fatalf("Neispravan ROUTING_TIMEOUT: %q", value)
+		}
+		timeout = parsed
+	}
+	baseURL := os.Getenv("ROUTING_URL")
+
+	var primary Provider
+	switch name {
+	case "offline":
+		log.Println("Routing: offline (vazdušna linija)")
+		return measured{offline}
+	case "ors":
+		if baseURL == "" {
+			baseURL = OpenRouteServiceURL
+		}
+		if baseURL == OpenRouteServiceURL && apiKey == "" {
+			log.Fatal("ROUTING_PROVIDER=ors sa javnim API-jem zahteva ORS_API_KEY")
+		}
+		primary = NewORS(baseURL, apiKey, profilesFromEnv(DefaultORSProfiles), timeout)
+	case "osrm":
+		if baseURL == "" {
+			log.Fatal("ROUTING_PROVIDER=osrm zahteva ROUTING_URL")
+		}
+		primary = NewOSRM(baseURL, profilesFromEnv(DefaultOSRMProfiles), timeout)
+	default:
+		log.Fatalf("Nepoznat ROUTING_PROVIDER: %q (dozvoljeno: ors, osrm, offline)", name)
+	}
+	log.Printf("Routing: %s na %s, rezerva: offline", name, baseURL)
+	return WithFallback(primary, offline)
+}
+
+// profilesFromEnv primenjuje ROUTING_PROFILES na podrazumevane profile
+func profilesFromEnv(defaults map[domain.TransportType]string) map[domain.TransportType]string {
+	profiles := map[domain.TransportType]string{}
+	for transport, profile := range defaults {
+		profiles[transport] = profile
+	}
+	value := os.Getenv("ROUTING_PROFILES")
+	if value == "" {
+		return profiles
+	}
+	for _, entry := range strings.Split(value, ",") {
+		transport, profile, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if _, known := defaults[domain.TransportType(transport)]; !ok || !known || profile == "" {
+			log.Fatalf("Neispravan ROUTING_PROFILES unos: %q (očekuje se walking|bicycle|car=profil)", entry)
+		}
+		profiles[domain.TransportType(transport)] = profile
+	}
+	return profiles
+}
@@ -0,0 +1,65 @@
+package routing
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"tours-service/domain"
+)
+
+const earthRadiusKm = 6371.0
+
+// averageSpeedKmh je pretpostavljena brzina po načinu prevoza za procenu
+// trajanja bez mape puteva
+var averageSpeedKmh = map[domain.TransportType]float64{
+	domain.TransportTypeWalking: 5,
+	domain.TransportTypeBicycle: 15,
+	domain.TransportTypeCar:     50,
+}
+
+// GreatCircle računa rutu vazdušnom linijom (haversine) između uzastopnih
+// tačaka. Ne zahteva mrežu, pa služi kao rezerva kada pravi provajder nije
+// dostupan; stvarna ruta po putevima je uvek nešto duža.
+type GreatCircle struct{}
+
+func NewGreatCircle() *GreatCircle {
+	return &GreatCircle{}
+}
+
+func (g *GreatCircle) Name() string {
+	return "offline"
+}
+
+func (g *GreatCircle) Route(_ context.Context, transport domain.TransportType, points []Point) (*Route, error) {
+	if len(points) < 2 {
+		return nil, ErrTooFewPoints
+	}
+	distance := 0.0
+	for i := 1; i < len(points); i++ {
+		distance += haversineKm(points[i-1], points[i])
+	}
+	speed, ok := averageSpeedKmh[transport]
+	if !ok {
+		speed = averageSpeedKmh[domain.TransportTypeWalking]
+	}
+	// Putanja su same tačke spojene pravim linijama
+	geometry := make([][]float64, len(points))
+	for i, p := range points {
+		geometry[i] = []float64{p.Longitude, p.Latitude}
+	}
+	return &Route{
+		DistanceKm: distance,
+		Duration:   time.Duration(distance / speed * float64(time.Hour)),
+		Geometry:   geometry,
+		Provider:   g.Name(),
+	}, nil
+}
+
+func haversineKm(a, b Point) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
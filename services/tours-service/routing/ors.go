@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tours-service/domain"
+)
+
+// OpenRouteServiceURL je javni ORS API; za njega je potreban API ključ
+const OpenRouteServiceURL = "https://api.openrouteservice.org"
+
+// DefaultORSProfiles su ORS profili po načinu prevoza
+var DefaultORSProfiles = map[domain.TransportType]string{
+	domain.TransportTypeWalking: "foot-walking",
+	domain.TransportTypeBicycle: "cycling-regular",
+	domain.TransportTypeCar:     "driving-car",
+}
+
+type orsRequestBody struct {
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+type orsResponseBody struct {
+	Features []struct {
+		Geometry struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Properties struct {
+			Summary struct {
+				Distance float64 `json:"distance"` // U metrima
+				Duration float64 `json:"duration"` // U sekundama
+			} `json:"summary"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// ORS računa rute preko OpenRouteService directions API-ja, javnog
+// (OpenRouteServiceURL, sa ključem) ili lokalne instance (bez ključa)
+type ORS struct {
+	baseURL  string
+	apiKey   string
+	profiles map[domain.TransportType]string
+	client   *http.Client
+}
+
+func NewORS(baseURL, apiKey string, profiles map[domain.TransportType]string, timeout time.Duration) *ORS {
+	return &ORS{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		apiKey:   apiKey,
+		profiles: profiles,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *ORS) Name() string {
+	return "ors"
+}
+
+func (o *ORS) Route(ctx context.Context, transport domain.TransportType, points []Point) (*Route, error) {
+	if len(points) < 2 {
+		return nil, ErrTooFewPoints
+	}
+	profile, ok := o.profiles[transport]
+	if !ok {
+		return nil, fmt.Errorf("no ORS profile for transport type %q", transport)
+	}
+
+	// ORS očekuje koordinate kao [longitude, latitude]
+	body := orsRequestBody{}
+	for _, p := range points {
+		body.Coordinates = append(body.Coordinates, []float64{p.Longitude, p.Latitude})
+	}
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/v2/directions/"+profile+"/geojson", bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ORS returned %d", resp.StatusCode)
+	}
+
+	var orsResp orsResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&orsResp); err != nil {
+		return nil, fmt.Errorf("decoding ORS response: %w", err)
+	}
+	if len(orsResp.Features) == 0 {
+		return nil, fmt.Errorf("ORS returned no route")
+	}
+	feature := orsResp.Features[0]
+	return &Route{
+		DistanceKm: feature.Properties.Summary.Distance / 1000,
+		Duration:   time.Duration(feature.Properties.Summary.Duration * float64(time.Second)),
+		Geometry:   feature.Geometry.Coordinates,
+		Provider:   o.Name(),
+	}, nil
+}
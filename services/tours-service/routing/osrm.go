@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tours-service/domain"
+)
+
+// DefaultOSRMProfiles su OSRM profili po načinu prevoza. osrm-backend
+// pokrenut sa jednim profilom ignoriše ime profila u putanji, pa za više
+// načina prevoza treba posebna instanca ili proxy koji ih razlikuje.
+var DefaultOSRMProfiles = map[domain.TransportType]string{
+	domain.TransportTypeWalking: "foot",
+	domain.TransportTypeBicycle: "bike",
+	domain.TransportTypeCar:     "car",
+}
+
+type osrmResponseBody struct {
+	Code   string `json:"code"`
+	Routes []struct {
+		Distance float64 `json:"distance"` // U metrima
+		Duration float64 `json:"duration"` // U sekundama
+		Geometry struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"routes"`
+}
+
+// OSRM računa rute preko route servisa OSRM-a (ili kompatibilnog servera)
+type OSRM struct {
+	baseURL  string
+	profiles map[domain.TransportType]string
+	client   *http.Client
+}
+
+func NewOSRM(baseURL string, profiles map[domain.TransportType]string, timeout time.Duration) *OSRM {
+	return &OSRM{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		profiles: profiles,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+func (o *OSRM) Name() string {
+	return "osrm"
+}
+
+func (o *OSRM) Route(ctx context.Context, transport domain.TransportType, points []Point) (*Route, error) {
+	if len(points) < 2 {
+		return nil, ErrTooFewPoints
+	}
+	profile, ok := o.profiles[transport]
+	if !ok {
+		return nil, fmt.Errorf("no OSRM profile for transport type %q", transport)
+	}
+
+	// Koordinate idu u putanju: lon,lat;lon,lat;...
+	coords := make([]string, len(points))
+	for i, p := range points {
+		coords[i] = fmt.Sprintf("%f,%f", p.Longitude, p.Latitude)
+	}
+	url := o.baseURL + "/route/v1/" + profile + "/" + strings.Join(coords, ";") + "?overview=full&geometries=geojson"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var osrmResp osrmResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&osrmResp); err != nil {
+		return nil, fmt.Errorf("decoding OSRM response (status %d): %w", resp.StatusCode, err)
+	}
+	if osrmResp.Code != "Ok" || len(osrmResp.Routes) == 0 {
+		return nil, fmt.Errorf("OSRM returned %s (status %d)", osrmResp.Code, resp.StatusCode)
+	}
+	route := osrmResp.Routes[0]
+	return &Route{
+		DistanceKm: route.Distance / 1000,
+		Duration:   time.Duration(route.Duration * float64(time.Second)),
+		Geometry:   route.Geometry.Coordinates,
+		Provider:   o.Name(),
+	}, nil
+}
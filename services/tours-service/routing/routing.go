@@ -0,0 +1,79 @@
+// Package routing računa dužinu i trajanje rute kroz ključne tačke ture,
+// posebno za svaki način prevoza (pešačenje, bicikl, automobil).
+package routing
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"tours-service/domain"
+	"tours-service/metrics"
+)
+
+// Point je geografska tačka rute
+type Point struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// Route je rezultat rutiranja: dužina u kilometrima, procenjeno trajanje,
+// putanja kao [longitude, latitude] parovi i provajder koji ju je izračunao
+type Route struct {
+	DistanceKm float64
+	Duration   time.Duration
+	Geometry   [][]float64
+	Provider   string
+}
+
+// Provider računa rutu kroz tačke redom kojim su zadate, za dati način
+// prevoza. Svaka implementacija sama bira profil (npr. foot-walking kod ORS-a).
+type Provider interface {
+	Name() string
+	Route(ctx context.Context, transport domain.TransportType, points []Point) (*Route, error)
+}
+
+// ErrTooFewPoints se vraća kada ruta ima manje od dve tačke
+var ErrTooFewPoints = errors.New("route needs at least two points")
+
+// measured broji pozive provajdera po ishodu (routing_requests_total)
+type measured struct {
+	Provider
+}
+
+func (p measured) Route(ctx context.Context, transport domain.TransportType, points []Point) (*Route, error) {
+	route, err := p.Provider.Route(ctx, transport, points)
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.RoutingRequests.WithLabelValues(p.Name(), string(transport), result).Inc()
+	return route, err
+}
+
+// withFallback koristi rezervni provajder kada glavni nije dostupan, tako da
+// tura uvek dobije dužinu, makar i procenjenu vazdušnom linijom
+type withFallback struct {
+	primary  Provider
+	fallback Provider
+}
+
+// WithFallback vraća provajder koji greške glavnog provajdera loguje i
+// umesto njega koristi fallback
+func WithFallback(primary, fallback Provider) Provider {
+	return &withFallback{primary: measured{primary}, fallback: measured{fallback}}
+}
+
+func (p *withFallback) Name() string {
+	return p.primary.Name()
+}
+
+func (p *withFallback) Route(ctx context.Context, transport domain.TransportType, points []Point) (*Route, error) {
+	route, err := p.primary.Route(ctx, transport, points)
+	if err == nil || errors.Is(err, ErrTooFewPoints) {
+		return route, err
+	}
+	log.Printf("Routing provider %s failed for %s, using %s: %v", p.primary.Name(), transport, p.fallback.Name(), err)
+	return p.fallback.Route(ctx, transport, points)
+}
@@ -1,66 +1,61 @@
 package service
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"errors" // <-- 1. DODAT IMPORT
 	"log"
-	"net/http"
+	"math"
 	"time"
 	"tours-service/domain"
 	"tours-service/repository"
+	"tours-service/routing"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
-// --- NOVO: Unesite vaš API ključ ovde ---
-const orsApiKey = "eyJvcmciOiI1YjNjZTM1OTc4NTExMTAwMDFjZjYyNDgiLCJpZCI6IjQxMTE3OTdhMmE5YjQxM2Y5OWQzZWQ4MzM2MmNjY2VjIiwiaCI6Im11cm11cjY0In0="
-
-// --- NOVO: Strukture za čitanje odgovora od ORS API-ja ---
-type orsRequestBody struct {
-	Coordinates [][]float64 `json:"coordinates"`
-}
-type orsSummary struct {
-	Distance float64 `json:"distance"` // U metrima
-}
-type orsProperties struct {
-	Summary orsSummary `json:"summary"`
-}
-type orsFeature struct {
-	Properties orsProperties `json:"properties"`
-}
-type orsResponseBody struct {
-	Features []orsFeature `json:"features"`
-}
 
 type TourService interface {
 	Create(tour *domain.Tour) error
 	GetById(tourId string) (*domain.Tour, error) // <-- DODATI
-	GetByAuthorId(authorId string) ([]*domain.Tour, error)
-	GetAll() ([]*domain.Tour, error)                          // <-- DODATO
+	GetByAuthorId(authorId string, query domain.TourQuery) (*domain.TourPage, error)
+	GetAll(query domain.TourQuery) (*domain.TourPage, error)  // <-- DODATO
 	AddReview(tourId string, review *domain.TourReview) error // <-- DODATO
 	// NOVE METODE ZA KEY POINTS
-	AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error
+	AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint, position int) error
 	UpdateKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error
 	DeleteKeyPoint(tourId, keyPointId string) error
+	ReorderKeyPoints(tourId string, keyPointIds []string) (*domain.Tour, error)
     Update(tour *domain.Tour) (*domain.Tour, error) // <-- ISPRAVLJEN POVRATNI TIP
 	// --- NOVE METODE ZA STANJA TURE ---
 	Publish(tourId string) (*domain.Tour, error)
 	Archive(tourId string) (*domain.Tour, error)
 	Reactivate(tourId string) (*domain.Tour, error) // <-- DODATA NOVA METODA
 	AddTransportInfo(tourId string, transportInfo []domain.TourTransport) (*domain.Tour, error)
-	GetPublished() ([]*domain.Tour, error) // <-- DODATA NOVA METODA
-	GetArchived() ([]*domain.Tour, error) // NOVO
+	GetPublished(query domain.TourQuery) (*domain.TourPage, error) // <-- DODATA NOVA METODA
+	GetArchived(query domain.TourQuery) (*domain.TourPage, error) // NOVO
+	GetNearby(latitude, longitude, radiusKm float64, query domain.TourQuery) (*domain.NearbyTourPage, error)
+	RecomputeRoute(tourId string) (*domain.Tour, error)
 }
 
+var (
+	// ErrInvalidKeyPointOrder znači da lista za preraspoređivanje nije
+	// permutacija ključnih tačaka ture (fali tačka, ponavlja se ili je strana)
+	ErrInvalidKeyPointOrder = errors.New("key point ids must list every key point of the tour exactly once")
+	// ErrKeyPointsChanged znači da su ključne tačke dodate ili obrisane dok se
+	// preraspoređivalo; klijent treba da učita turu i pokuša ponovo
+	ErrKeyPointsChanged = errors.New("key points of the tour changed, reload the tour and try again")
+	// ErrInvalidCoordinates znači da širina nije u [-90, 90] ili dužina u [-180, 180]
+	ErrInvalidCoordinates = errors.New("latitude must be between -90 and 90 and longitude between -180 and 180")
+)
+
 type tourService struct {
-	repo       repository.TourRepository
-	httpClient *http.Client // <-- NOVO: HTTP Klijent za slanje zahteva
+	repo    repository.TourRepository
+	routing routing.Provider // Računa dužinu ture i vremena prevoza
 }
 
-func NewTourService(repo repository.TourRepository) TourService {
+func NewTourService(repo repository.TourRepository, routingProvider routing.Provider) TourService {
 	return &tourService{
-		repo:       repo,
-		httpClient: &http.Client{}, // <-- NOVO: Inicijalizacija klijenta
+		repo:    repo,
+		routing: routingProvider,
 	}
 }
 
@@ -69,6 +64,8 @@ func NewTourService(repo repository.TourRepository) TourService {
 func (s *tourService) Create(tour *domain.Tour) error {
 	tour.Status = domain.TourStatusDraft // Koristimo konstantu
 	tour.Price = 0.0
+	tour.AverageRating = 0
+	tour.StartPoint = nil
 	tour.KeyPoints = []domain.TourKeyPoint{}
 	tour.Reviews = []domain.TourReview{}
 	tour.TransportInfo = []domain.TourTransport{}
@@ -79,12 +76,13 @@ func (s *tourService) GetById(tourId string) (*domain.Tour, error) {
 	return s.repo.GetById(tourId)
 }
 
-func (s *tourService) GetByAuthorId(authorId string) ([]*domain.Tour, error) {
-	return s.repo.GetByAuthorId(authorId)
+func (s *tourService) GetByAuthorId(authorId string, query domain.TourQuery) (*domain.TourPage, error) {
+	query.AuthorId = authorId
+	return s.repo.Find(query)
 }
 
-func (s *tourService) GetAll() ([]*domain.Tour, error) {
-	return s.repo.GetAll()
+func (s *tourService) GetAll(query domain.TourQuery) (*domain.TourPage, error) {
+	return s.repo.Find(query)
 }
 
 func (s *tourService) AddReview(tourId string, review *domain.TourReview) error {
@@ -95,77 +93,173 @@ func (s *tourService) AddReview(tourId string, review *domain.TourReview) error
 }
 
 // --- PREPRAVLJENA AddKeyPoint METODA ---
-func (s *tourService) AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error {
+// position je redni broj (od 1) na koji se tačka umeće; 0 dodaje na kraj
+func (s *tourService) AddKeyPoint(tourId string, keyPoint *domain.TourKeyPoint, position int) error {
+	if !domain.ValidCoordinates(keyPoint.Latitude, keyPoint.Longitude) {
+		return ErrInvalidCoordinates
+	}
 	keyPoint.ID = primitive.NewObjectID()
 	keyPoint.TourId = tourId
+	keyPoint.Location = domain.NewGeoPoint(keyPoint.Latitude, keyPoint.Longitude)
 	
 	// 1. Dodajemo ključnu tačku u bazu
-	err := s.repo.AddKeyPoint(tourId, keyPoint)
+	err := s.repo.AddKeyPoint(tourId, keyPoint, position)
 	if err != nil {
 		return err
 	}
 
-	// 2. Dobavljamo celu turu sa novom tačkom
+	// 2. Ponovo računamo distancu, putanju i procenjena vremena
+	s.recomputeAfterChange(tourId)
+	return nil
+}
+
+// RecomputeRoute ponovo računa distancu, putanju i procenjena vremena ture iz
+// njenih trenutnih ključnih tačaka. Jedini je put kojim se ruta menja: poziva
+// se posle svake izmene ključnih tačaka i iz komande backfill-routes.
+func (s *tourService) RecomputeRoute(tourId string) (*domain.Tour, error) {
 	tour, err := s.repo.GetById(tourId)
 	if err != nil {
-		return err // Vraćamo grešku ako ne možemo da dobavimo turu
+		return nil, err
+	}
+	if err := s.computeRoute(tour); err != nil {
+		return nil, err
+	}
+	updated, err := s.repo.UpdateRoute(tour)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		// Tačke su se promenile dok smo računali; tu izmenu prati novo računanje
+		log.Printf("Key points of tour %s changed during route calculation, skipping\n", tourId)
 	}
+	return tour, nil
+}
 
-	// 3. Ako ima 2 ili više tačaka, računamo distancu
-	if len(tour.KeyPoints) >= 2 {
-		log.Println("Calculating distance for tour:", tour.ID.Hex())
-		
-		// Pripremamo koordinate za ORS API ([longitude, latitude])
-		var coords [][]float64
-		for _, kp := range tour.KeyPoints {
-			coords = append(coords, []float64{kp.Longitude, kp.Latitude})
-		}
+// recomputeAfterChange računa rutu posle izmene ključnih tačaka. Izmena je
+// već sačuvana, pa greška samo ostavlja staru rutu do sledećeg računanja.
+func (s *tourService) recomputeAfterChange(tourId string) {
+	if _, err := s.RecomputeRoute(tourId); err != nil {
+		log.Printf("Error calculating route for tour %s: %v\n", tourId, err)
+	}
+}
 
-		// Pravimo telo zahteva
-		reqBody := orsRequestBody{Coordinates: coords}
-		reqBytes, _ := json.Marshal(reqBody)
+// computeRoute računa dužinu i putanju ture za njen glavni (prvi) način
+// prevoza, pešačenje ako ga nema, i procenjuje vremena koja autor nije uneo.
+// Sa manje od dve ključne tačke ruta ne postoji.
+func (s *tourService) computeRoute(tour *domain.Tour) error {
+	if len(tour.KeyPoints) < 2 {
+		tour.Distance = 0
+		tour.RouteGeometry = nil
+		tour.RouteProvider = ""
+		for i := range tour.TransportInfo {
+			if tour.TransportInfo[i].Estimated {
+				tour.TransportInfo[i].TimeInMinutes = 0
+			}
+		}
+		return nil
+	}
 
-		// Šaljemo POST zahtev na ORS API
-		orsURL := "https://api.openrouteservice.org/v2/directions/driving-car/geojson"
-		req, _ := http.NewRequest("POST", orsURL, bytes.NewBuffer(reqBytes))
-		req.Header.Set("Authorization", orsApiKey)
-		req.Header.Set("Content-Type", "application/json")
+	points := make([]routing.Point, len(tour.KeyPoints))
+	for i, kp := range tour.KeyPoints {
+		points[i] = routing.Point{Latitude: kp.Latitude, Longitude: kp.Longitude}
+	}
 
-		resp, err := s.httpClient.Do(req)
+	primary := domain.TransportTypeWalking
+	if len(tour.TransportInfo) > 0 {
+		primary = tour.TransportInfo[0].Type
+	}
+	routes := map[domain.TransportType]*routing.Route{}
+	route := func(transport domain.TransportType) (*routing.Route, error) {
+		if r, ok := routes[transport]; ok {
+			return r, nil
+		}
+		r, err := s.routing.Route(context.Background(), transport, points)
 		if err != nil {
-			log.Printf("Error calling ORS API: %v\n", err)
-			return nil // Opciono: ne vraćamo grešku klijentu ako ORS ne radi
+			return nil, err
 		}
-		defer resp.Body.Close()
+		routes[transport] = r
+		return r, nil
+	}
 
-		// Čitamo i parsiramo odgovor
-		var orsResp orsResponseBody
-		if err := json.NewDecoder(resp.Body).Decode(&orsResp); err != nil {
-			log.Printf("Error decoding ORS response: %v\n", err)
-			return nil
+	r, err := route(primary)
+	if err != nil {
+		return err
+	}
+	tour.Distance = r.DistanceKm
+	tour.RouteGeometry = &domain.LineString{Type: "LineString", Coordinates: r.Geometry}
+	tour.RouteProvider = r.Provider
+	log.Printf("New distance for tour %s is %f km (%s, %s)\n", tour.ID.Hex(), tour.Distance, primary, r.Provider)
+
+	for i, transport := range tour.TransportInfo {
+		if !transport.Estimated {
+			continue
 		}
-
-		// Ažuriramo distancu na turi
-		if len(orsResp.Features) > 0 {
-			distanceInMeters := orsResp.Features[0].Properties.Summary.Distance
-			tour.Distance = distanceInMeters / 1000 // Pretvaramo u km
-			log.Printf("New distance for tour %s is %f km\n", tour.ID.Hex(), tour.Distance)
-			
-			// Sačuvamo izmenjenu turu u bazi
-			return s.repo.Update(tour)
+		r, err := route(transport.Type)
+		if err != nil {
+			return err
 		}
+		tour.TransportInfo[i].TimeInMinutes = int(math.Ceil(r.Duration.Minutes()))
 	}
-
 	return nil
 }
 
 func (s *tourService) UpdateKeyPoint(tourId string, keyPoint *domain.TourKeyPoint) error {
-	// Logika za validaciju ili dodatne provere bi išla ovde
-	return s.repo.UpdateKeyPoint(tourId, keyPoint)
+	if !domain.ValidCoordinates(keyPoint.Latitude, keyPoint.Longitude) {
+		return ErrInvalidCoordinates
+	}
+	keyPoint.Location = domain.NewGeoPoint(keyPoint.Latitude, keyPoint.Longitude)
+	if err := s.repo.UpdateKeyPoint(tourId, keyPoint); err != nil {
+		return err
+	}
+	s.recomputeAfterChange(tourId)
+	return nil
 }
 
 func (s *tourService) DeleteKeyPoint(tourId, keyPointId string) error {
-	return s.repo.DeleteKeyPoint(tourId, keyPointId)
+	if err := s.repo.DeleteKeyPoint(tourId, keyPointId); err != nil {
+		return err
+	}
+	s.recomputeAfterChange(tourId)
+	return nil
+}
+
+// ReorderKeyPoints postavlja novi redosled ključnih tačaka; keyPointIds mora
+// sadržati svaku tačku ture tačno jednom. Ruta se računa ponovo jer zavisi
+// od redosleda.
+func (s *tourService) ReorderKeyPoints(tourId string, keyPointIds []string) (*domain.Tour, error) {
+	ids := make([]primitive.ObjectID, len(keyPointIds))
+	seen := make(map[primitive.ObjectID]bool, len(keyPointIds))
+	for i, id := range keyPointIds {
+		objID, err := primitive.ObjectIDFromHex(id)
+		if err != nil || seen[objID] {
+			return nil, ErrInvalidKeyPointOrder
+		}
+		seen[objID] = true
+		ids[i] = objID
+	}
+
+	tour, err := s.repo.GetById(tourId)
+	if err != nil {
+		return nil, err
+	}
+	if len(tour.KeyPoints) != len(ids) {
+		return nil, ErrInvalidKeyPointOrder
+	}
+	for _, kp := range tour.KeyPoints {
+		if !seen[kp.ID] {
+			return nil, ErrInvalidKeyPointOrder
+		}
+	}
+
+	updated, err := s.repo.ReorderKeyPoints(tourId, ids)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrKeyPointsChanged
+	}
+	s.recomputeAfterChange(tourId)
+	return s.repo.GetById(tourId)
 }
 
 // --- DODAJEMO NOVU METODU ---
@@ -182,7 +276,15 @@ func (s *tourService) AddTransportInfo(tourId string, transportInfo []domain.Tou
 	if err != nil {
 		return nil, err
 	}
+	// Vremena koja autor nije uneo procenjujemo iz rute
+	for i := range transportInfo {
+		transportInfo[i].Estimated = transportInfo[i].TimeInMinutes <= 0
+	}
 	tour.TransportInfo = transportInfo
+	// Glavni način prevoza određuje distancu, pa se ruta računa ponovo
+	if err := s.computeRoute(tour); err != nil {
+		log.Printf("Error calculating route for tour %s: %v\n", tour.ID.Hex(), err)
+	}
 	return s.Update(tour)
 }
 
@@ -250,37 +352,62 @@ func (s *tourService) Reactivate(tourId string) (*domain.Tour, error) {
 	return s.Update(tour)
 }
 
-func (s *tourService) GetPublished() ([]*domain.Tour, error) {
+func (s *tourService) GetPublished(query domain.TourQuery) (*domain.TourPage, error) {
 	// 1. Dobavi samo objavljene ture iz repozitorijuma
-	publishedTours, err := s.repo.GetPublished()
+	query.Status = domain.TourStatusPublished
+	publishedTours, err := s.repo.Find(query)
 	if err != nil {
 		return nil, err
 	}
 
 	// 2. Za svaku turu, ostavi samo prvu ključnu tačku
-	for _, tour := range publishedTours {
-		if len(tour.KeyPoints) > 0 {
-			tour.KeyPoints = tour.KeyPoints[:1] // Skraćujemo niz na samo prvi element
-		}
+	for _, tour := range publishedTours.Tours {
+		hideKeyPoints(tour)
 	}
 
 	return publishedTours, nil
 }
 
 // NOVO: Servisna metoda za dobavljanje arhiviranih tura
-func (s *tourService) GetArchived() ([]*domain.Tour, error) {
+func (s *tourService) GetArchived(query domain.TourQuery) (*domain.TourPage, error) {
 	// 1. Dobavi samo arhivirane ture iz repozitorijuma
-	archivedTours, err := s.repo.GetArchived()
+	query.Status = domain.TourStatusArchived
+	archivedTours, err := s.repo.Find(query)
 	if err != nil {
 		return nil, err
 	}
 
 	// 2. Za svaku turu, ostavi samo prvu ključnu tačku (kao i za objavljene)
-	for _, tour := range archivedTours {
-		if len(tour.KeyPoints) > 0 {
-			tour.KeyPoints = tour.KeyPoints[:1] // Skraćujemo niz na samo prvi element
-		}
+	for _, tour := range archivedTours.Tours {
+		hideKeyPoints(tour)
 	}
 
 	return archivedTours, nil
 }
+
+// GetNearby vraća objavljene ture sa bar jednom ključnom tačkom u krugu od
+// radiusKm oko lokacije, najbliže prve. Kao i u listi objavljenih tura,
+// prikazuje se samo prva ključna tačka.
+func (s *tourService) GetNearby(latitude, longitude, radiusKm float64, query domain.TourQuery) (*domain.NearbyTourPage, error) {
+	if !domain.ValidCoordinates(latitude, longitude) {
+		return nil, ErrInvalidCoordinates
+	}
+	query.Status = domain.TourStatusPublished
+	nearbyTours, err := s.repo.Nearby(latitude, longitude, radiusKm, query)
+	if err != nil {
+		return nil, err
+	}
+	for _, tour := range nearbyTours.Tours {
+		hideKeyPoints(&tour.Tour)
+	}
+	return nearbyTours, nil
+}
+
+// hideKeyPoints ostavlja samo prvu ključnu tačku ture, za liste koje vide
+// turisti koji turu još nisu kupili
+func hideKeyPoints(tour *domain.Tour) {
+	if len(tour.KeyPoints) > 0 {
+		tour.KeyPoints = tour.KeyPoints[:1] // Skraćujemo niz na samo prvi element
+	}
+	tour.RouteGeometry = nil // Putanja bi otkrila i ostale tačke
+}
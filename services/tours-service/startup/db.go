@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"os"
+	"tours-service/metrics"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -19,7 +20,7 @@ func ConnectDB() *mongo.Client {
 	log.Printf("Povezivanje na MongoDB na adresi: %s", uri)
 
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
+	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI).SetMonitor(metrics.MongoMonitor())
 
 	client, err := mongo.Connect(context.TODO(), opts)
 	if err != nil {
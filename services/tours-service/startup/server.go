@@ -1,13 +1,17 @@
 package startup
 
 import (
+	"log"
+	"net/http"
 	"tours-service/api"
 	_ "tours-service/docs"
 	"tours-service/repository"
+	"tours-service/routing"
 	"tours-service/service"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -22,7 +26,29 @@ func NewServer() *Server {
 
 	// 2. Inicijalizujemo sve slojeve, kao u vašem primeru
 	tourRepo := repository.NewTourRepository(mongoClient)
-	tourService := service.NewTourService(tourRepo)
+	// Indeksi za filtere i sortiranje liste tura
+	if err := tourRepo.EnsureIndexes(); err != nil {
+		log.Printf("Error creating tour indexes: %v\n", err)
+	}
+	// Ture sačuvane pre uvođenja redosleda ključnih tačaka dobijaju order po poziciji
+	if migrated, err := tourRepo.EnsureKeyPointOrder(); err != nil {
+		log.Printf("Error setting key point order: %v\n", err)
+	} else if migrated > 0 {
+		log.Printf("Key point order set for %d tours\n", migrated)
+	}
+	// i prosečnu ocenu iz recenzija, za sortiranje po oceni
+	if migrated, err := tourRepo.EnsureAverageRating(); err != nil {
+		log.Printf("Error setting average rating: %v\n", err)
+	} else if migrated > 0 {
+		log.Printf("Average rating set for %d tours\n", migrated)
+	}
+	// i GeoJSON lokacije ključnih tačaka i početne tačke, za pretragu po blizini
+	if migrated, err := tourRepo.EnsureKeyPointLocations(); err != nil {
+		log.Printf("Error setting key point locations: %v\n", err)
+	} else if migrated > 0 {
+		log.Printf("Key point locations set for %d tours\n", migrated)
+	}
+	tourService := service.NewTourService(tourRepo, routing.FromEnv())
 	tourHandler := api.NewTourHandler(tourService)
 
     //touristPositionRepo := repository.NewTouristPositionRepository(mongoClient)
@@ -30,13 +56,28 @@ func NewServer() *Server {
 	//touristPositionHandler := api.NewTouristPositionHandler(touristPositionService)
 
 	// 3. Kreiramo ruter i podešavamo CORS
-	router := gin.Default()
+	router := gin.New()
+	// Recovery + JSON access log sa X-Request-ID umesto podrazumevanog gin logger-a
+	router.Use(gin.Recovery(), api.RequestLogger(), api.Metrics())
 	config := cors.DefaultConfig()
 	config.AllowOrigins = []string{"*"} // Dozvoljavamo sve za sada, možete promeniti na http://localhost:4200
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.ExposeHeaders = []string{api.TotalCountHeader}
 	router.Use(cors.New(config))
 
+	// Health check koji koristi API gateway - proverava i konekciju ka bazi
+	router.GET("/health", func(c *gin.Context) {
+		if err := mongoClient.Ping(c.Request.Context(), nil); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "down", "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "up"})
+	})
+
+	// Prometheus metrike (HTTP, baza, gRPC)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// 4. Definišemo Swagger i API rute
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	apiGroup := router.Group("/api")
@@ -47,18 +88,20 @@ func NewServer() *Server {
 			toursGroup.GET("/my-tours", api.AuthMiddleware(), tourHandler.GetByAuthor)
 			toursGroup.GET("", tourHandler.GetAll) // Javna ruta za prikaz svih tura
 			toursGroup.POST("/:id/reviews", api.AuthMiddleware(), tourHandler.AddReview)
-			toursGroup.GET("/:id", tourHandler.GetById)
+			toursGroup.GET("/:id", api.SharedCache(60), tourHandler.GetById)
 			// NOVE RUTE ZA KEY POINTS
 			toursGroup.POST("/:id/keypoints", api.AuthMiddleware(), tourHandler.AddKeyPoint)
+			toursGroup.PUT("/:id/keypoints/order", api.AuthMiddleware(), tourHandler.ReorderKeyPoints)
 			toursGroup.PUT("/:id/keypoints/:keypointId", api.AuthMiddleware(), tourHandler.UpdateKeyPoint)
 			toursGroup.DELETE("/:id/keypoints/:keypointId", api.AuthMiddleware(), tourHandler.DeleteKeyPoint)
 			// --- NOVE RUTE ZA STANJA TURE ---
 			toursGroup.POST("/:id/transport-info", api.AuthMiddleware(), tourHandler.AddTransportInfo)
 			toursGroup.POST("/:id/publish", api.AuthMiddleware(), tourHandler.Publish)
 			toursGroup.POST("/:id/archive", api.AuthMiddleware(), tourHandler.Archive)
-			toursGroup.GET("/published", tourHandler.GetPublished)
+			toursGroup.GET("/published", api.SharedCache(60), tourHandler.GetPublished)
+			toursGroup.GET("/nearby", api.SharedCache(60), tourHandler.GetNearby)
 			toursGroup.POST("/:id/reactivate", api.AuthMiddleware(), tourHandler.Reactivate)
-			toursGroup.GET("/archived", tourHandler.GetArchived) // NOVO: Registracija rute
+			toursGroup.GET("/archived", api.SharedCache(60), tourHandler.GetArchived) // NOVO: Registracija rute
 		}
 
 		/*positionGroup := apiGroup.Group("/tourist-position")